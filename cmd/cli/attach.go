@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/qubesome/cli/internal/profiles"
+	"github.com/urfave/cli/v3"
+)
+
+func attachCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "attach",
+		Usage: "view the headless display of a running profile",
+		Description: `Examples:
+
+qubesome attach               - Attach to the display of the active profile
+qubesome attach work          - Attach to the display of the work profile
+
+Requires the profile to set displayBackend: headless, and a VNC viewer
+that supports unix sockets, such as TigerVNC.
+`,
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "profile",
+				Destination: &targetProfile,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			prof, err := profileOrActive(targetProfile)
+			if err != nil {
+				return err
+			}
+			return profiles.Attach(prof)
+		},
+	}
+	return cmd
+}
@@ -3,9 +3,12 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/qubesome/cli/internal/clipboard"
 	"github.com/qubesome/cli/internal/command"
+	"github.com/qubesome/cli/internal/types"
 	"github.com/urfave/cli/v3"
 )
 
@@ -13,9 +16,9 @@ func clipboardCommand() *cli.Command {
 	clipType := &cli.StringFlag{
 		Name:    "type",
 		Aliases: []string{"t"},
+		Usage:   "content type to copy, picked from the clipboard targets when not set",
 		Validator: func(s string) error {
-			switch s {
-			case "image/png":
+			if types.ValidClipboardTarget(s) {
 				return nil
 			}
 			return fmt.Errorf("unsupported type %q", s)
@@ -164,7 +167,6 @@ qubesome clip to-host -profile <name>        - Copy clipboard contents from a sp
 					}
 
 					if typ := c.String("type"); typ != "" {
-						fmt.Println(typ)
 						opts = append(opts, clipboard.WithContentType(typ))
 					}
 
@@ -173,6 +175,34 @@ qubesome clip to-host -profile <name>        - Copy clipboard contents from a sp
 					)
 				},
 			},
+			{
+				// serve is run by the other commands to keep the
+				// clipboard contents available from the background.
+				Name:   "serve",
+				Hidden: true,
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "display"},
+					&cli.StringFlag{Name: "authority"},
+					&cli.StringFlag{Name: "type"},
+				},
+				Action: func(ctx context.Context, c *cli.Command) error {
+					display := c.Int("display")
+					if display < 0 || display > 255 {
+						return fmt.Errorf("invalid display %d", display)
+					}
+
+					data, err := io.ReadAll(os.Stdin)
+					if err != nil {
+						return err
+					}
+
+					d := clipboard.Display{Number: uint8(display), Authority: c.String("authority")}
+					return clipboard.Serve(d, c.String("type"), data, func() {
+						fmt.Println(clipboard.ServeReady)
+						os.Stdout.Close()
+					})
+				},
+			},
 		},
 	}
 	return cmd
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qubesome/cli/internal/profiles"
+	"github.com/urfave/cli/v3"
+)
+
+var displaySize string
+
+func displayCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "display",
+		Usage: "manage the display of running profiles",
+		Commands: []*cli.Command{
+			{
+				Name:  "resize",
+				Usage: "resize the display of a running profile, keeping its workloads",
+				Description: `Examples:
+
+qubesome display resize work 2560x1440   - Resize the display of the work profile to 2560x1440
+qubesome display resize work auto        - Resize the display of the work profile to the host resolution
+qubesome display resize work             - Same as auto
+`,
+				Arguments: []cli.Argument{
+					&cli.StringArg{
+						Name:        "profile",
+						Destination: &targetProfile,
+					},
+					&cli.StringArg{
+						Name:        "size",
+						Value:       profiles.ResizeAuto,
+						Destination: &displaySize,
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					if targetProfile == "" {
+						return fmt.Errorf("profile name is required")
+					}
+					prof, err := profileOrActive(targetProfile)
+					if err != nil {
+						return err
+					}
+					return profiles.Resize(prof.Runner, prof, displaySize)
+				},
+			},
+		},
+	}
+	return cmd
+}
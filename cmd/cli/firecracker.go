@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qubesome/cli/internal/runners/firecracker"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	kernelFile     string
+	deleteSnapshot bool
+)
+
+func firecrackerCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "firecracker",
+		Usage: "manage the firecracker runner",
+		Commands: []*cli.Command{
+			{
+				Name:  "import-kernel",
+				Usage: "import the kernel image used to boot firecracker VMs",
+				Description: `Examples:
+
+qubesome firecracker import-kernel ./vmlinux   - Use ./vmlinux instead of downloading the kernel image
+
+The file is verified against the firecracker.kernelSHA256 set in the user-level config, when set.
+`,
+				Arguments: []cli.Argument{
+					&cli.StringArg{
+						Name:        "file",
+						Destination: &kernelFile,
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					if kernelFile == "" {
+						return fmt.Errorf("kernel file is required")
+					}
+					return firecracker.ImportKernel(kernelFile)
+				},
+			},
+			{
+				Name:  "snapshot",
+				Usage: "snapshot a running workload, so that it starts from the snapshot next time",
+				Description: `Examples:
+
+qubesome firecracker snapshot chrome                 - Snapshot the chrome workload on the active profile
+qubesome firecracker snapshot -delete chrome         - Delete the snapshot of the chrome workload
+qubesome firecracker snapshot -profile <p> chrome    - Snapshot the chrome workload on a specific profile
+`,
+				Arguments: []cli.Argument{
+					&cli.StringArg{
+						Name:        "workload",
+						Destination: &workload,
+					},
+				},
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:        "profile",
+						Destination: &targetProfile,
+					},
+					&cli.BoolFlag{
+						Name:        "delete",
+						Destination: &deleteSnapshot,
+						Usage:       "delete the snapshot instead of taking a new one",
+					},
+				},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					name, err := workloadName()
+					if err != nil {
+						return err
+					}
+					if deleteSnapshot {
+						return firecracker.DeleteSnapshot(name)
+					}
+					return firecracker.Snapshot(name)
+				},
+			},
+		},
+	}
+	return cmd
+}
+
+// workloadName returns the name of the effective workload, which
+// combines the workload and profile names.
+func workloadName() (string, error) {
+	if workload == "" {
+		return "", fmt.Errorf("workload name is required")
+	}
+
+	prof, err := profileOrActive(targetProfile)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", workload, prof.Name), nil
+}
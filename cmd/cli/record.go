@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/qubesome/cli/internal/capture"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	recordFPS      int
+	recordDuration time.Duration
+)
+
+func recordCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "record",
+		Usage: "record the display of a running profile",
+		Description: `Examples:
+
+qubesome record                          - Record the active profile until Ctrl+C
+qubesome record work -duration 30s       - Record the work profile for 30 seconds
+qubesome record work -o demo.webm        - Record the work profile into demo.webm
+
+Recordings are saved to ~/Videos/qubesome by default, and encoded by
+ffmpeg based on the output file extension.
+`,
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "profile",
+				Destination: &targetProfile,
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "file to save the recording to",
+				Destination: &captureOutput,
+			},
+			&cli.IntFlag{
+				Name:        "fps",
+				Value:       capture.DefaultFPS,
+				Usage:       "frames captured per second",
+				Destination: &recordFPS,
+			},
+			&cli.DurationFlag{
+				Name:        "duration",
+				Usage:       "stop recording after this long",
+				Destination: &recordDuration,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			prof, err := profileOrActive(targetProfile)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			fmt.Println("Recording, press Ctrl+C to stop...")
+			out, err := capture.Record(ctx, prof, captureOutput, recordFPS, recordDuration)
+			if out != "" {
+				fmt.Println(out)
+			}
+			return err
+		},
+	}
+	return cmd
+}
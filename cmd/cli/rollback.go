@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qubesome/cli/internal/profiles"
+	"github.com/urfave/cli/v3"
+)
+
+var history bool
+
+func rollbackCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "rollback",
+		Usage: "restart a profile at the previous git commit it was started from",
+		Description: `Examples:
+
+qubesome rollback i3                - Restart the i3 profile at its previous commit
+qubesome rollback -history i3       - Show the commits the i3 profile was started from
+`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "runner",
+				Destination: &runner,
+				Usage:       "sets what runner to be used, this will override the value set at the qubesome.config. Options: docker, podman or nerdctl.",
+			},
+			&cli.BoolFlag{
+				Name:        "history",
+				Destination: &history,
+				Usage:       "list the commits the profile was started from instead of rolling back",
+			},
+		},
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "profile",
+				Destination: &targetProfile,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			if targetProfile == "" {
+				return fmt.Errorf("profile name is required")
+			}
+
+			if history {
+				h, err := profiles.History(targetProfile)
+				if err != nil {
+					return err
+				}
+				for _, e := range h {
+					status := ""
+					if e.RolledBack {
+						status = " (rolled back)"
+					}
+					fmt.Printf("%s %s %s%s\n", e.Time.Format("2006-01-02 15:04:05"), e.Commit[:7], e.Ref, status)
+				}
+				return nil
+			}
+
+			if runner == "" {
+				if p, err := profileOrActive(targetProfile); err == nil {
+					runner = p.Runner
+				}
+			}
+
+			return profiles.Rollback(runner, targetProfile)
+		},
+	}
+	return cmd
+}
@@ -42,6 +42,16 @@ func RootCommand() *cli.Command {
 			hostRunCommand(),
 			flatpakCommand(),
 			headlessCommand(),
+			updateCommand(),
+			rollbackCommand(),
+			statusCommand(),
+			secretCommand(),
+			firecrackerCommand(),
+			stopCommand(),
+			displayCommand(),
+			attachCommand(),
+			screenshotCommand(),
+			recordCommand(),
 		},
 	}
 
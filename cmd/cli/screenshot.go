@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/qubesome/cli/internal/capture"
+	"github.com/urfave/cli/v3"
+)
+
+var captureOutput string
+
+func screenshotCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "screenshot",
+		Usage: "take a screenshot of the display of a running profile",
+		Description: `Examples:
+
+qubesome screenshot                       - Take a screenshot of the active profile
+qubesome screenshot work                  - Take a screenshot of the work profile
+qubesome screenshot work -o shot.png      - Save the screenshot of the work profile to shot.png
+
+Screenshots are saved to ~/Pictures/qubesome by default.
+`,
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "profile",
+				Destination: &targetProfile,
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "output",
+				Aliases:     []string{"o"},
+				Usage:       "PNG file to save the screenshot to",
+				Destination: &captureOutput,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			prof, err := profileOrActive(targetProfile)
+			if err != nil {
+				return err
+			}
+
+			out, err := capture.Screenshot(prof, captureOutput)
+			if err != nil {
+				return err
+			}
+			fmt.Println(out)
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/qubesome/cli/internal/secret"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	secretName string
+	host       string
+)
+
+func secretCommand() *cli.Command {
+	hostFlag := &cli.StringFlag{
+		Name:        "host",
+		Usage:       "git host the secret is used for",
+		Destination: &host,
+	}
+	nameArg := &cli.StringArg{
+		Name:        "name",
+		Destination: &secretName,
+	}
+
+	cmd := &cli.Command{
+		Name:  "secret",
+		Usage: "manage secrets stored in the qubesome keyring",
+		Commands: []*cli.Command{
+			{
+				Name:  "set",
+				Usage: "store a secret, reading its value from stdin",
+				Description: `Examples:
+
+qubesome secret set -host github.com git-token   - Set the token used for github.com
+`,
+				Flags:     []cli.Flag{hostFlag},
+				Arguments: []cli.Argument{nameArg},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return secret.Run(
+						secret.WithName(secretName),
+						secret.WithHost(host),
+					)
+				},
+			},
+			{
+				Name:      "delete",
+				Usage:     "delete a secret",
+				Flags:     []cli.Flag{hostFlag},
+				Arguments: []cli.Argument{nameArg},
+				Action: func(ctx context.Context, cmd *cli.Command) error {
+					return secret.Run(
+						secret.WithName(secretName),
+						secret.WithHost(host),
+						secret.WithDelete(),
+					)
+				},
+			},
+		},
+	}
+	return cmd
+}
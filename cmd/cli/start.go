@@ -12,7 +12,11 @@ import (
 	"github.com/urfave/cli/v3"
 )
 
-var detach bool
+var (
+	detach bool
+	ref    string
+	accept bool
+)
 
 func startCommand() *cli.Command {
 	cmd := &cli.Command{
@@ -23,6 +27,7 @@ func startCommand() *cli.Command {
 
 qubesome start -git https://github.com/qubesome/sample-dotfiles awesome
 qubesome start -git https://github.com/qubesome/sample-dotfiles i3
+qubesome start -git https://github.com/qubesome/sample-dotfiles -ref v0.1.0 i3
 `,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
@@ -40,10 +45,25 @@ qubesome start -git https://github.com/qubesome/sample-dotfiles i3
 				Usage:       "local is the local path for a git repository. This is to be used in combination with --git.",
 				Destination: &local,
 			},
+			&cli.StringFlag{
+				Name:        "ref",
+				Usage:       "git branch, tag or commit to checkout before starting the profile. This is to be used in combination with --git.",
+				Destination: &ref,
+			},
+			&cli.BoolFlag{
+				Name:        "require-signed",
+				Usage:       "refuse to use git commits that are not signed by a key in ~/.qubesome/allowed-signers.asc",
+				Destination: &requireSigned,
+			},
+			&cli.BoolFlag{
+				Name:        "accept",
+				Usage:       "accept new host access granted by the profile's workloads without confirmation. Without a terminal, new host access is refused unless this is set.",
+				Destination: &accept,
+			},
 			&cli.StringFlag{
 				Name:        "runner",
 				Destination: &runner,
-				Usage:       "sets what runner to be used, this will override the value set at the qubesome.config. Options: docker or podman.",
+				Usage:       "sets what runner to be used, this will override the value set at the qubesome.config. Options: docker, podman or nerdctl.",
 			},
 			&cli.BoolFlag{
 				Name:        "interactive",
@@ -100,12 +120,19 @@ qubesome start -git https://github.com/qubesome/sample-dotfiles i3
 				profiles.WithGitURL(gitURL),
 				profiles.WithPath(path),
 				profiles.WithLocal(local),
+				profiles.WithRef(ref),
 				profiles.WithRunner(runner),
 			}
 
 			if interactive {
 				opts = append(opts, profiles.WithInteractive())
 			}
+			if requireSigned {
+				opts = append(opts, profiles.WithRequireSigned())
+			}
+			if accept {
+				opts = append(opts, profiles.WithAccept())
+			}
 
 			return profiles.Run(opts...)
 		},
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/qubesome/cli/internal/profiles"
+	"github.com/qubesome/cli/internal/runners/firecracker"
+	"github.com/urfave/cli/v3"
+)
+
+func statusCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "status",
+		Usage: "show the active profiles, the git commits they were started from and running firecracker workloads",
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			active := activeProfiles()
+			if len(active) == 0 {
+				fmt.Println("No active profiles")
+				return vmStatus()
+			}
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PROFILE\tCOMMIT\tREF\tSIGNER\tGIT")
+			for _, name := range active {
+				h, err := profiles.History(name)
+				if err != nil {
+					return err
+				}
+				if len(h) == 0 {
+					fmt.Fprintf(w, "%s\t-\t-\t-\t-\n", name)
+					continue
+				}
+
+				e := h[0]
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, e.Commit[:7], orDash(e.Ref), orDash(e.Signer), e.GitURL)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			return vmStatus()
+		},
+	}
+	return cmd
+}
+
+func vmStatus() error {
+	vms, err := firecracker.VMs()
+	if err != nil || len(vms) == 0 {
+		return err
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FIRECRACKER WORKLOAD\tSTATE\tVERSION")
+	for _, vm := range vms {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", vm.Name, vm.State, vm.Version)
+	}
+	return w.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/qubesome/cli/internal/profiles"
+	"github.com/qubesome/cli/internal/runners/firecracker"
+	"github.com/urfave/cli/v3"
+)
+
+func stopCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:  "stop",
+		Usage: "gracefully stop a running profile or firecracker workload",
+		Description: `Examples:
+
+qubesome stop                               - Stop the active profile and its firecracker workloads
+qubesome stop -profile <profile>            - Stop a specific profile and its firecracker workloads
+qubesome stop chrome                        - Stop the chrome firecracker workload on the active profile
+qubesome stop -profile <profile> chrome     - Stop the chrome firecracker workload on a specific profile
+`,
+		Arguments: []cli.Argument{
+			&cli.StringArg{
+				Name:        "workload",
+				Destination: &workload,
+			},
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "profile",
+				Destination: &targetProfile,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			if workload == "" {
+				prof, err := profileOrActive(targetProfile)
+				if err != nil {
+					return err
+				}
+				return profiles.Stop(prof.Runner, prof.Name)
+			}
+
+			name, err := workloadName()
+			if err != nil {
+				return err
+			}
+			return firecracker.Stop(name)
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/qubesome/cli/internal/command"
+	"github.com/qubesome/cli/internal/update"
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	restart       bool
+	requireSigned bool
+)
+
+func updateCommand() *cli.Command {
+	cmd := &cli.Command{
+		Name:    "update",
+		Aliases: []string{"u"},
+		Usage:   "fetch and fast-forward the git repository backing qubesome profiles",
+		Description: `Examples:
+
+qubesome update -profile i3                                          - Update the repository the active i3 profile was started from
+qubesome update -git https://github.com/qubesome/sample-dotfiles     - Update a previously cloned repository
+qubesome update -profile i3 -restart                                 - Update and restart the active profiles affected by the changes
+`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "profile",
+				Usage:       "active profile whose git repository should be updated",
+				Destination: &targetProfile,
+			},
+			&cli.StringFlag{
+				Name:        "git",
+				Usage:       "git repository URL",
+				Destination: &gitURL,
+			},
+			&cli.StringFlag{
+				Name:        "path",
+				Usage:       "rel path (based on -git) to the dir containing the qubesome.config",
+				Destination: &path,
+			},
+			&cli.BoolFlag{
+				Name:        "restart",
+				Usage:       "restart active profiles affected by the update",
+				Destination: &restart,
+			},
+			&cli.BoolFlag{
+				Name:        "require-signed",
+				Usage:       "refuse to use git commits that are not signed by a key in ~/.qubesome/allowed-signers.asc",
+				Destination: &requireSigned,
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			opts := []command.Option[update.Options]{
+				update.WithProfile(targetProfile),
+				update.WithGitURL(gitURL),
+				update.WithPath(path),
+			}
+
+			if restart {
+				opts = append(opts, update.WithRestart())
+			}
+			if requireSigned {
+				opts = append(opts, update.WithRequireSigned())
+			}
+
+			return update.Run(opts...)
+		},
+	}
+	return cmd
+}
@@ -6,9 +6,15 @@ import (
 	"os"
 
 	"github.com/qubesome/cli/cmd/cli"
+	"github.com/qubesome/cli/internal/runners/firecracker/guest"
 )
 
 func main() {
+	// Within firecracker VMs, qubesome runs as the guest init.
+	if guest.IsInit() {
+		guest.Main()
+	}
+
 	cmd := cli.RootCommand()
 
 	if err := cmd.Run(context.Background(), os.Args); err != nil {
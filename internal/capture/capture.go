@@ -0,0 +1,203 @@
+// Package capture takes screenshots and recordings of profile displays,
+// talking to their X server directly with the profile's server cookie.
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/x11"
+	"github.com/qubesome/cli/internal/util/xauth"
+	"golang.org/x/sys/execabs"
+)
+
+const DefaultFPS = 10
+
+var (
+	ErrInvalidFPS     = errors.New("fps must be between 1 and 60")
+	ErrNoServerCookie = errors.New("no server cookie found")
+
+	now = time.Now
+)
+
+// Screenshot writes a PNG of the display of profile to out, or to the
+// screenshot dir when out is empty, and returns the path written to.
+func Screenshot(profile *types.Profile, out string) (string, error) {
+	out, err := outputPath(out, files.ScreenshotDir(), profile.Name, ".png")
+	if err != nil {
+		return "", err
+	}
+
+	c, err := dial(profile)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	img, err := c.CaptureScreen()
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, files.FileMode)
+	if err != nil {
+		return "", fmt.Errorf("cannot create screenshot: %w", err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		return "", fmt.Errorf("cannot encode screenshot: %w", err)
+	}
+	return out, f.Close()
+}
+
+// Record captures the display of profile at fps frames per second until
+// ctx is done or, when set, until d elapses. Frames are encoded by ffmpeg
+// into out, or into the recording dir when out is empty, and the path
+// written to is returned.
+func Record(ctx context.Context, profile *types.Profile, out string, fps int, d time.Duration) (string, error) {
+	if fps < 1 || fps > 60 {
+		return "", ErrInvalidFPS
+	}
+
+	out, err := outputPath(out, files.RecordingDir(), profile.Name, ".mp4")
+	if err != nil {
+		return "", err
+	}
+
+	c, err := dial(profile)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	// The frame size is fixed for the whole recording, so a display
+	// resized while recording ends it.
+	w, h, err := c.Size()
+	if err != nil {
+		return "", err
+	}
+	r := image.Rect(0, 0, w, h)
+
+	args := ffmpegArgs(out, w, h, fps)
+	slog.Debug("recording display", "profile", profile.Name, "command", files.FfmpegBinary, "args", args)
+
+	cmd := execabs.Command(files.FfmpegBinary, args...)
+	// Keep ffmpeg out of the terminal's process group, so that Ctrl+C
+	// stops the recording through ctx and ffmpeg can finish the file.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return "", err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("cannot start ffmpeg: %w", err)
+	}
+
+	if d > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	recErr := record(ctx, c, r, stdin, time.Second/time.Duration(fps))
+	stdin.Close()
+
+	if err := cmd.Wait(); err != nil {
+		return "", fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if recErr != nil {
+		return out, fmt.Errorf("recording stopped: %w", recErr)
+	}
+	return out, nil
+}
+
+func record(ctx context.Context, c *x11.Conn, r image.Rectangle, w io.Writer, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		img, err := c.Capture(r)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(img.Pix); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func ffmpegArgs(out string, w, h, fps int) []string {
+	args := []string{
+		"-hide_banner", "-loglevel", "error", "-y",
+		"-f", "rawvideo", "-pixel_format", "rgba",
+		"-video_size", fmt.Sprintf("%dx%d", w, h),
+		"-framerate", strconv.Itoa(fps),
+		"-i", "-",
+	}
+	if !strings.EqualFold(filepath.Ext(out), ".gif") {
+		// Most players require yuv420p, which needs even dimensions.
+		args = append(args, "-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2", "-pix_fmt", "yuv420p")
+	}
+	return append(args, out)
+}
+
+// outputPath returns out, or a timestamped file for profile within dir
+// when out is empty, ensuring its parent dir exists.
+func outputPath(out, dir, profile, ext string) (string, error) {
+	if out == "" {
+		name := fmt.Sprintf("%s-%s%s", profile, now().Format("20060102-150405"), ext)
+		out = filepath.Join(dir, name)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), files.DirMode); err != nil {
+		return "", fmt.Errorf("cannot create output dir: %w", err)
+	}
+	return out, nil
+}
+
+func dial(profile *types.Profile) (*x11.Conn, error) {
+	path, err := files.ServerCookiePath(profile.Name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get X magic cookie path: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open X magic cookie: %w", err)
+	}
+	defer f.Close()
+
+	entries, err := xauth.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+	e, ok := xauth.Find(entries, profile.Display)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoServerCookie, path)
+	}
+
+	return x11.Dial(profile.Display, e.Name, e.Data)
+}
@@ -0,0 +1,62 @@
+package capture
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputPath(t *testing.T) {
+	old := now
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	t.Cleanup(func() { now = old })
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name string
+		out  string
+		want string
+	}{
+		{
+			name: "default",
+			want: filepath.Join(dir, "pictures", "work-20260102-030405.png"),
+		},
+		{
+			name: "explicit",
+			out:  filepath.Join(dir, "shots", "a.png"),
+			want: filepath.Join(dir, "shots", "a.png"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := outputPath(tc.out, filepath.Join(dir, "pictures"), "work", ".png")
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.DirExists(t, filepath.Dir(got))
+		})
+	}
+}
+
+func TestFfmpegArgs(t *testing.T) {
+	args := ffmpegArgs("/tmp/a.mp4", 1920, 1080, 10)
+	assert.Subset(t, args, []string{"-video_size", "1920x1080", "-framerate", "10", "-pix_fmt", "yuv420p"})
+	assert.Equal(t, "/tmp/a.mp4", args[len(args)-1])
+
+	args = ffmpegArgs("/tmp/a.GIF", 1920, 1080, 10)
+	assert.NotContains(t, args, "-pix_fmt")
+}
+
+func TestRecordInvalidFPS(t *testing.T) {
+	p := &types.Profile{Name: "work"}
+	for _, fps := range []int{0, -1, 61} {
+		_, err := Record(context.Background(), p, "", fps, 0)
+		assert.ErrorIs(t, err, ErrInvalidFPS)
+	}
+}
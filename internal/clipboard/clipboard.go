@@ -4,15 +4,21 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/qubesome/cli/internal/command"
-	"github.com/qubesome/cli/internal/files"
-	"golang.org/x/sys/execabs"
+	"github.com/qubesome/cli/internal/types"
 )
 
 var (
 	ErrUnsupportedCopyType                  = errors.New("unsupported copy type")
+	ErrNoAllowedTarget                      = errors.New("clipboard has no allowed content type")
 	ErrCannotCopyClipboardWithinSameDisplay = errors.New("cannot copy clipboard within the same display")
+
+	targetsFunc = targets
+	readFunc    = read
+	serveFunc   = serveDetached
 )
 
 func Run(opts ...command.Option[Options]) error {
@@ -22,11 +28,9 @@ func Run(opts ...command.Option[Options]) error {
 	}
 
 	var from, target uint8
-	var profile string
 
 	if o.SourceProfile != nil {
 		from = o.SourceProfile.Display
-		profile = o.SourceProfile.Name
 	}
 
 	if o.TargetProfile == nil && !o.ToHost {
@@ -35,41 +39,80 @@ func Run(opts ...command.Option[Options]) error {
 
 	if o.TargetProfile != nil {
 		target = o.TargetProfile.Display
-		profile = o.TargetProfile.Name
 	}
 
 	if from == target {
 		return ErrCannotCopyClipboardWithinSameDisplay
 	}
 
-	if !validTarget(o.ContentType) {
-		return fmt.Errorf("%w: %s", ErrUnsupportedCopyType, o.ContentType)
+	src, err := profileDisplay(o.SourceProfile)
+	if err != nil {
+		return err
+	}
+	dst, err := profileDisplay(o.TargetProfile)
+	if err != nil {
+		return err
 	}
 
-	targetExtra := ""
-	if o.ContentType != "" {
-		targetExtra = fmt.Sprintf("-t %s", o.ContentType)
+	allowed := allowedTargets(o.SourceProfile, o.TargetProfile)
+	contentType := o.ContentType
+	if contentType != "" {
+		if !slices.Contains(allowed, contentType) {
+			return fmt.Errorf("%w: %s", ErrUnsupportedCopyType, contentType)
+		}
+	} else {
+		offered, err := targetsFunc(src)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		contentType, ok = pick(offered, allowed)
+		if !ok {
+			return fmt.Errorf("%w: offered %s", ErrNoAllowedTarget, strings.Join(offered, ", "))
+		}
+		slog.Debug("clipboard target picked", "target", contentType, "offered", offered)
 	}
 
-	cookiePath, err := files.ServerCookiePath(profile)
+	data, err := readFunc(src, contentType)
 	if err != nil {
-		return fmt.Errorf("cannot get X magic cookie path: %w", err)
+		return err
 	}
 
-	xclip := fmt.Sprintf("%s -selection clip -o -display :%d | XAUTHORITY=%s %s -selection clip %s -i -display :%d",
-		files.XclipBinary, int(from), cookiePath, files.XclipBinary, targetExtra, int(target))
-
-	slog.Debug("clipboard copy", "command", []string{files.ShBinary, "-c", xclip})
-	cmd := execabs.Command(files.ShBinary, "-c", xclip) //nolint
+	slog.Debug("clipboard copy", "from", from, "to", target, "target", contentType, "size", len(data))
+	return serveFunc(dst, contentType, data)
+}
 
-	err = cmd.Run()
-	if err != nil {
-		return fmt.Errorf("failed to copy clipboard: %w", err)
+// allowedTargets returns the targets allowed by both profiles, in order
+// of preference of the source. The host does not restrict targets.
+func allowedTargets(source, target *types.Profile) []string {
+	var allowed []string
+	switch {
+	case source != nil:
+		allowed = source.ClipboardTargets()
+	case target != nil:
+		allowed = target.ClipboardTargets()
 	}
 
-	return nil
+	var both []string
+	for _, t := range allowed {
+		if !types.ValidClipboardTarget(t) {
+			continue
+		}
+		if target == nil || target.AllowsClipboardTarget(t) {
+			both = append(both, t)
+		}
+	}
+	return both
 }
 
-func validTarget(target string) bool {
-	return (target == "" || target == "image/png")
+// pick returns the most preferred of the allowed targets which is
+// offered by the clipboard owner.
+func pick(offered, allowed []string) (string, bool) {
+	for _, t := range allowed {
+		if slices.Contains(offered, t) {
+			return t, true
+		}
+	}
+	return "", false
 }
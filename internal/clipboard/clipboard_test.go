@@ -6,6 +6,7 @@ import (
 	"github.com/qubesome/cli/internal/command"
 	"github.com/qubesome/cli/internal/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCopy(t *testing.T) {
@@ -51,3 +52,111 @@ func TestCopy(t *testing.T) {
 		})
 	}
 }
+
+func TestRunNoAllowedTarget(t *testing.T) {
+	old := targetsFunc
+	targetsFunc = func(Display) ([]string, error) {
+		return []string{"TARGETS", "TIMESTAMP", "application/x-qt-image"}, nil
+	}
+	t.Cleanup(func() { targetsFunc = old })
+
+	err := Run(
+		WithSourceProfile(&types.Profile{Display: 1}),
+		WithTargetProfile(&types.Profile{Display: 2}),
+	)
+	assert.ErrorIs(t, err, ErrNoAllowedTarget)
+}
+
+func TestRunPicksTarget(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+	t.Setenv("XAUTHORITY", "/tmp/host.xauth")
+
+	oldTargets, oldRead, oldServe := targetsFunc, readFunc, serveFunc
+	t.Cleanup(func() { targetsFunc, readFunc, serveFunc = oldTargets, oldRead, oldServe })
+
+	var read, served Display
+	var servedTarget string
+	targetsFunc = func(Display) ([]string, error) {
+		return []string{"TARGETS", "text/html", "UTF8_STRING"}, nil
+	}
+	readFunc = func(d Display, target string) ([]byte, error) {
+		read = d
+		return []byte(target), nil
+	}
+	serveFunc = func(d Display, target string, data []byte) error {
+		served, servedTarget = d, target
+		assert.Equal(t, target, string(data))
+		return nil
+	}
+
+	err := Run(
+		WithSourceProfile(&types.Profile{Name: "work", Display: 1}),
+		WithTargetHost(),
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, Display{Number: 1, Authority: "/home/user/.qubesome/run/work/.Xserver-cookie"}, read)
+	assert.Equal(t, Display{Number: 0, Authority: "/tmp/host.xauth"}, served)
+	assert.Equal(t, "UTF8_STRING", servedTarget)
+}
+
+func TestAllowedTargets(t *testing.T) {
+	textOnly := &types.Profile{Clipboard: &types.Clipboard{Targets: []string{"UTF8_STRING", "text/html"}}}
+	svg := &types.Profile{Clipboard: &types.Clipboard{Targets: []string{"image/svg+xml", "image/png"}}}
+
+	tests := []struct {
+		name   string
+		source *types.Profile
+		target *types.Profile
+		want   []string
+	}{
+		{
+			name:   "defaults",
+			source: &types.Profile{},
+			target: &types.Profile{},
+			want:   types.DefaultClipboardTargets,
+		},
+		{
+			name:   "to host",
+			source: textOnly,
+			want:   []string{"UTF8_STRING", "text/html"},
+		},
+		{
+			name:   "from host",
+			target: svg,
+			want:   []string{"image/svg+xml", "image/png"},
+		},
+		{
+			name:   "intersection",
+			source: &types.Profile{},
+			target: textOnly,
+			want:   []string{"UTF8_STRING", "text/html"},
+		},
+		{
+			name:   "nothing in common",
+			source: textOnly,
+			target: svg,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, allowedTargets(tc.source, tc.target))
+		})
+	}
+}
+
+func TestPick(t *testing.T) {
+	offered := []string{"TIMESTAMP", "TARGETS", "text/html", "UTF8_STRING", "image/png"}
+
+	got, ok := pick(offered, types.DefaultClipboardTargets)
+	assert.True(t, ok)
+	assert.Equal(t, "image/png", got)
+
+	got, ok = pick(offered, []string{"text/uri-list", "text/html", "UTF8_STRING"})
+	assert.True(t, ok)
+	assert.Equal(t, "text/html", got)
+
+	_, ok = pick(offered, []string{"image/jpeg"})
+	assert.False(t, ok)
+}
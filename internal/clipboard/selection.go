@@ -0,0 +1,387 @@
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/x11"
+	"github.com/qubesome/cli/internal/util/xauth"
+)
+
+const (
+	// maxSize caps the clipboard contents read from a display.
+	maxSize = 128 << 20
+	// timeout is how long the clipboard owner has to reply.
+	timeout = 5 * time.Second
+
+	selectionProperty = "QUBESOME_SELECTION"
+)
+
+var (
+	ErrEmptyClipboard    = errors.New("clipboard is empty")
+	ErrClipboardTooBig   = errors.New("clipboard contents too big")
+	ErrNotClipboardOwner = errors.New("cannot become the clipboard owner")
+)
+
+var dialX11 = x11.Dial
+
+// utf8Targets are targets holding the same UTF-8 text, so that any of
+// them is served when one is copied.
+var utf8Targets = []string{"UTF8_STRING", "text/plain;charset=utf-8"}
+
+// Display is an X display and the Xauthority file used to connect to it.
+type Display struct {
+	Number    uint8
+	Authority string
+}
+
+// profileDisplay returns the display of profile, or the host display
+// when profile is nil.
+func profileDisplay(profile *types.Profile) (Display, error) {
+	if profile == nil {
+		auth := os.Getenv("XAUTHORITY")
+		if auth == "" {
+			auth = os.ExpandEnv("${HOME}/.Xauthority")
+		}
+		return Display{Authority: auth}, nil
+	}
+
+	auth, err := files.ServerCookiePath(profile.Name)
+	if err != nil {
+		return Display{}, fmt.Errorf("cannot get X magic cookie path: %w", err)
+	}
+	return Display{Number: profile.Display, Authority: auth}, nil
+}
+
+func dial(d Display) (*x11.Conn, error) {
+	var name string
+	var data []byte
+
+	if f, err := os.Open(d.Authority); err == nil {
+		entries, err := xauth.Parse(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if e, ok := xauth.Find(entries, d.Number); ok {
+			name, data = e.Name, e.Data
+		}
+	} else {
+		slog.Debug("connecting without Xauthority", "display", d.Number, "error", err)
+	}
+
+	return dialX11(d.Number, name, data)
+}
+
+// requestor reads the clipboard of a display.
+type requestor struct {
+	c         *x11.Conn
+	window    uint32
+	clipboard uint32
+	property  uint32
+	incr      uint32
+}
+
+func newRequestor(c *x11.Conn) (*requestor, error) {
+	w, err := c.CreateWindow(x11.PropertyChangeMask)
+	if err != nil {
+		return nil, err
+	}
+	r := &requestor{c: c, window: w}
+
+	for name, atom := range map[string]*uint32{
+		"CLIPBOARD":       &r.clipboard,
+		"INCR":            &r.incr,
+		selectionProperty: &r.property,
+	} {
+		if *atom, err = c.InternAtom(name); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// targets returns the targets offered by the clipboard owner.
+func (r *requestor) targets() ([]string, error) {
+	atom, err := r.c.InternAtom("TARGETS")
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.convert(atom)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, a := range p.Atoms() {
+		name, err := r.c.AtomName(a)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// read returns the clipboard contents as target.
+func (r *requestor) read(target string) ([]byte, error) {
+	atom, err := r.c.InternAtom(target)
+	if err != nil {
+		return nil, err
+	}
+	p, err := r.convert(atom)
+	if err != nil {
+		return nil, err
+	}
+	if p.Type != r.incr {
+		return p.Value, nil
+	}
+
+	// The owner sends the contents in chunks, each written once the
+	// previous one was deleted, finishing with an empty one.
+	var data []byte
+	for {
+		_, err := r.wait(func(ev []byte) bool {
+			if ev[0]&0x7f != x11.PropertyNotify {
+				return false
+			}
+			pn := x11.ParsePropertyNotify(ev)
+			return pn.Window == r.window && pn.Atom == r.property && pn.State == x11.PropertyNewValue
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := r.c.GetProperty(r.window, r.property, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(p.Value) == 0 {
+			return data, nil
+		}
+		if len(data)+len(p.Value) > maxSize {
+			return nil, ErrClipboardTooBig
+		}
+		data = append(data, p.Value...)
+	}
+}
+
+// convert asks the clipboard owner for target, returning the property
+// it was stored in.
+func (r *requestor) convert(target uint32) (x11.Property, error) {
+	if err := r.c.ConvertSelection(r.window, r.clipboard, target, r.property); err != nil {
+		return x11.Property{}, err
+	}
+
+	ev, err := r.wait(func(ev []byte) bool {
+		return ev[0]&0x7f == x11.SelectionNotify && x11.ParseSelectionNotify(ev).Requestor == r.window
+	})
+	if err != nil {
+		return x11.Property{}, err
+	}
+	if x11.ParseSelectionNotify(ev).Property == x11.None {
+		return x11.Property{}, ErrEmptyClipboard
+	}
+
+	p, err := r.c.GetProperty(r.window, r.property, true)
+	if err != nil {
+		return x11.Property{}, err
+	}
+	if len(p.Value) > maxSize {
+		return x11.Property{}, ErrClipboardTooBig
+	}
+	return p, nil
+}
+
+// wait returns the next event matching match, skipping any others.
+func (r *requestor) wait(match func([]byte) bool) ([]byte, error) {
+	if err := r.c.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer r.c.SetDeadline(time.Time{}) //nolint
+
+	for {
+		ev, err := r.c.NextEvent()
+		if err != nil {
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				return nil, errors.New("timed out waiting for the clipboard owner")
+			}
+			return nil, err
+		}
+		if match(ev) {
+			return ev, nil
+		}
+	}
+}
+
+// targets returns the targets offered by the clipboard owner of d.
+func targets(d Display) ([]string, error) {
+	c, err := dial(d)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	r, err := newRequestor(c)
+	if err != nil {
+		return nil, err
+	}
+	ts, err := r.targets()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get clipboard targets: %w", err)
+	}
+	return ts, nil
+}
+
+// read returns the clipboard contents of d as target.
+func read(d Display, target string) ([]byte, error) {
+	c, err := dial(d)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	r, err := newRequestor(c)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.read(target)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read clipboard: %w", err)
+	}
+	return data, nil
+}
+
+type transferKey struct {
+	requestor uint32
+	property  uint32
+}
+
+type transfer struct {
+	target uint32
+	data   []byte
+}
+
+// Serve owns the clipboard of d, serving data as target until another
+// client takes ownership or the display exits. ready is called once the
+// clipboard is owned.
+func Serve(d Display, target string, data []byte, ready func()) error {
+	c, err := dial(d)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	w, err := c.CreateWindow(0)
+	if err != nil {
+		return err
+	}
+
+	names := []string{target}
+	if slices.Contains(utf8Targets, target) {
+		names = utf8Targets
+	}
+
+	atoms := map[string]uint32{}
+	for _, name := range append([]string{"CLIPBOARD", "TARGETS", "INCR"}, names...) {
+		if atoms[name], err = c.InternAtom(name); err != nil {
+			return err
+		}
+	}
+	served := []uint32{atoms["TARGETS"]}
+	for _, name := range names {
+		served = append(served, atoms[name])
+	}
+
+	if err := c.SetSelectionOwner(w, atoms["CLIPBOARD"]); err != nil {
+		return err
+	}
+	if owner, err := c.SelectionOwner(atoms["CLIPBOARD"]); err != nil {
+		return err
+	} else if owner != w {
+		return ErrNotClipboardOwner
+	}
+	ready()
+
+	chunk := c.MaxPropertySize()
+	transfers := map[transferKey]*transfer{}
+
+	for {
+		ev, err := c.NextEvent()
+		if err != nil {
+			var xerr *x11.Error
+			if errors.As(err, &xerr) {
+				// Requestors may go away mid transfer.
+				slog.Debug("clipboard request failed", "error", err)
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		switch ev[0] & 0x7f {
+		case x11.SelectionClear:
+			if x11.ParseSelectionClear(ev).Owner == w {
+				return nil
+			}
+
+		case x11.SelectionRequest:
+			req := x11.ParseSelectionRequest(ev)
+			prop := req.Property
+			if prop == x11.None {
+				prop = req.Target
+			}
+
+			switch {
+			case req.Selection != atoms["CLIPBOARD"] || !slices.Contains(served, req.Target):
+				prop = x11.None
+			case req.Target == atoms["TARGETS"]:
+				err = c.ChangeProperty(req.Requestor, prop, x11.AtomAtom, 32, x11.AtomsValue(served...))
+			case len(data) <= chunk:
+				err = c.ChangeProperty(req.Requestor, prop, req.Target, 8, data)
+			default:
+				transfers[transferKey{req.Requestor, prop}] = &transfer{target: req.Target, data: data}
+				if err = c.SelectInput(req.Requestor, x11.PropertyChangeMask); err == nil {
+					err = c.ChangeProperty(req.Requestor, prop, atoms["INCR"], 32, x11.AtomsValue(uint32(len(data))))
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if err := c.SendSelectionNotify(req, prop); err != nil {
+				return err
+			}
+
+		case x11.PropertyNotify:
+			pn := x11.ParsePropertyNotify(ev)
+			key := transferKey{pn.Window, pn.Atom}
+			t, ok := transfers[key]
+			if !ok || pn.State != x11.PropertyDelete {
+				continue
+			}
+
+			n := min(chunk, len(t.data))
+			if err := c.ChangeProperty(pn.Window, pn.Atom, t.target, 8, t.data[:n]); err != nil {
+				return err
+			}
+			if n == 0 {
+				delete(transfers, key)
+				if err := c.SelectInput(pn.Window, 0); err != nil {
+					return err
+				}
+			}
+			t.data = t.data[n:]
+		}
+	}
+}
@@ -0,0 +1,361 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qubesome/cli/internal/util/x11"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var le = binary.LittleEndian
+
+// fakeX is an in-memory X server implementing the requests used to
+// transfer selections, with a small max request size so that large
+// transfers use INCR.
+type fakeX struct {
+	mu         sync.Mutex
+	clients    int
+	atoms      map[string]uint32
+	names      map[uint32]string
+	windows    map[uint32]*fakeWindow
+	selections map[uint32]uint32
+}
+
+type fakeWindow struct {
+	client *fakeClient
+	props  map[uint32]fakeProp
+	masks  map[*fakeClient]uint32
+}
+
+type fakeProp struct {
+	typ    uint32
+	format uint8
+	data   []byte
+}
+
+type fakeClient struct {
+	conn net.Conn
+	mu   sync.Mutex
+	seq  uint16
+}
+
+func (c *fakeClient) write(b []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	le.PutUint16(b[2:], c.seq)
+	_, _ = c.conn.Write(b)
+}
+
+func newFakeX(t *testing.T) string {
+	t.Helper()
+
+	sock := filepath.Join(t.TempDir(), "X")
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	x := &fakeX{
+		atoms:      map[string]uint32{"ATOM": x11.AtomAtom},
+		names:      map[uint32]string{x11.AtomAtom: "ATOM"},
+		windows:    map[uint32]*fakeWindow{},
+		selections: map[uint32]uint32{},
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			x.mu.Lock()
+			x.clients++
+			id := x.clients
+			x.mu.Unlock()
+			go x.serve(&fakeClient{conn: c}, uint32(id)<<21)
+		}
+	}()
+	return sock
+}
+
+func (x *fakeX) serve(c *fakeClient, idBase uint32) {
+	defer c.conn.Close()
+
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(c.conn, hdr); err != nil {
+		return
+	}
+	auth := make([]byte, pad(int(le.Uint16(hdr[6:])))+pad(int(le.Uint16(hdr[8:]))))
+	if _, err := io.ReadFull(c.conn, auth); err != nil {
+		return
+	}
+	_, _ = c.conn.Write(fakeSetup(idBase))
+
+	for {
+		req := make([]byte, 4)
+		if _, err := io.ReadFull(c.conn, req); err != nil {
+			return
+		}
+		body := make([]byte, int(le.Uint16(req[2:]))*4-4)
+		if _, err := io.ReadFull(c.conn, body); err != nil {
+			return
+		}
+		req = append(req, body...)
+
+		c.mu.Lock()
+		c.seq++
+		c.mu.Unlock()
+
+		x.mu.Lock()
+		x.handle(c, req)
+		x.mu.Unlock()
+	}
+}
+
+func (x *fakeX) handle(c *fakeClient, req []byte) {
+	reply := make([]byte, 32)
+	reply[0] = 1
+
+	switch req[0] {
+	case 1: // CreateWindow
+		w := &fakeWindow{client: c, props: map[uint32]fakeProp{}, masks: map[*fakeClient]uint32{}}
+		if le.Uint32(req[28:])&0x800 != 0 {
+			w.masks[c] = le.Uint32(req[32:])
+		}
+		x.windows[le.Uint32(req[4:])] = w
+		return
+	case 2: // ChangeWindowAttributes
+		if w, ok := x.windows[le.Uint32(req[4:])]; ok {
+			w.masks[c] = le.Uint32(req[12:])
+		}
+		return
+	case 16: // InternAtom
+		name := string(req[8 : 8+le.Uint16(req[4:])])
+		a, ok := x.atoms[name]
+		if !ok {
+			a = uint32(100 + len(x.atoms))
+			x.atoms[name] = a
+			x.names[a] = name
+		}
+		le.PutUint32(reply[8:], a)
+	case 17: // GetAtomName
+		name := x.names[le.Uint32(req[4:])]
+		le.PutUint16(reply[8:], uint16(len(name)))
+		le.PutUint32(reply[4:], uint32(pad(len(name))/4))
+		reply = append(reply, make([]byte, pad(len(name)))...)
+		copy(reply[32:], name)
+	case 18: // ChangeProperty
+		w := x.windows[le.Uint32(req[4:])]
+		format := req[16]
+		n := int(le.Uint32(req[20:])) * int(format/8)
+		prop := le.Uint32(req[8:])
+		w.props[prop] = fakeProp{typ: le.Uint32(req[12:]), format: format, data: bytes.Clone(req[24 : 24+n])}
+		x.propertyNotify(le.Uint32(req[4:]), w, prop, x11.PropertyNewValue)
+		return
+	case 20: // GetProperty
+		w := x.windows[le.Uint32(req[4:])]
+		prop := le.Uint32(req[8:])
+		p, ok := w.props[prop]
+		if ok {
+			reply[1] = p.format
+			le.PutUint32(reply[8:], p.typ)
+			le.PutUint32(reply[16:], uint32(len(p.data)/int(p.format/8)))
+			le.PutUint32(reply[4:], uint32(pad(len(p.data))/4))
+			reply = append(reply, make([]byte, pad(len(p.data)))...)
+			copy(reply[32:], p.data)
+			if req[1] == 1 {
+				delete(w.props, prop)
+				defer x.propertyNotify(le.Uint32(req[4:]), w, prop, x11.PropertyDelete)
+			}
+		}
+	case 22: // SetSelectionOwner
+		owner, sel := le.Uint32(req[4:]), le.Uint32(req[8:])
+		if prev, ok := x.selections[sel]; ok && prev != owner {
+			ev := make([]byte, 32)
+			ev[0] = x11.SelectionClear
+			le.PutUint32(ev[8:], prev)
+			le.PutUint32(ev[12:], sel)
+			x.windows[prev].client.write(ev)
+		}
+		x.selections[sel] = owner
+		return
+	case 23: // GetSelectionOwner
+		le.PutUint32(reply[8:], x.selections[le.Uint32(req[4:])])
+	case 24: // ConvertSelection
+		requestor, sel := le.Uint32(req[4:]), le.Uint32(req[8:])
+		ev := make([]byte, 32)
+		if owner, ok := x.selections[sel]; ok {
+			ev[0] = x11.SelectionRequest
+			le.PutUint32(ev[8:], owner)
+			copy(ev[12:28], req[4:20])
+			x.windows[owner].client.write(ev)
+		} else {
+			ev[0] = x11.SelectionNotify
+			copy(ev[8:20], req[4:16])
+			x.windows[requestor].client.write(ev)
+		}
+		return
+	case 25: // SendEvent
+		ev := bytes.Clone(req[12:44])
+		ev[0] |= 0x80
+		x.windows[le.Uint32(req[4:])].client.write(ev)
+		return
+	default:
+		reply = make([]byte, 32)
+		reply[1] = 1
+		reply[10] = req[0]
+	}
+	c.write(reply)
+}
+
+func (x *fakeX) propertyNotify(window uint32, w *fakeWindow, prop uint32, state uint8) {
+	for c, mask := range w.masks {
+		if mask&x11.PropertyChangeMask == 0 {
+			continue
+		}
+		ev := make([]byte, 32)
+		ev[0] = x11.PropertyNotify
+		le.PutUint32(ev[4:], window)
+		le.PutUint32(ev[8:], prop)
+		ev[16] = state
+		c.write(ev)
+	}
+}
+
+func fakeSetup(idBase uint32) []byte {
+	body := make([]byte, 32)
+	le.PutUint32(body[4:], idBase)
+	le.PutUint32(body[8:], 0x1fffff)
+	le.PutUint16(body[18:], 1024)
+	body[20] = 1
+	body = append(body, make([]byte, 40)...)
+	le.PutUint32(body[32:], 1)
+
+	hdr := make([]byte, 8)
+	hdr[0] = 1
+	le.PutUint16(hdr[6:], uint16(len(body)/4))
+	return append(hdr, body...)
+}
+
+func pad(n int) int {
+	return (n + 3) &^ 3
+}
+
+func serveInBackground(t *testing.T, target string, data []byte) <-chan error {
+	t.Helper()
+
+	ready := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(Display{}, target, data, func() { close(ready) })
+	}()
+
+	select {
+	case <-ready:
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for clipboard ownership")
+	}
+	return done
+}
+
+func TestSelectionTransfer(t *testing.T) {
+	sock := newFakeX(t)
+	old := dialX11
+	dialX11 = func(_ uint8, name string, data []byte) (*x11.Conn, error) {
+		c, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, err
+		}
+		return x11.NewConn(c, name, data)
+	}
+	t.Cleanup(func() { dialX11 = old })
+
+	_, err := read(Display{}, "UTF8_STRING")
+	require.ErrorIs(t, err, ErrEmptyClipboard)
+
+	text := []byte("hello from qubesome")
+	first := serveInBackground(t, "UTF8_STRING", text)
+
+	offered, err := targets(Display{})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"TARGETS", "UTF8_STRING", "text/plain;charset=utf-8"}, offered)
+
+	got, err := read(Display{}, "text/plain;charset=utf-8")
+	require.NoError(t, err)
+	assert.Equal(t, text, got)
+
+	_, err = read(Display{}, "image/png")
+	require.ErrorIs(t, err, ErrEmptyClipboard)
+
+	// Contents larger than a request are transferred with INCR.
+	img := bytes.Repeat([]byte{0x89, 'P', 'N', 'G', 1, 2, 3}, 3000)
+	second := serveInBackground(t, "image/png", img)
+
+	select {
+	case err := <-first:
+		require.NoError(t, err, "previous owner must stop once the clipboard is taken")
+	case <-time.After(5 * time.Second):
+		t.Fatal("previous owner still serving")
+	}
+
+	got, err = read(Display{}, "image/png")
+	require.NoError(t, err)
+	assert.Equal(t, img, got)
+
+	_, err = read(Display{}, "UTF8_STRING")
+	require.ErrorIs(t, err, ErrEmptyClipboard)
+
+	_ = serveInBackground(t, "text/html", []byte("<b>bye</b>"))
+	require.NoError(t, <-second)
+}
+
+// TestSelectionTransferXvfb copies between two real X servers, when
+// Xvfb is installed.
+func TestSelectionTransferXvfb(t *testing.T) {
+	xvfb, err := exec.LookPath("Xvfb")
+	if err != nil {
+		t.Skip("Xvfb not found")
+	}
+
+	src, dst := Display{Number: 197}, Display{Number: 198}
+	for _, d := range []Display{src, dst} {
+		cmd := exec.Command(xvfb, ":"+strconv.Itoa(int(d.Number)), "-nolisten", "tcp")
+		require.NoError(t, cmd.Start())
+		t.Cleanup(func() {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		})
+
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(x11.SocketPath(d.Number))
+			return err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+	}
+
+	data := bytes.Repeat([]byte("qubesome "), 100000)
+	ready := make(chan struct{})
+	go func() { _ = Serve(src, "UTF8_STRING", data, func() { close(ready) }) }()
+	<-ready
+
+	got, err := read(src, "UTF8_STRING")
+	require.NoError(t, err)
+
+	ready = make(chan struct{})
+	go func() { _ = Serve(dst, "UTF8_STRING", got, func() { close(ready) }) }()
+	<-ready
+
+	got, err = read(dst, "UTF8_STRING")
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
@@ -0,0 +1,64 @@
+package clipboard
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/execabs"
+)
+
+// ServeReady is printed by the clipboard server once it owns the
+// clipboard.
+const ServeReady = "ready"
+
+// serveDetached serves data from a background qubesome process, as the
+// clipboard contents are only available while their owner is running.
+func serveDetached(d Display, target string, data []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("cannot find qubesome binary: %w", err)
+	}
+
+	cmd := execabs.Command(exe, serveArgs(d, target)...) //nolint
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cannot start clipboard server: %w", err)
+	}
+
+	var lines []string
+	s := bufio.NewScanner(out)
+	for s.Scan() {
+		if s.Text() == ServeReady {
+			return cmd.Process.Release()
+		}
+		lines = append(lines, s.Text())
+	}
+
+	_ = cmd.Wait()
+	msg := strings.TrimSpace(strings.Join(lines, "\n"))
+	if msg == "" {
+		return errors.New("clipboard server exited")
+	}
+	return fmt.Errorf("clipboard server failed: %s", msg)
+}
+
+// serveArgs returns the qubesome args which serve the clipboard of d.
+func serveArgs(d Display, target string) []string {
+	return []string{"clipboard", "serve",
+		"-display", strconv.Itoa(int(d.Number)),
+		"-authority", d.Authority,
+		"-type", target,
+	}
+}
@@ -20,10 +20,6 @@ var (
 )
 
 var deps map[string][]string = map[string][]string{
-	"clip": {
-		files.XclipBinary,
-		files.ShBinary,
-	},
 	"run": {
 		files.PodmanBinary,
 		files.DockerBinary,
@@ -42,20 +38,33 @@ var deps map[string][]string = map[string][]string{
 		files.ShBinary,
 		files.XrandrBinary,
 	},
+	"attach": {
+		files.VncViewerBinary,
+	},
+	"record": {
+		files.FfmpegBinary,
+	},
 }
 
 var optionalDeps map[string][]string = map[string][]string{
 	"run": {
+		files.NerdctlBinary,
 		files.FireCrackerBinary,
+		files.MkfsExt4Binary,
+		files.DebugfsBinary,
+		files.BwrapBinary,
 		files.DbusBinary,
 	},
 	"xdg-open": {
+		files.NerdctlBinary,
 		files.FireCrackerBinary,
 	},
 	"images": {
+		files.NerdctlBinary,
 		files.FireCrackerBinary,
 	},
 	"start": {
+		files.NerdctlBinary,
 		files.FireCrackerBinary,
 		files.DbusBinary,
 	},
@@ -102,8 +111,7 @@ func Run(opts ...command.Option[Options]) error {
 		return nil
 	}
 
-	bin := files.ContainerRunnerBinary(o.Runner)
-	imgs, err := images.MissingImages(bin, o.Config)
+	imgs, err := images.MissingImages(o.Runner, o.Config)
 	if err != nil {
 		return err
 	}
@@ -114,7 +122,7 @@ func Run(opts ...command.Option[Options]) error {
 	for _, img := range imgs {
 		status := amber + "Missing" + reset
 
-		fmt.Fprintf(writer, "%s\t%s\t%s\n", img, bin, status)
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", img.Name, img.Binary, status)
 	}
 
 	writer.Flush()
@@ -0,0 +1,104 @@
+package egress
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"github.com/qubesome/cli/internal/types"
+	"golang.org/x/sys/execabs"
+)
+
+// refreshInterval limits how often containers are inspected when an
+// unknown address connects to the proxy.
+const refreshInterval = time.Second
+
+type workload struct {
+	name  string
+	allow types.Egress
+}
+
+type containerLookup struct {
+	bin     string
+	network string
+
+	mu        sync.Mutex
+	workloads map[netip.Addr]workload
+	refreshed time.Time
+}
+
+// ContainerLookup identifies workloads by their address on network,
+// based on the labels set by the container runners.
+func ContainerLookup(bin, network string) Lookup {
+	c := &containerLookup{bin: bin, network: network}
+	return c.lookup
+}
+
+func (c *containerLookup) lookup(addr netip.Addr) (string, types.Egress, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w, ok := c.workloads[addr]
+	if !ok && time.Since(c.refreshed) > refreshInterval {
+		if err := c.refresh(); err != nil {
+			slog.Warn("cannot inspect egress proxy clients", "error", err)
+		}
+		w, ok = c.workloads[addr]
+	}
+	return w.name, w.allow, ok
+}
+
+func (c *containerLookup) refresh() error {
+	c.refreshed = time.Now()
+
+	out, err := execabs.Command(c.bin, "ps", "-q", "--filter", "network="+c.network).Output()
+	if err != nil {
+		return fmt.Errorf("cannot list containers on network %q: %w", c.network, err)
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) == 0 {
+		c.workloads = nil
+		return nil
+	}
+
+	format := fmt.Sprintf(`{{index .Config.Labels %q}}|{{index .Config.Labels %q}}|{{(index .NetworkSettings.Networks %q).IPAddress}}`,
+		container.WorkloadLabel, container.EgressLabel, c.network)
+	args := append([]string{"inspect", "--format", format}, ids...)
+	out, err = execabs.Command(c.bin, args...).Output()
+	if err != nil {
+		return fmt.Errorf("cannot inspect containers on network %q: %w", c.network, err)
+	}
+
+	c.workloads = parseInspect(out)
+	return nil
+}
+
+// parseInspect parses lines in the format workload|domains|address.
+// Containers without a workload label, such as the profile's own
+// container, are ignored.
+func parseInspect(out []byte) map[netip.Addr]workload {
+	workloads := map[netip.Addr]workload{}
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		fields := strings.Split(strings.TrimSpace(string(line)), "|")
+		if len(fields) != 3 || fields[0] == "" || fields[0] == "<no value>" {
+			continue
+		}
+
+		addr, err := netip.ParseAddr(fields[2])
+		if err != nil {
+			continue
+		}
+
+		var allow types.Egress
+		if fields[1] != "" && fields[1] != "<no value>" {
+			allow.Allow = strings.Split(fields[1], ",")
+		}
+		workloads[addr] = workload{name: fields[0], allow: allow}
+	}
+	return workloads
+}
@@ -0,0 +1,221 @@
+// Package egress implements the filtering proxy which is the only route
+// out of a profile's internal network. It supports plain HTTP requests
+// and HTTPS via CONNECT, only allowing the domains set for the workload
+// making the request and the CONNECT ports set for the profile.
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/qubesome/cli/internal/log"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/freebind"
+)
+
+const (
+	dialTimeout       = 10 * time.Second
+	readHeaderTimeout = 10 * time.Second
+)
+
+// hopHeaders are removed when forwarding requests, as they only apply
+// to the connection with the proxy.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Lookup returns the workload with the given address, and the domains
+// it may reach.
+type Lookup func(addr netip.Addr) (workload string, allow types.Egress, ok bool)
+
+// Proxy is an HTTP proxy that enforces per-workload domain allowlists.
+type Proxy struct {
+	profile   string
+	ceiling   types.Egress
+	lookup    Lookup
+	dialer    *net.Dialer
+	transport *http.Transport
+}
+
+// New returns a proxy for profile, which only allows CONNECT to the
+// ports of its egress ceiling.
+func New(profile string, ceiling types.Egress, lookup Lookup) *Proxy {
+	d := &net.Dialer{Timeout: dialTimeout}
+	return &Proxy{
+		profile: profile,
+		ceiling: ceiling,
+		lookup:  lookup,
+		dialer:  d,
+		transport: &http.Transport{
+			DialContext:         d.DialContext,
+			TLSHandshakeTimeout: dialTimeout,
+		},
+	}
+}
+
+// Listen listens on addr, which may not be assigned to the host yet.
+func Listen(addr string) (net.Listener, error) {
+	l, err := freebind.Listen("tcp4", addr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen for egress proxy on %q: %w", addr, err)
+	}
+	return l, nil
+}
+
+// Serve serves the proxy on l until it is closed.
+func (p *Proxy) Serve(l net.Listener) error {
+	s := &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	err := s.Serve(l)
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect && !r.URL.IsAbs() {
+		http.Error(w, "qubesome: not a proxy request", http.StatusBadRequest)
+		return
+	}
+
+	host := r.URL.Hostname()
+	workload, allowed := p.allowed(r.RemoteAddr, host)
+	if !allowed {
+		log.Audit("egress request blocked",
+			"profile", p.profile, "workload", workload,
+			"client", r.RemoteAddr, "method", r.Method, "host", host)
+		http.Error(w, "qubesome: egress to "+host+" is not allowed", http.StatusForbidden)
+		return
+	}
+
+	scheme, port := requestPort(r)
+	if !p.ceiling.AllowsPort(scheme, port) {
+		log.Audit("egress port blocked",
+			"profile", p.profile, "workload", workload,
+			"client", r.RemoteAddr, "host", r.URL.Host)
+		http.Error(w, "qubesome: egress to port "+port+" is not allowed", http.StatusForbidden)
+		return
+	}
+
+	slog.Debug("egress request allowed", "workload", workload, "method", r.Method, "host", host)
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	p.forward(w, r)
+}
+
+// requestPort returns the scheme and port r is proxied to. CONNECT
+// tunnels count as https, and must always set their port.
+func requestPort(r *http.Request) (string, string) {
+	if r.Method == http.MethodConnect {
+		return "https", r.URL.Port()
+	}
+
+	port := r.URL.Port()
+	if port == "" {
+		switch r.URL.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+	}
+	return r.URL.Scheme, port
+}
+
+// allowed returns the workload behind remoteAddr and whether it may
+// reach host.
+func (p *Proxy) allowed(remoteAddr, host string) (string, bool) {
+	ap, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return "", false
+	}
+
+	workload, allow, ok := p.lookup(ap.Addr().Unmap())
+	if !ok {
+		return "", false
+	}
+	return workload, allow.Allows(host)
+}
+
+func (p *Proxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	dst, err := p.dialer.DialContext(r.Context(), "tcp", r.URL.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer dst.Close()
+
+	rc := http.NewResponseController(w)
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	src, buf, err := rc.Hijack()
+	if err != nil {
+		slog.Debug("cannot hijack egress connection", "error", err)
+		return
+	}
+	defer src.Close()
+
+	done := make(chan struct{}, 2)
+	cp := func(dst io.Writer, src io.Reader) {
+		_, _ = io.Copy(dst, src)
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	// Data sent by the client right after the CONNECT request may
+	// already be buffered.
+	go cp(dst, buf)
+	go cp(src, dst)
+
+	<-done
+	<-done
+}
+
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request) {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
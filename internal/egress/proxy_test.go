@@ -0,0 +1,157 @@
+package egress
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer target.Close()
+	targetURL, err := url.Parse(target.URL)
+	require.NoError(t, err)
+	port, err := strconv.ParseUint(targetURL.Port(), 10, 16)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		allow    []string
+		ports    []uint16
+		known    bool
+		wantCode int
+	}{
+		{
+			name:     "allowed domain",
+			allow:    []string{"127.0.0.1"},
+			ports:    []uint16{uint16(port)},
+			known:    true,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "port not allowed",
+			allow:    []string{"127.0.0.1"},
+			known:    true,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "domain not allowed",
+			allow:    []string{"*.slack.com"},
+			known:    true,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "unknown client",
+			allow:    []string{"127.0.0.1"},
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lookup := func(addr netip.Addr) (string, types.Egress, bool) {
+				assert.Equal(t, "127.0.0.1", addr.String())
+				return "test-profile", types.Egress{Allow: tc.allow}, tc.known
+			}
+			proxy := httptest.NewServer(New("profile", types.Egress{Ports: tc.ports}, lookup))
+			defer proxy.Close()
+
+			t.Run("http", func(t *testing.T) {
+				proxyURL, err := url.Parse(proxy.URL)
+				require.NoError(t, err)
+				c := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+				resp, err := c.Get(target.URL)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				assert.Equal(t, tc.wantCode, resp.StatusCode)
+				if tc.wantCode == http.StatusOK {
+					body, err := io.ReadAll(resp.Body)
+					require.NoError(t, err)
+					assert.Equal(t, "hello", string(body))
+				}
+			})
+
+			t.Run("connect", func(t *testing.T) {
+				conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+				require.NoError(t, err)
+				defer conn.Close()
+
+				fmt.Fprintf(conn, "CONNECT %[1]s HTTP/1.1\r\nHost: %[1]s\r\n\r\n", targetURL.Host)
+				br := bufio.NewReader(conn)
+				resp, err := http.ReadResponse(br, nil)
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantCode, resp.StatusCode)
+				if tc.wantCode != http.StatusOK {
+					return
+				}
+
+				fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nConnection: close\r\n\r\n", targetURL.Host)
+				resp, err = http.ReadResponse(br, nil)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+
+				body, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, "hello", string(body))
+			})
+		})
+	}
+}
+
+func TestRequestPort(t *testing.T) {
+	tests := []struct {
+		method     string
+		target     string
+		wantScheme string
+		wantPort   string
+	}{
+		{method: http.MethodGet, target: "http://example.com/", wantScheme: "http", wantPort: "80"},
+		{method: http.MethodGet, target: "http://example.com:8080/", wantScheme: "http", wantPort: "8080"},
+		{method: http.MethodGet, target: "https://example.com/", wantScheme: "https", wantPort: "443"},
+		{method: http.MethodConnect, target: "example.com:443", wantScheme: "https", wantPort: "443"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.target, nil)
+			scheme, port := requestPort(r)
+			assert.Equal(t, tc.wantScheme, scheme)
+			assert.Equal(t, tc.wantPort, port)
+		})
+	}
+}
+
+func TestParseInspect(t *testing.T) {
+	out := []byte(`slack-work|*.slack.com,slack.com|172.30.0.3
+|<no value>|172.30.0.2
+curl-work||172.30.0.4
+<no value>|<no value>|172.30.0.5
+broken-work|a.com|
+`)
+
+	want := map[netip.Addr]workload{
+		netip.MustParseAddr("172.30.0.3"): {
+			name:  "slack-work",
+			allow: types.Egress{Allow: []string{"*.slack.com", "slack.com"}},
+		},
+		netip.MustParseAddr("172.30.0.4"): {name: "curl-work"},
+	}
+	assert.Equal(t, want, parseInspect(out))
+}
@@ -7,13 +7,18 @@ import (
 
 const (
 	ShBinary          = "/bin/sh"
-	XclipBinary       = "/usr/bin/xclip"
 	FireCrackerBinary = "/usr/bin/firecracker"
 	XrandrBinary      = "/usr/bin/xrandr"
 	WlrRandrBinary    = "/usr/bin/wlr-randr"
 	DbusBinary        = "/usr/bin/dbus-send"
 	PodmanBinary      = "/usr/bin/podman"
 	DockerBinary      = "/usr/bin/docker"
+	BwrapBinary       = "/usr/bin/bwrap"
+	NerdctlBinary     = "/usr/local/bin/nerdctl"
+	MkfsExt4Binary    = "/usr/sbin/mkfs.ext4"
+	DebugfsBinary     = "/usr/sbin/debugfs"
+	VncViewerBinary   = "/usr/bin/vncviewer"
+	FfmpegBinary      = "/usr/bin/ffmpeg"
 )
 
 func ContainerRunnerBinary(runner string) string {
@@ -34,6 +39,14 @@ func ContainerRunnerBinary(runner string) string {
 
 		slog.Debug("could not find docker on PATH", "binary", DockerBinary)
 		return DockerBinary
+	case "nerdctl":
+		p, err := exec.LookPath("nerdctl")
+		if err == nil {
+			return p
+		}
+
+		slog.Debug("could not find nerdctl on PATH", "binary", NerdctlBinary)
+		return NerdctlBinary
 	}
 
 	slog.Debug("auto-detecting runner")
@@ -49,6 +62,12 @@ func ContainerRunnerBinary(runner string) string {
 		return p
 	}
 
+	p, err = exec.LookPath("nerdctl")
+	if err == nil {
+		slog.Debug("found nerdctl", "path", p)
+		return p
+	}
+
 	slog.Debug("fallback to static path", "path", PodmanBinary)
 	return PodmanBinary
 }
@@ -8,21 +8,28 @@ import (
 )
 
 func TestContainerRunnerBinary(t *testing.T) {
+	// Binaries on the PATH take precedence, so hide them.
+	t.Setenv("PATH", t.TempDir())
+
 	tests := []struct {
 		in   string
 		want string
 	}{
 		{
 			in:   "",
-			want: "/usr/bin/podman",
+			want: files.PodmanBinary,
 		},
 		{
 			in:   "podman",
-			want: "/usr/bin/podman",
+			want: files.PodmanBinary,
 		},
 		{
 			in:   "docker",
-			want: "/usr/bin/docker",
+			want: files.DockerBinary,
+		},
+		{
+			in:   "nerdctl",
+			want: files.NerdctlBinary,
 		},
 	}
 
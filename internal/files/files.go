@@ -3,9 +3,20 @@
 // Key locations:
 // - ~/.qubesome: default location for persistent files.
 // - ~/.qubesome/images-last-checked: file that stores when images were last checked.
+// - ~/.qubesome/allowed-signers.asc: keys trusted to sign git commits.
+// - ~/.qubesome/vmlinux: kernel used by the firecracker runner.
+// - ~/.qubesome/firecracker/rootfs/<digest>.ext4: cached firecracker root file systems.
+// - ~/.qubesome/firecracker/snapshots/<workload>-<profile>: firecracker VM snapshots.
+// - ~/.qubesome/dns/<profile>.log: DNS queries of profiles with a query log.
+// - ~/.qubesome/history/<profile>.yaml: commits a profile was started from.
+// - ~/.qubesome/permissions/<profile>.yaml: last accepted host access per profile.
 // - ~/.qubesome/run: root of ephemeral files.
+// - ~/.qubesome/run/firecracker/<workload>-<profile>: files of running firecracker VMs.
+// - ~/.qubesome/run/<profile>/display/vnc.sock: VNC socket of headless profiles.
 // - ~/.qubesome/git/<git-url>/<path>: where git repositories
 // are cloned to.
+// - ~/Pictures/qubesome: default location of profile screenshots.
+// - ~/Videos/qubesome: default location of profile recordings.
 package files
 
 import (
@@ -13,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
@@ -21,6 +33,10 @@ import (
 const (
 	FileMode = 0o600
 	DirMode  = 0o700
+
+	// ContainerRuntimeDir is the runtime dir within containers, as their
+	// user is mapped to UID 1000 regardless of the host UID.
+	ContainerRuntimeDir = "/run/user/1000"
 )
 
 var (
@@ -51,6 +67,69 @@ func ImagesLastCheckedPath() string {
 	return filepath.Join(QubesomeDir(), "images-last-checked")
 }
 
+// AllowedSignersPath returns the default path to the armored OpenPGP
+// keyring used to verify signed git commits.
+func AllowedSignersPath() string {
+	return filepath.Join(QubesomeDir(), "allowed-signers.asc")
+}
+
+// FirecrackerKernelPath returns the path to the kernel image used to
+// boot firecracker VMs.
+func FirecrackerKernelPath() string {
+	return filepath.Join(QubesomeDir(), "vmlinux")
+}
+
+// FirecrackerRootfsPath returns the path to the cached root file system
+// built from the image with the given digest.
+func FirecrackerRootfsPath(digest string) (string, error) {
+	base := filepath.Join(QubesomeDir(), "firecracker", "rootfs")
+	return securejoin.SecureJoin(base, strings.ReplaceAll(digest, ":", "-")+".ext4")
+}
+
+// FirecrackerSnapshotDir returns the directory holding the snapshot of
+// the given firecracker workload.
+func FirecrackerSnapshotDir(name string) (string, error) {
+	base := filepath.Join(QubesomeDir(), "firecracker", "snapshots")
+	return securejoin.SecureJoin(base, name)
+}
+
+// FirecrackerRunDir returns the directory holding the ephemeral files
+// of the given firecracker workload, such as its API socket.
+func FirecrackerRunDir(name string) (string, error) {
+	base := filepath.Join(RunUserQubesome(), "firecracker")
+	return securejoin.SecureJoin(base, name)
+}
+
+// ProfileHistoryPath returns the path to the file that records the git
+// commits the given profile was started from.
+func ProfileHistoryPath(profile string) (string, error) {
+	base := filepath.Join(QubesomeDir(), "history")
+	return securejoin.SecureJoin(base, fmt.Sprintf("%s.yaml", profile))
+}
+
+// DNSQueryLogPath returns the path to the DNS query log of the given
+// profile.
+func DNSQueryLogPath(profile string) (string, error) {
+	base := filepath.Join(QubesomeDir(), "dns")
+	return securejoin.SecureJoin(base, fmt.Sprintf("%s.log", profile))
+}
+
+// PermissionsPath returns the path to the file that records the host
+// access last accepted for the given profile.
+func PermissionsPath(profile string) (string, error) {
+	base := filepath.Join(QubesomeDir(), "permissions")
+	return securejoin.SecureJoin(base, fmt.Sprintf("%s.yaml", profile))
+}
+
+// HostRuntimeDir returns the runtime dir of the current user on the host,
+// based on XDG_RUNTIME_DIR or, when not set, on the user's UID.
+func HostRuntimeDir() string {
+	if d := os.Getenv("XDG_RUNTIME_DIR"); d != "" {
+		return d
+	}
+	return "/run/user/" + strconv.Itoa(os.Getuid())
+}
+
 // RunUserQubesome returns the path to the user-specific qubesome directory.
 func RunUserQubesome() string {
 	return filepath.Join(QubesomeDir(), "run")
@@ -84,6 +163,22 @@ func ProfileDir(profile string) string {
 	return filepath.Join(base, profile)
 }
 
+// DisplaySocketPath returns the path to the VNC socket of a profile with
+// a headless display.
+func DisplaySocketPath(profile string) string {
+	return filepath.Join(ProfileDir(profile), "display", "vnc.sock")
+}
+
+// ScreenshotDir returns the default directory for profile screenshots.
+func ScreenshotDir() string {
+	return os.ExpandEnv("${HOME}/Pictures/qubesome")
+}
+
+// RecordingDir returns the default directory for profile recordings.
+func RecordingDir() string {
+	return os.ExpandEnv("${HOME}/Videos/qubesome")
+}
+
 // InProfileSocketPath returns the path to the socket when running inside the profile
 // container.
 func InProfileSocketPath() string {
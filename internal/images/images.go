@@ -20,19 +20,27 @@ func Run(opts ...command.Option[Options]) error {
 		opt(o)
 	}
 
-	bin := files.ContainerRunnerBinary(o.Runner)
-
 	slog.Debug("images.Run", "options", o)
-	return PullAll(bin, o.Config)
+	return PullAll(o.Runner, o.Config)
+}
+
+// Image is a container image and the binary of the engine it is pulled
+// with.
+type Image struct {
+	Name   string
+	Binary string
 }
 
-func Pull(bin string, cfg *types.Config, wg *sync.WaitGroup) error {
+// Pull pulls all images of cfg in the background, based on its pull mode.
+// Images are pulled with the engine of runner, if set, or of the runner
+// of their profile or workload.
+func Pull(runner string, cfg *types.Config, wg *sync.WaitGroup) error {
 	switch cfg.WorkloadPullMode {
 	case types.Background:
 		wg.Add(1)
 		go func() {
 			if exp, _ := pullExpired(); exp {
-				err := PullAll(bin, cfg)
+				err := PullAll(runner, cfg)
 				if err != nil {
 					slog.Error("error pulling images", "error", err)
 				}
@@ -76,7 +84,7 @@ func pullExpired() (bool, error) {
 	return false, nil
 }
 
-func PreemptWorkloadImages(bin string, cfg *types.Config) {
+func PreemptWorkloadImages(runner string, cfg *types.Config) {
 	slog.Debug("Check need for the preemptive pull of workload images")
 	fn := files.ImagesLastCheckedPath()
 
@@ -84,21 +92,21 @@ func PreemptWorkloadImages(bin string, cfg *types.Config) {
 	if err != nil && os.IsNotExist(err) {
 		fmt.Println("INFO: Preemptively pulling workload images. This only happens on first execution and aims to avoid delays opening apps.")
 
-		_ = PullAll(bin, cfg)
+		_ = PullAll(runner, cfg)
 		_ = os.WriteFile(fn, []byte{}, files.FileMode)
 	}
 }
 
-func PullAll(bin string, cfg *types.Config) error {
-	imgs, err := UniqueImages(cfg)
+func PullAll(runner string, cfg *types.Config) error {
+	imgs, err := EngineImages(cfg, runner)
 	if err != nil {
 		return fmt.Errorf("cannot get images: %w", err)
 	}
 
 	for _, img := range imgs {
-		err = PullImage(bin, img)
+		err = PullImage(img.Binary, img.Name)
 		if err != nil {
-			slog.Error("cannot pull image %q: %w", img, err)
+			slog.Error("cannot pull image", "image", img.Name, "binary", img.Binary, "error", err)
 		}
 	}
 
@@ -137,15 +145,15 @@ func imagePresent(bin, image string) (found bool, err error) {
 	return
 }
 
-func MissingImages(bin string, cfg *types.Config) ([]string, error) {
-	imgs, err := UniqueImages(cfg)
+func MissingImages(runner string, cfg *types.Config) ([]Image, error) {
+	imgs, err := EngineImages(cfg, runner)
 	if err != nil {
 		return nil, fmt.Errorf("cannot get images: %w", err)
 	}
 
-	missing := make([]string, 0, len(imgs))
+	missing := make([]Image, 0, len(imgs))
 	for _, img := range imgs {
-		ok, err := imagePresent(bin, img)
+		ok, err := imagePresent(img.Binary, img.Name)
 		if ok && err == nil {
 			continue
 		}
@@ -156,18 +164,31 @@ func MissingImages(bin string, cfg *types.Config) ([]string, error) {
 	return missing, nil
 }
 
-func UniqueImages(cfg *types.Config) ([]string, error) {
+// EngineImages returns the unique images used by the profiles and
+// workloads of cfg, along with the engine they are pulled with. When
+// runner is set, it overrides the runner of all of them, as it does
+// when running workloads.
+func EngineImages(cfg *types.Config, runner string) ([]Image, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("config cannot be nil")
 	}
 
-	missing := []string{}
+	var imgs []Image
+	seen := map[Image]struct{}{}
+	add := func(name, r string) {
+		if runner != "" {
+			r = runner
+		}
+		img := Image{Name: name, Binary: files.ContainerRunnerBinary(engine(r))}
+		if _, ok := seen[img]; !ok {
+			seen[img] = struct{}{}
+			imgs = append(imgs, img)
+		}
+	}
 
-	seen := map[string]struct{}{}
 	for _, p := range cfg.Profiles {
-		if _, ok := seen[p.Image]; !ok {
-			seen[p.Image] = struct{}{}
-			missing = append(missing, p.Image)
+		if p.Image != "" {
+			add(p.Image, p.Runner)
 		}
 	}
 
@@ -201,11 +222,26 @@ func UniqueImages(cfg *types.Config) ([]string, error) {
 			return nil, fmt.Errorf("cannot unmarshal workload file %q: %w", fn, err)
 		}
 
-		if _, ok := seen[w.Image]; !ok {
-			seen[w.Image] = struct{}{}
-			missing = append(missing, w.Image)
+		// Workloads using the bwrap runner may not have an image.
+		if w.Image == "" {
+			continue
 		}
+
+		add(w.Image, w.Runner)
 	}
 
-	return missing, nil
+	return imgs, nil
+}
+
+// engine returns the container engine used for images of runner, or an
+// empty string for the default engine.
+func engine(runner string) string {
+	switch runner {
+	case "docker", "podman", "nerdctl":
+		return runner
+	case "firecracker":
+		// VM root file systems are built from docker images.
+		return "docker"
+	}
+	return ""
 }
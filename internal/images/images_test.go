@@ -0,0 +1,58 @@
+package images
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngineImages(t *testing.T) {
+	// Binaries on the PATH take precedence, so hide them.
+	t.Setenv("PATH", t.TempDir())
+
+	root := t.TempDir()
+	wd := filepath.Join(root, "i3", "workloads")
+	require.NoError(t, os.MkdirAll(wd, 0o700))
+
+	for name, data := range map[string]string{
+		"chrome.yaml":  "name: chrome\nimage: chrome\n",
+		"code.yaml":    "name: code\nimage: code\nrunner: nerdctl\n",
+		"slack.yaml":   "name: slack\nimage: slack\nrunner: firecracker\n",
+		"xterm.yaml":   "name: xterm\nrunner: bwrap\ncommand: /usr/bin/xterm\n",
+		"firefox.yaml": "name: firefox\nimage: chrome\nrunner: podman\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(wd, name), []byte(data), 0o600))
+	}
+
+	cfg := &types.Config{
+		RootDir:  root,
+		Profiles: map[string]types.Profile{"i3": {Name: "i3", Image: "i3", Runner: "nerdctl"}},
+	}
+
+	got, err := EngineImages(cfg, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Image{
+		{Name: "i3", Binary: files.NerdctlBinary},
+		{Name: "chrome", Binary: files.PodmanBinary},
+		{Name: "code", Binary: files.NerdctlBinary},
+		{Name: "slack", Binary: files.DockerBinary},
+	}, got)
+
+	// The runner override applies to all images.
+	got, err = EngineImages(cfg, "nerdctl")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Image{
+		{Name: "i3", Binary: files.NerdctlBinary},
+		{Name: "chrome", Binary: files.NerdctlBinary},
+		{Name: "code", Binary: files.NerdctlBinary},
+		{Name: "slack", Binary: files.NerdctlBinary},
+	}, got)
+
+	_, err = EngineImages(nil, "")
+	assert.Error(t, err)
+}
@@ -6,4 +6,17 @@ const (
 	MtlsCA         SecretName = "mtls-ca"
 	MtlsClientCert SecretName = "mtls-client-cert"
 	MtlsClientKey  SecretName = "mtls-client-key"
+
+	// GitToken is the token used to authenticate against HTTPS git
+	// remotes. It is always scoped to a single host, see GitHostScope.
+	GitToken SecretName = "git-token"
 )
+
+// GitScope is the keyring scope used for git credentials, which are not
+// tied to any profile.
+const GitScope = "git"
+
+// GitHostScope returns the keyring scope of the git credentials for host.
+func GitHostScope(host string) string {
+	return GitScope + ":" + host
+}
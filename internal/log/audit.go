@@ -0,0 +1,45 @@
+package log
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	auditOnce   sync.Once
+	auditLogger *slog.Logger
+)
+
+// Audit records a security relevant event, such as a blocked network
+// request, to the audit log. Entries are written as JSON lines to a file
+// next to the qubesome log, regardless of the configured log level.
+func Audit(msg string, args ...any) {
+	auditOnce.Do(func() {
+		auditLogger = newAuditLogger(statePath(auditFileName))
+	})
+	auditLogger.Info(msg, args...)
+}
+
+func newAuditLogger(path string) *slog.Logger {
+	f, err := openAuditFile(path)
+	if err != nil {
+		slog.Warn("cannot open audit log, using default logger", "error", err)
+		return slog.Default().With("audit", true)
+	}
+
+	return slog.New(slog.NewJSONHandler(f, nil))
+}
+
+func openAuditFile(path string) (*os.File, error) {
+	if !filepath.IsAbs(path) {
+		return nil, ErrRelativePathLogFile
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), logDirMode); err != nil {
+		return nil, err
+	}
+
+	return os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, logFileMode)
+}
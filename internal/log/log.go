@@ -18,11 +18,12 @@ const (
 	xdgStateVar     = "XDG_STATE_HOME"
 	xdgStateDefault = "${HOME}/.local/state"
 
-	syslogTag   = "qubesome"
-	logDir      = "qubesome"
-	logFileName = "qubesome.log"
-	logFileMode = 0o600
-	logDirMode  = 0o700
+	syslogTag     = "qubesome"
+	logDir        = "qubesome"
+	logFileName   = "qubesome.log"
+	auditFileName = "audit.log"
+	logFileMode   = 0o600
+	logDirMode    = 0o700
 )
 
 var (
@@ -80,12 +81,16 @@ func Configure(level string, toStdout, toFile, toSyslog bool) error {
 }
 
 func logPath() string {
+	return statePath(logFileName)
+}
+
+func statePath(name string) string {
 	base := os.ExpandEnv(xdgStateDefault)
 	if v, ok := lookupEnv(xdgStateVar); ok {
 		base = v
 	}
 
-	return filepath.Join(base, logDir, logFileName)
+	return filepath.Join(base, logDir, name)
 }
 
 func slogLevel(logLevel string) slog.Level {
@@ -32,3 +32,16 @@ func TestLogPath(t *testing.T) {
 		})
 	}
 }
+
+func TestNewAuditLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "qubesome", auditFileName)
+
+	l := newAuditLogger(path)
+	l.Info("egress blocked", "host", "example.com")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal("cannot read audit log", err)
+	}
+	Contains(t, string(data), `"msg":"egress blocked","host":"example.com"`)
+}
@@ -0,0 +1,211 @@
+// Package permissions tracks the host access granted to the workloads of
+// each profile, so that access changes introduced by new config versions
+// are surfaced to the user before being used.
+package permissions
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/dbus"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotAccepted is returned when the user declines new host access.
+var ErrNotAccepted = errors.New("new host access was not accepted")
+
+var isTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) //nolint:gosec // G115: fd values fit in int
+}
+
+// Grants maps workload names to the host access granted to them.
+type Grants map[string][]string
+
+type record struct {
+	Grants Grants `yaml:"grants"`
+}
+
+// Load computes the grants of all workloads within the profile dir.
+func Load(profile *types.Profile, profileDir string) (Grants, error) {
+	g := Grants{}
+
+	wd := filepath.Join(profileDir, "workloads")
+	entries, err := os.ReadDir(wd)
+	if errors.Is(err, os.ErrNotExist) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".yaml" {
+			continue
+		}
+
+		fn := filepath.Join(wd, entry.Name())
+		data, err := os.ReadFile(fn)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read file %q: %w", fn, err)
+		}
+
+		w := types.Workload{}
+		if err := yaml.Unmarshal(data, &w); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal workload config %q: %w", fn, err)
+		}
+
+		w.Name = strings.TrimSuffix(entry.Name(), ".yaml")
+		if grants := w.ApplyProfile(profile).Grants(); len(grants) > 0 {
+			g[w.Name] = grants
+		}
+	}
+
+	return g, nil
+}
+
+// Hash returns a digest of all grants.
+func (g Grants) Hash() string {
+	h := sha256.New()
+	for _, name := range g.names() {
+		fmt.Fprintf(h, "%s\n", name)
+		for _, v := range g[name] {
+			fmt.Fprintf(h, "\t%s\n", v)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Added returns the grants in g which are not in old.
+func (g Grants) Added(old Grants) Grants {
+	added := Grants{}
+	for name, grants := range g {
+		for _, v := range grants {
+			if !slices.Contains(old[name], v) {
+				added[name] = append(added[name], v)
+			}
+		}
+	}
+	return added
+}
+
+// String returns a human-readable list of the grants, one per line.
+func (g Grants) String() string {
+	var sb strings.Builder
+	for _, name := range g.names() {
+		for _, v := range g[name] {
+			fmt.Fprintf(&sb, "%s: %s\n", name, v)
+		}
+	}
+	return sb.String()
+}
+
+func (g Grants) names() []string {
+	names := make([]string, 0, len(g))
+	for name := range g {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Accepted returns the grants last accepted for profile. The bool result
+// is false when no grants were ever accepted.
+func Accepted(profile string) (Grants, bool, error) {
+	fn, err := files.PermissionsPath(profile)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	r := record{}
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, false, fmt.Errorf("cannot unmarshal permissions file %q: %w", fn, err)
+	}
+	return r.Grants, true, nil
+}
+
+// Accept records g as the accepted grants for profile.
+func Accept(profile string, g Grants) error {
+	fn, err := files.PermissionsPath(profile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), files.DirMode); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(record{Grants: g})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, data, files.FileMode)
+}
+
+// Check compares g with the grants last accepted for profile. Newly
+// granted access must be confirmed when running on a terminal, unless
+// accept is set. Otherwise, the user is notified and ErrNotAccepted is
+// returned. The first grants seen for a profile are accepted as is.
+func Check(profile string, g Grants, accept bool) error {
+	old, ok, err := Accepted(profile)
+	if err != nil {
+		return err
+	}
+	if ok && old.Hash() == g.Hash() {
+		return nil
+	}
+
+	added := g.Added(old)
+	if ok && len(added) > 0 && !accept {
+		msg := fmt.Sprintf("profile %q config grants new host access:\n%s", profile, added)
+
+		if !isTerminal() {
+			msg += "Start the profile with -accept or from a terminal to allow it."
+			dbus.NotifyOrLog("qubesome: new host access", strings.ReplaceAll(msg, "\n", "<br/>"))
+			return fmt.Errorf("%w for profile %q: start it with -accept or from a terminal", ErrNotAccepted, profile)
+		}
+
+		fmt.Print(msg)
+		if !confirm("Accept the new host access?") {
+			return fmt.Errorf("%w for profile %q", ErrNotAccepted, profile)
+		}
+	}
+
+	return Accept(profile, g)
+}
+
+func confirm(prompt string) bool {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("%s (Y/N): ", prompt)
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return false
+		}
+
+		input = strings.TrimSpace(input)
+		if strings.EqualFold(input, "Y") {
+			return true
+		} else if strings.EqualFold(input, "N") {
+			return false
+		}
+		fmt.Println("Invalid input. Please enter 'Y' or 'N'.")
+	}
+}
@@ -0,0 +1,99 @@
+package permissions
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	wd := filepath.Join(dir, "workloads")
+	require.NoError(t, os.MkdirAll(wd, 0o700))
+
+	workloads := map[string]string{
+		"chrome.yaml":  "image: chrome\nhostAccess:\n  camera: true\n  microphone: true\n",
+		"slack.yaml":   "image: slack\nhostAccess:\n  network: none\n",
+		"firefox.yaml": "image: firefox\nhostAccess:\n  paths:\n    - /home/user/Downloads:/home/user/Downloads\n",
+		"README.md":    "not a workload",
+	}
+	for fn, data := range workloads {
+		require.NoError(t, os.WriteFile(filepath.Join(wd, fn), []byte(data), 0o600))
+	}
+
+	p := &types.Profile{
+		Name: "i3",
+		HostAccess: types.HostAccess{
+			Camera: true,
+			Paths:  []string{"/home/user/Downloads"},
+		},
+	}
+
+	got, err := Load(p, dir)
+	require.NoError(t, err)
+	assert.Equal(t, Grants{
+		"chrome":  {"camera"},
+		"firefox": {"path: /home/user/Downloads:/home/user/Downloads"},
+	}, got)
+}
+
+func TestAdded(t *testing.T) {
+	old := Grants{
+		"chrome":  {"camera"},
+		"firefox": {"path: /tmp:/tmp"},
+	}
+	cur := Grants{
+		"chrome": {"camera", "microphone"},
+		"slack":  {"network: host"},
+	}
+
+	assert.Equal(t, Grants{
+		"chrome": {"microphone"},
+		"slack":  {"network: host"},
+	}, cur.Added(old))
+	assert.Equal(t, "chrome: microphone\nslack: network: host\n", cur.Added(old).String())
+	assert.Empty(t, Grants{"chrome": {"camera"}}.Added(old))
+	assert.NotEqual(t, old.Hash(), cur.Hash())
+}
+
+func TestCheck(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/nonexistent")
+
+	old := isTerminal
+	isTerminal = func() bool { return false }
+	t.Cleanup(func() { isTerminal = old })
+
+	g := Grants{"chrome": {"camera"}}
+	_, ok, err := Accepted("i3")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// First grants are accepted as is.
+	require.NoError(t, Check("i3", g, false))
+	got, ok, err := Accepted("i3")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, g, got)
+
+	// Removing access needs no confirmation.
+	require.NoError(t, Check("i3", Grants{}, false))
+	got, _, err = Accepted("i3")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	// New access is refused without a terminal, unless accepted.
+	require.ErrorIs(t, Check("i3", g, false), ErrNotAccepted)
+	got, _, err = Accepted("i3")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	require.NoError(t, Check("i3", g, true))
+	got, _, err = Accepted("i3")
+	require.NoError(t, err)
+	assert.Equal(t, g, got)
+}
@@ -0,0 +1,192 @@
+package profiles
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"github.com/qubesome/cli/internal/types"
+	"golang.org/x/sys/execabs"
+)
+
+// containerDisplayDir is where the dir holding the VNC socket of headless
+// displays is mounted within the profile container.
+const containerDisplayDir = "/run/qubesome/display"
+
+// displayBackend runs the X server of a profile within its container.
+type displayBackend interface {
+	// command returns the command and args that start the X server.
+	command(p *types.Profile, display string, geometries []string) (string, []string)
+	// containerArgs returns the extra run args of the profile container.
+	containerArgs(p *types.Profile) ([]string, error)
+	// runsWindowManager returns whether the command also starts the
+	// profile's window manager.
+	runsWindowManager() bool
+	// needsHost returns whether the X server runs on top of the host's
+	// display.
+	needsHost() bool
+}
+
+func newDisplayBackend(p *types.Profile) displayBackend {
+	switch p.DisplayBackend {
+	case "xephyr":
+		return xephyr{}
+	case "xwayland":
+		return xwayland{}
+	case "headless":
+		return headless{}
+	}
+
+	if strings.EqualFold(os.Getenv("XDG_SESSION_TYPE"), "wayland") {
+		return xwayland{}
+	}
+	return xephyr{}
+}
+
+// xephyr runs a nested X server within a window of the host's X server.
+type xephyr struct{}
+
+func (xephyr) command(p *types.Profile, display string, geometries []string) (string, []string) {
+	args := []string{
+		":" + display,
+		"-title", fmt.Sprintf("qubesome-%s :%s", p.Name, display),
+		"-auth", "/home/xorg-user/.Xserver",
+		"-extension", "MIT-SHM",
+		"-extension", "XTEST",
+		"-nopn",
+		"-nolisten", "tcp",
+	}
+	args = append(args, screenArgs(geometries)...)
+	args = append(args, dpiArgs(p)...)
+	args = append(args, "-resizeable")
+	if p.Keyboard.NoGrab() {
+		args = append(args, "-no-host-grab")
+	}
+	if p.XephyrArgs != "" {
+		args = append(args, strings.Split(p.XephyrArgs, " ")...)
+	}
+	return "Xephyr", args
+}
+
+func (xephyr) containerArgs(*types.Profile) ([]string, error) {
+	return []string{"-e", "XDG_SESSION_TYPE=X11"}, nil
+}
+
+func (xephyr) runsWindowManager() bool { return false }
+func (xephyr) needsHost() bool         { return true }
+
+// xwayland runs an Xwayland server within a window of the host's Wayland
+// compositor.
+type xwayland struct{}
+
+func (xwayland) command(p *types.Profile, _ string, geometries []string) (string, []string) {
+	var args []string
+	if !p.Keyboard.NoGrab() {
+		args = append(args, "-host-grab")
+	}
+	args = append(args,
+		"-geometry", size(geometries[0]),
+		"-extension", "MIT-SHM",
+		"-extension", "XTEST",
+		"-nopn",
+		"-tst",
+		"-nolisten", "tcp",
+		"-auth", "/home/xorg-user/.Xserver",
+		"-verbose", "9",
+	)
+	args = append(args, dpiArgs(p)...)
+	args = append(args, "--", strings.TrimPrefix(p.WindowManager, "exec "))
+	return "xwayland-run", args
+}
+
+func (xwayland) containerArgs(*types.Profile) ([]string, error) {
+	if os.Getuid() == 0 {
+		return nil, fmt.Errorf("qubesome does not support running under privileged users")
+	}
+
+	// TODO: Investigate ways to avoid sharing /run/user/1000 on Wayland.
+	args := container.RuntimeDirEnv(files.ContainerRuntimeDir)
+	args = append(args,
+		"-e", "XDG_BACKEND",
+		"-e", "XDG_SEAT",
+		"-e", "XDG_SESSION_TYPE",
+		"-e", "XDG_SESSION_ID",
+		"-e", "XDG_SESSION_CLASS",
+		"-e", "XDG_SESSION_DESKTOP",
+		"-e", "WAYLAND_DISPLAY",
+		"-e", "HYPRLAND_INSTANCE_SIGNATURE",
+		"-v="+files.HostRuntimeDir()+":"+files.ContainerRuntimeDir,
+	)
+	return args, nil
+}
+
+func (xwayland) runsWindowManager() bool { return true }
+func (xwayland) needsHost() bool         { return true }
+
+// headless runs Xvfb, which needs no display on the host, and serves it
+// over VNC on a unix socket for qubesome attach. Both Xvfb and x11vnc
+// must be installed in the profile image.
+type headless struct{}
+
+// headlessBinaries are the binaries the profile image needs for headless
+// displays.
+var headlessBinaries = []string{"Xvfb", "x11vnc"}
+
+// requireBinaries returns a shell snippet which fails when any of names
+// is not found.
+func requireBinaries(names []string) string {
+	return fmt.Sprintf(`for b in %s; do command -v "$b" >/dev/null || { echo "$b not found in profile image" >&2; exit 127; }; done`,
+		strings.Join(names, " "))
+}
+
+// checkHeadless checks that the binaries of headless displays are found
+// within the running profile container.
+func checkHeadless(bin, name string) error {
+	out, err := execabs.Command(bin, "exec", name, files.ShBinary, "-c", requireBinaries(headlessBinaries)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("headless display requires %s in the profile image: %s: %w",
+			strings.Join(headlessBinaries, " and "), strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+func (headless) command(p *types.Profile, display string, geometries []string) (string, []string) {
+	xvfb := []string{
+		"Xvfb", ":" + display,
+		"-screen", "0", size(geometries[0]) + "x24",
+		"-auth", "/home/xorg-user/.Xserver",
+		"-nolisten", "tcp",
+	}
+	xvfb = append(xvfb, dpiArgs(p)...)
+
+	vnc := []string{
+		"x11vnc",
+		"-display", ":" + display,
+		"-auth", "/home/xorg-user/.Xserver",
+		"-unixsockonly", containerDisplayDir + "/" + filepath.Base(files.DisplaySocketPath(p.Name)),
+		"-forever", "-shared", "-quiet",
+	}
+
+	// x11vnc is retried until Xvfb accepts connections, and the container
+	// stops once Xvfb exits.
+	script := fmt.Sprintf(`%s; %s & pid=$!; until %s; do sleep 0.5; done & wait $pid`,
+		requireBinaries(headlessBinaries), strings.Join(xvfb, " "), strings.Join(vnc, " "))
+	return files.ShBinary, []string{"-c", script}
+}
+
+func (headless) containerArgs(p *types.Profile) ([]string, error) {
+	dir := filepath.Dir(files.DisplaySocketPath(p.Name))
+	if err := os.MkdirAll(dir, files.DirMode); err != nil {
+		return nil, fmt.Errorf("failed to create display dir: %w", err)
+	}
+	return []string{
+		"-e", "XDG_SESSION_TYPE=X11",
+		fmt.Sprintf("-v=%s:%s", dir, containerDisplayDir),
+	}, nil
+}
+
+func (headless) runsWindowManager() bool { return false }
+func (headless) needsHost() bool         { return false }
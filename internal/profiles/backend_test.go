@@ -0,0 +1,115 @@
+package profiles
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDisplayBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		session string
+		want    displayBackend
+	}{
+		{name: "default on X11", session: "x11", want: xephyr{}},
+		{name: "default on Wayland", session: "wayland", want: xwayland{}},
+		{name: "xephyr on Wayland", backend: "xephyr", session: "wayland", want: xephyr{}},
+		{name: "headless", backend: "headless", session: "x11", want: headless{}},
+		{name: "headless without session", backend: "headless", want: headless{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("XDG_SESSION_TYPE", tc.session)
+			got := newDisplayBackend(&types.Profile{DisplayBackend: tc.backend})
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestXephyrCommand(t *testing.T) {
+	p := &types.Profile{Name: "work", DPI: 120, XephyrArgs: "-br -glamor"}
+
+	cmd, args := xephyr{}.command(p, "3", []string{"1920x1080+0+0", "1280x1024+1920+0"})
+	assert.Equal(t, "Xephyr", cmd)
+	assert.Equal(t, []string{
+		":3",
+		"-title", "qubesome-work :3",
+		"-auth", "/home/xorg-user/.Xserver",
+		"-extension", "MIT-SHM",
+		"-extension", "XTEST",
+		"-nopn",
+		"-nolisten", "tcp",
+		"-screen", "1920x1080+0+0",
+		"-screen", "1280x1024+1920+0",
+		"+xinerama",
+		"-dpi", "120",
+		"-resizeable",
+		"-br", "-glamor",
+	}, args)
+}
+
+func TestXwaylandCommand(t *testing.T) {
+	p := &types.Profile{Name: "work", WindowManager: "exec awesome"}
+
+	cmd, args := xwayland{}.command(p, "3", []string{"1920x1080+0+0"})
+	assert.Equal(t, "xwayland-run", cmd)
+	assert.Contains(t, args, "1920x1080")
+	assert.Equal(t, []string{"--", "awesome"}, args[len(args)-2:])
+}
+
+func TestHeadlessCommand(t *testing.T) {
+	p := &types.Profile{Name: "work"}
+
+	cmd, args := headless{}.command(p, "3", []string{"1920x1080+0+0"})
+	assert.Equal(t, "/bin/sh", cmd)
+	require.Len(t, args, 2)
+	assert.Equal(t, "-c", args[0])
+	assert.Contains(t, args[1], "Xvfb :3 -screen 0 1920x1080x24")
+	assert.Contains(t, args[1], "x11vnc -display :3")
+	assert.Contains(t, args[1], "-unixsockonly /run/qubesome/display/vnc.sock")
+}
+
+func TestRequireBinaries(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Xvfb"), nil, 0o755))
+
+	run := func(names ...string) (string, error) {
+		cmd := exec.Command("/bin/sh", "-c", requireBinaries(names))
+		cmd.Env = []string{"PATH=" + dir}
+		out, err := cmd.CombinedOutput()
+		return string(out), err
+	}
+
+	_, err := run("Xvfb")
+	require.NoError(t, err)
+
+	out, err := run(headlessBinaries...)
+	require.Error(t, err)
+	assert.Contains(t, out, "x11vnc not found")
+}
+
+func TestNoHostGrab(t *testing.T) {
+	p := &types.Profile{Name: "work", Keyboard: &types.Keyboard{GrabKey: "default"}}
+
+	_, args := xephyr{}.command(p, "3", []string{"1920x1080"})
+	assert.NotContains(t, args, "-no-host-grab")
+
+	_, args = xwayland{}.command(p, "3", []string{"1920x1080"})
+	assert.Contains(t, args, "-host-grab")
+
+	p.Keyboard.GrabKey = types.GrabKeyNone
+
+	_, args = xephyr{}.command(p, "3", []string{"1920x1080"})
+	assert.Contains(t, args, "-no-host-grab")
+
+	_, args = xwayland{}.command(p, "3", []string{"1920x1080"})
+	assert.NotContains(t, args, "-host-grab")
+}
@@ -0,0 +1,96 @@
+package profiles
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/resolution"
+	"golang.org/x/sys/execabs"
+)
+
+// ResizeAuto resizes the display to the host's primary resolution.
+const ResizeAuto = "auto"
+
+var sizeRegex = regexp.MustCompile(`^[1-9][0-9]{1,4}x[1-9][0-9]{1,4}$`)
+
+// Resize resizes the display of the running profile p to size, which is
+// either WxH or auto. The nested X server is resized via RandR, so that
+// running workloads are kept.
+func Resize(runner string, p *types.Profile, size string) error {
+	if size == ResizeAuto {
+		res, err := resolution.Primary()
+		if err != nil {
+			return err
+		}
+		size = res
+	}
+	if !sizeRegex.MatchString(size) {
+		return fmt.Errorf("invalid display size %q: must be WxH or auto", size)
+	}
+
+	bin := files.ContainerRunnerBinary(runner)
+	cn := fmt.Sprintf(ContainerNameFormat, p.Name)
+	if !container.Running(bin, cn) {
+		return fmt.Errorf("profile %q is not running", p.Name)
+	}
+
+	args := []string{"exec", cn, "xrandr", "--display", ":" + strconv.Itoa(int(p.Display)), "--fb", size}
+	slog.Debug(bin+" exec", "container-name", cn, "args", args)
+	out, err := execabs.Command(bin, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to resize display of profile %q: %s: %w", p.Name, out, err)
+	}
+	return nil
+}
+
+// dpiArgs returns the Xephyr args that apply the DPI of p.
+func dpiArgs(p *types.Profile) []string {
+	dpi := p.EffectiveDPI()
+	if dpi == 0 {
+		return nil
+	}
+	return []string{"-dpi", strconv.Itoa(dpi)}
+}
+
+// setXftDPI sets the Xft.dpi resource of the profile's display, which
+// most toolkits use for scaling instead of the X server's DPI.
+func setXftDPI(bin, name, display string, dpi int) {
+	if dpi == 0 {
+		return
+	}
+
+	cmd := fmt.Sprintf("echo Xft.dpi: %d | xrdb -display :%s -merge", dpi, display)
+	out, err := execabs.Command(bin, "exec", name, files.ShBinary, "-c", cmd).CombinedOutput()
+	if err != nil {
+		slog.Warn("failed to set Xft.dpi", "output", string(out), "error", err)
+	}
+}
+
+// Attach opens a VNC viewer on the headless display of the running
+// profile p, returning once the viewer is closed.
+func Attach(p *types.Profile) error {
+	if newDisplayBackend(p).needsHost() {
+		return fmt.Errorf("profile %q does not have a headless display", p.Name)
+	}
+
+	socket := files.DisplaySocketPath(p.Name)
+	fi, err := os.Stat(socket)
+	if err != nil {
+		return fmt.Errorf("profile %q is not running or its display is not ready: %w", p.Name, err)
+	}
+	if fi.Mode().Type() != os.ModeSocket {
+		return fmt.Errorf("%q is not a socket", socket)
+	}
+
+	cmd := execabs.Command(files.VncViewerBinary, socket)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
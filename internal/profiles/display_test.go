@@ -0,0 +1,21 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDPIArgs(t *testing.T) {
+	assert.Nil(t, dpiArgs(&types.Profile{}))
+	assert.Equal(t, []string{"-dpi", "192"}, dpiArgs(&types.Profile{Scale: 2}))
+}
+
+func TestResizeInvalidSize(t *testing.T) {
+	for _, size := range []string{"", "1920", "1920x1080+0+0", "1920x1080; reboot"} {
+		err := Resize("docker", &types.Profile{Name: "p"}, size)
+		require.ErrorContains(t, err, "invalid display size", size)
+	}
+}
@@ -0,0 +1,160 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"gopkg.in/yaml.v3"
+)
+
+// historySize is the number of starts recorded per profile.
+const historySize = 10
+
+// ErrNoRollbackTarget is returned when a profile has no previous commit
+// to roll back to.
+var ErrNoRollbackTarget = errors.New("no previous commit to roll back to")
+
+// HistoryEntry records a git commit a profile was successfully started
+// from.
+type HistoryEntry struct {
+	Commit string `yaml:"commit"`
+	Ref    string `yaml:"ref,omitempty"`
+	GitURL string `yaml:"gitURL"`
+	Path   string `yaml:"path,omitempty"`
+	Local  string `yaml:"local,omitempty"`
+	// Signer is the identity that signed Commit, when it was verified.
+	Signer string    `yaml:"signer,omitempty"`
+	Time   time.Time `yaml:"time"`
+	// RolledBack is set when the profile was rolled back from this
+	// commit, so that it is not picked as a rollback target again.
+	RolledBack bool `yaml:"rolledBack,omitempty"`
+}
+
+// History returns the start history of a profile, newest first.
+func History(profile string) ([]HistoryEntry, error) {
+	fn, err := files.ProfileHistoryPath(profile)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(fn)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var h []HistoryEntry
+	if err := yaml.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal history file %q: %w", fn, err)
+	}
+
+	// Entries are only ever written with full commit hashes, anything
+	// else was edited by hand and cannot be started from.
+	valid := h[:0]
+	for _, e := range h {
+		if !plumbing.IsHash(e.Commit) {
+			slog.Warn("ignoring invalid history entry", "profile", profile, "commit", e.Commit)
+			continue
+		}
+		valid = append(valid, e)
+	}
+	return valid, nil
+}
+
+func saveHistory(profile string, h []HistoryEntry) error {
+	fn, err := files.ProfileHistoryPath(profile)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fn), files.DirMode); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fn, data, files.FileMode)
+}
+
+// recordStart adds e to the top of the profile's history, keeping only
+// the last historySize entries.
+func recordStart(profile string, e HistoryEntry) error {
+	h, err := History(profile)
+	if err != nil {
+		return err
+	}
+
+	h = append([]HistoryEntry{e}, h...)
+	if len(h) > historySize {
+		h = h[:historySize]
+	}
+	return saveHistory(profile, h)
+}
+
+// rollbackTarget returns the most recent entry in h whose commit differs
+// from the current one (h[0]) and has not been rolled back from. All
+// entries for the current commit are marked as rolled back.
+func rollbackTarget(h []HistoryEntry) (*HistoryEntry, error) {
+	if len(h) == 0 {
+		return nil, ErrNoRollbackTarget
+	}
+
+	current := h[0].Commit
+	var target *HistoryEntry
+	for i := range h {
+		if h[i].Commit == current {
+			h[i].RolledBack = true
+			continue
+		}
+		if target == nil && !h[i].RolledBack {
+			target = &h[i]
+		}
+	}
+
+	if target == nil {
+		return nil, ErrNoRollbackTarget
+	}
+	return target, nil
+}
+
+// Rollback restarts a profile at the last commit it was started from
+// before the current one. If the profile is running, it is stopped first.
+// The commit is always checked out in the clone owned by qubesome, so
+// that local checkouts are left on their branch.
+func Rollback(runner, name string) error {
+	h, err := History(name)
+	if err != nil {
+		return err
+	}
+
+	target, err := rollbackTarget(h)
+	if err != nil {
+		return fmt.Errorf("cannot rollback profile %q: %w", name, err)
+	}
+	e := *target
+
+	if err := saveHistory(name, h); err != nil {
+		return err
+	}
+
+	bin := files.ContainerRunnerBinary(runner)
+	if container.Running(bin, fmt.Sprintf(ContainerNameFormat, name)) {
+		if err := Stop(runner, name); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Rolling back profile %q to %s, run qubesome update to return to its branch\n", name, e.Commit[:7])
+	return StartFromGit(runner, name, e.GitURL, e.Path, "", e.Commit, e.Signer != "", false, false)
+}
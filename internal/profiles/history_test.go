@@ -0,0 +1,104 @@
+package profiles
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordStart(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	h, err := History("i3")
+	require.NoError(t, err)
+	assert.Empty(t, h)
+
+	for i := 0; i < historySize+2; i++ {
+		err := recordStart("i3", HistoryEntry{Commit: fmt.Sprintf("%040d", i)})
+		require.NoError(t, err)
+	}
+
+	h, err = History("i3")
+	require.NoError(t, err)
+	require.Len(t, h, historySize)
+	assert.Equal(t, fmt.Sprintf("%040d", historySize+1), h[0].Commit)
+	assert.Equal(t, fmt.Sprintf("%040d", 2), h[historySize-1].Commit)
+}
+
+func TestHistoryIgnoresInvalidCommits(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	valid := fmt.Sprintf("%040d", 1)
+	require.NoError(t, saveHistory("i3", []HistoryEntry{
+		{Commit: ""},
+		{Commit: "abc"},
+		{Commit: valid},
+	}))
+
+	h, err := History("i3")
+	require.NoError(t, err)
+	require.Len(t, h, 1)
+	assert.Equal(t, valid, h[0].Commit)
+}
+
+func TestRollbackTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []HistoryEntry
+		want    string
+		marked  []bool
+		wantErr error
+	}{
+		{
+			name:    "no history",
+			wantErr: ErrNoRollbackTarget,
+		},
+		{
+			name:    "single commit",
+			history: []HistoryEntry{{Commit: "a"}, {Commit: "a"}},
+			marked:  []bool{true, true},
+			wantErr: ErrNoRollbackTarget,
+		},
+		{
+			name:    "previous commit",
+			history: []HistoryEntry{{Commit: "c"}, {Commit: "b"}, {Commit: "a"}},
+			want:    "b",
+			marked:  []bool{true, false, false},
+		},
+		{
+			name:    "skips restarts at the current commit",
+			history: []HistoryEntry{{Commit: "c"}, {Commit: "c"}, {Commit: "b"}},
+			want:    "b",
+			marked:  []bool{true, true, false},
+		},
+		{
+			name: "skips rolled back commits",
+			history: []HistoryEntry{
+				{Commit: "b"},
+				{Commit: "c", RolledBack: true},
+				{Commit: "b"},
+				{Commit: "a"},
+			},
+			want:   "a",
+			marked: []bool{true, true, true, false},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := rollbackTarget(tc.history)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tc.want, got.Commit)
+			}
+
+			for i, e := range tc.history {
+				assert.Equal(t, tc.marked[i], e.RolledBack, "entry %d", i)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package profiles
+
+import (
+	"log/slog"
+
+	"github.com/qubesome/cli/internal/types"
+	"golang.org/x/sys/execabs"
+)
+
+// setxkbmapArgs returns the setxkbmap args that apply k to display.
+func setxkbmapArgs(k *types.Keyboard, display string) []string {
+	if k == nil || (k.Layout == "" && k.Variant == "" && k.Model == "" && len(k.Options) == 0) {
+		return nil
+	}
+
+	args := []string{"setxkbmap", "-display", ":" + display}
+	if k.Model != "" {
+		args = append(args, "-model", k.Model)
+	}
+	if k.Layout != "" {
+		args = append(args, "-layout", k.Layout)
+	}
+	if k.Variant != "" {
+		args = append(args, "-variant", k.Variant)
+	}
+	if len(k.Options) > 0 {
+		// An empty option clears the options set by the X server.
+		args = append(args, "-option", "")
+		for _, o := range k.Options {
+			args = append(args, "-option", o)
+		}
+	}
+	return args
+}
+
+// setKeyboard applies the keyboard settings of the profile to its
+// display.
+func setKeyboard(bin, name, display string, k *types.Keyboard) {
+	args := setxkbmapArgs(k, display)
+	if args == nil {
+		return
+	}
+
+	args = append([]string{"exec", name}, args...)
+	slog.Debug(bin+" exec", "container-name", name, "args", args)
+	out, err := execabs.Command(bin, args...).CombinedOutput()
+	if err != nil {
+		slog.Warn("failed to set keyboard layout", "output", string(out), "error", err)
+	}
+}
@@ -0,0 +1,53 @@
+package profiles
+
+import (
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetxkbmapArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyboard *types.Keyboard
+		want     []string
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:     "grab key only",
+			keyboard: &types.Keyboard{GrabKey: "none"},
+		},
+		{
+			name:     "layout",
+			keyboard: &types.Keyboard{Layout: "de"},
+			want:     []string{"setxkbmap", "-display", ":3", "-layout", "de"},
+		},
+		{
+			name: "all settings",
+			keyboard: &types.Keyboard{
+				Model:   "pc105",
+				Layout:  "us,de",
+				Variant: "dvorak,",
+				Options: []string{"ctrl:nocaps", "grp:alt_shift_toggle"},
+			},
+			want: []string{
+				"setxkbmap", "-display", ":3",
+				"-model", "pc105",
+				"-layout", "us,de",
+				"-variant", "dvorak,",
+				"-option", "",
+				"-option", "ctrl:nocaps",
+				"-option", "grp:alt_shift_toggle",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, setxkbmapArgs(tc.keyboard, "3"))
+		})
+	}
+}
@@ -8,9 +8,16 @@ type Options struct {
 	GitURL      string
 	Path        string
 	Local       string
+	Ref         string
 	Profile     string
 	Runner      string
 	Interactive bool
+	// RequireSigned requires the git commit the profile is started from
+	// to be signed by an allowed signer.
+	RequireSigned bool
+	// Accept accepts new host access granted by the profile's workloads
+	// without confirmation.
+	Accept bool
 }
 
 func WithGitURL(gitURL string) command.Option[Options] {
@@ -31,6 +38,12 @@ func WithLocal(local string) command.Option[Options] {
 	}
 }
 
+func WithRef(ref string) command.Option[Options] {
+	return func(o *Options) {
+		o.Ref = ref
+	}
+}
+
 func WithProfile(profile string) command.Option[Options] {
 	return func(o *Options) {
 		o.Profile = profile
@@ -48,3 +61,15 @@ func WithInteractive() command.Option[Options] {
 		o.Interactive = true
 	}
 }
+
+func WithRequireSigned() command.Option[Options] {
+	return func(o *Options) {
+		o.RequireSigned = true
+	}
+}
+
+func WithAccept() command.Option[Options] {
+	return func(o *Options) {
+		o.Accept = true
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -15,19 +16,22 @@ import (
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/go-git/go-git/v6"
-	"github.com/go-git/go-git/v6/plumbing/client"
-	"github.com/go-git/go-git/v6/plumbing/transport/ssh"
 	"github.com/google/uuid"
 	"github.com/qubesome/cli/internal/command"
+	"github.com/qubesome/cli/internal/egress"
 	"github.com/qubesome/cli/internal/files"
 	"github.com/qubesome/cli/internal/images"
 	"github.com/qubesome/cli/internal/keyring"
 	"github.com/qubesome/cli/internal/keyring/backend"
+	"github.com/qubesome/cli/internal/permissions"
+	"github.com/qubesome/cli/internal/resolver"
+	"github.com/qubesome/cli/internal/runners/firecracker"
 	"github.com/qubesome/cli/internal/runners/util/container"
 	"github.com/qubesome/cli/internal/types"
 	"github.com/qubesome/cli/internal/util/dbus"
 	"github.com/qubesome/cli/internal/util/drive"
 	"github.com/qubesome/cli/internal/util/env"
+	"github.com/qubesome/cli/internal/util/gitrepo"
 	"github.com/qubesome/cli/internal/util/gpu"
 	"github.com/qubesome/cli/internal/util/mtls"
 	"github.com/qubesome/cli/internal/util/resolution"
@@ -40,7 +44,9 @@ import (
 
 var (
 	ContainerNameFormat = "qubesome-%s"
+	stopTimeout         = 10 * time.Second
 	defaultProfileImage = "ghcr.io/qubesome/xorg:latest"
+	defaultShmSize      = "128m"
 
 	appTemplate = `[Desktop Entry]
 Version=1.0
@@ -61,7 +67,11 @@ func Run(opts ...command.Option[Options]) error {
 	}
 
 	if o.GitURL != "" {
-		return StartFromGit(o.Runner, o.Profile, o.GitURL, o.Path, o.Local, o.Interactive)
+		return StartFromGit(o.Runner, o.Profile, o.GitURL, o.Path, o.Local, o.Ref, o.RequireSigned, o.Interactive, o.Accept)
+	}
+
+	if o.Ref != "" {
+		return fmt.Errorf("-ref can only be used in combination with -git")
 	}
 
 	if o.Local != "" {
@@ -92,7 +102,7 @@ func Run(opts ...command.Option[Options]) error {
 		return fmt.Errorf("cannot start profile: profile %q not found", o.Profile)
 	}
 
-	return Start(o.Runner, profile, cfg, o.Interactive)
+	return Start(o.Runner, profile, cfg, o.Interactive, o.Accept)
 }
 
 func validGitDir(path string) bool {
@@ -110,7 +120,12 @@ func validGitDir(path string) bool {
 	return err == nil
 }
 
-func StartFromGit(runner, name, gitURL, path, local string, interactive bool) error {
+// StartFromGit starts a profile sourced from a git repository. When ref is
+// set, it is checked out before the config is loaded. When signed commits
+// are required, the checked out commit must be signed by an allowed signer.
+// Successful starts are recorded in the profile history, which is used by
+// Rollback.
+func StartFromGit(runner, name, gitURL, path, local, ref string, requireSigned, interactive, accept bool) error {
 	ln := files.ProfileConfig(name)
 
 	if _, err := os.Lstat(ln); err == nil {
@@ -142,22 +157,44 @@ func StartFromGit(runner, name, gitURL, path, local string, interactive bool) er
 	} else {
 		slog.Debug("cloning repo to start")
 
-		var clientOptions []client.Option
-		if strings.HasPrefix(gitURL, "git@") {
-			a, err := ssh.NewSSHAgentAuth("git")
-			if err != nil {
-				return err
-			}
-			clientOptions = append(clientOptions, client.WithSSHAuth(a))
+		err = gitrepo.Clone(dir, gitURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ref != "" {
+		slog.Debug("checking out ref", "ref", ref, "path", dir)
+		if _, err := gitrepo.Checkout(dir, ref); err != nil {
+			return err
 		}
+	} else if detached, err := gitrepo.Detached(dir); err == nil && detached {
+		fmt.Println("\033[33mWARN: profile is pinned to a commit (e.g. by a rollback), run qubesome update to move it back onto its branch.\033[0m")
+	}
+
+	head, err := gitrepo.Head(dir)
+	if err != nil {
+		return err
+	}
 
-		_, err = git.PlainClone(dir, &git.CloneOptions{
-			URL:           gitURL,
-			ClientOptions: clientOptions,
-		})
+	keyring, err := AllowedSigners(requireSigned)
+	if err != nil {
+		return err
+	}
+
+	var signer string
+	if keyring != "" {
+		r, err := git.PlainOpen(dir)
 		if err != nil {
 			return err
 		}
+
+		signer, err = gitrepo.Verify(r, head, keyring)
+		if err != nil {
+			dbus.NotifyOrLog("qubesome start error", fmt.Sprintf("refusing to start profile %q: commit %s failed verification", name, head.String()[:7]))
+			return fmt.Errorf("refusing to start profile %q: %w", name, err)
+		}
+		slog.Debug("commit signature verified", "commit", head, "signer", signer)
 	}
 
 	// This is a Git setup, and now we know the Git Dir, so enable
@@ -203,12 +240,31 @@ func StartFromGit(runner, name, gitURL, path, local string, interactive bool) er
 	}
 	p.Path = pp
 
-	slog.Debug("start from git", "profile", p.Name, "p", path, "path", p.Path, "config", cfgPath)
+	slog.Debug("start from git", "profile", p.Name, "p", path, "path", p.Path, "config", cfgPath, "commit", head)
 
-	return Start(runner, p, cfg, interactive)
+	entry := HistoryEntry{
+		Commit: head.String(),
+		Ref:    ref,
+		GitURL: gitURL,
+		Path:   path,
+		Local:  local,
+		Signer: signer,
+		Time:   time.Now(),
+	}
+	return start(runner, p, cfg, interactive, accept, func() {
+		if err := recordStart(name, entry); err != nil {
+			slog.Warn("failed to record profile history", "error", err)
+		}
+	})
 }
 
-func Start(runner string, profile *types.Profile, cfg *types.Config, interactive bool) (err error) {
+func Start(runner string, profile *types.Profile, cfg *types.Config, interactive, accept bool) error {
+	return start(runner, profile, cfg, interactive, accept, nil)
+}
+
+// start starts the profile, calling started (if not nil) once the
+// profile display is up.
+func start(runner string, profile *types.Profile, cfg *types.Config, interactive, accept bool, started func()) (err error) {
 	if cfg == nil {
 		return fmt.Errorf("cannot start profile: config is nil")
 	}
@@ -222,6 +278,10 @@ func Start(runner string, profile *types.Profile, cfg *types.Config, interactive
 		return err
 	}
 
+	if err := checkPermissions(profile, cfg, accept); err != nil {
+		return err
+	}
+
 	// If runner is not being overwritten (via -runner), use the runner
 	// set at profile level in the config.
 	if runner == "" && profile.Runner != "" {
@@ -234,13 +294,13 @@ func Start(runner string, profile *types.Profile, cfg *types.Config, interactive
 		return fmt.Errorf("could not find container runner %q", binary)
 	}
 
-	imgs, err := images.MissingImages(binary, cfg)
+	imgs, err := images.MissingImages(runner, cfg)
 	if err != nil {
 		return err
 	}
 
 	for _, img := range imgs {
-		if img == profile.Image {
+		if img.Name == profile.Image {
 			fmt.Println("Pulling profile image:", profile.Image)
 			err = images.PullImageIfNotPresent(binary, profile.Image)
 			if err != nil {
@@ -251,7 +311,7 @@ func Start(runner string, profile *types.Profile, cfg *types.Config, interactive
 
 	if len(imgs) > 1 && term.IsTerminal(int(os.Stdout.Fd())) { //nolint:gosec // G115: fd values fit in int
 		if proceed("Not all workload images are present. Start loading them on the background?") {
-			go images.PreemptWorkloadImages(binary, cfg)
+			go images.PreemptWorkloadImages(runner, cfg)
 		}
 	}
 
@@ -333,38 +393,165 @@ func Start(runner string, profile *types.Profile, cfg *types.Config, interactive
 		}
 	}()
 
+	if err := container.CheckGatewayEngine(binary, profile); err != nil {
+		return err
+	}
+	if err := container.EnsureNetwork(binary, profile); err != nil {
+		return err
+	}
+	defer func() {
+		if err := container.RemoveNetwork(binary, profile); err != nil {
+			slog.Warn("failed to remove managed network", "error", err)
+		}
+	}()
+
+	if profile.Egress != nil {
+		addr, err := profile.EgressProxy()
+		if err != nil {
+			return err
+		}
+		l, err := egress.Listen(addr)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+
+		proxy := egress.New(profile.Name, *profile.Egress, egress.ContainerLookup(binary, profile.NetworkName()))
+		go func() {
+			if err := proxy.Serve(l); err != nil {
+				slog.Warn("egress proxy stopped", "error", err)
+			}
+		}()
+	}
+
+	if profile.Resolver != nil {
+		gw, err := profile.ManagedNetwork.Gateway()
+		if err != nil {
+			return err
+		}
+		r, err := resolver.New(profile)
+		if err != nil {
+			return err
+		}
+		c, err := r.ListenAndServe(netip.AddrPortFrom(gw, types.ResolverPort).String())
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+	}
+
+	backend := newDisplayBackend(profile)
 	err = createNewDisplay(binary,
 		creds.CA, creds.ClientPEM, creds.ClientKeyPEM,
-		profile, strconv.Itoa(int(profile.Display)), interactive, cfg)
+		profile, backend, strconv.Itoa(int(profile.Display)), interactive, cfg)
 	if err != nil {
 		slog.Warn("failed to create display", "error", err)
 		return err
 	}
 
-	// In Wayland, Xephyr is replaced by xwayland-run, which can
-	// run the Window Manager directly, without the need of a exec
-	// into the container to trigger it.
-	if !strings.EqualFold(os.Getenv("XDG_SESSION_TYPE"), "wayland") {
-		name := fmt.Sprintf(ContainerNameFormat, profile.Name)
+	// Interactive displays run in the foreground, so by now the user
+	// has successfully used and exited them.
+	if started != nil && interactive {
+		started()
+	}
+
+	// The resolver and egress proxy listen on the gateway before it
+	// exists, so check that it became a host address once the profile
+	// container joined the network.
+	if err := container.WaitHostGateway(profile, 5*time.Second); err != nil {
+		return err
+	}
+
+	// Backends such as xwayland-run can run the Window Manager
+	// directly, without the need of a exec into the container to
+	// trigger it.
+	name := fmt.Sprintf(ContainerNameFormat, profile.Name)
 
+	if !backend.runsWindowManager() && !container.Running(binary, name) {
 		// If xhost access control is enabled, it may block qubesome
 		// execution. A tail sign is the profile container dying early.
-		if !container.Running(binary, name) {
-			msg := os.ExpandEnv("run xhost +SI:localhost:${USER} and try again")
-			dbus.NotifyOrLog("qubesome start error", msg)
-			return fmt.Errorf("failed to start profile: %s", msg)
+		msg := "profile container exited early"
+		if backend.needsHost() {
+			msg = os.ExpandEnv("run xhost +SI:localhost:${USER} and try again")
 		}
+		dbus.NotifyOrLog("qubesome start error", msg)
+		return fmt.Errorf("failed to start profile: %s", msg)
+	}
+
+	if started != nil && !interactive {
+		started()
+	}
+
+	if !backend.runsWindowManager() {
+		setXftDPI(binary, name, strconv.Itoa(int(profile.Display)), profile.EffectiveDPI())
+		setKeyboard(binary, name, strconv.Itoa(int(profile.Display)), profile.Keyboard)
 
 		err = startWindowManager(binary, name, strconv.Itoa(int(profile.Display)), profile.WindowManager)
 		if err != nil {
 			return err
 		}
+	} else if setxkbmapArgs(profile.Keyboard, "") != nil {
+		slog.Warn("keyboard layout is not applied on Wayland, where it follows the compositor")
 	}
 
 	wg.Wait()
 	return nil
 }
 
+// checkPermissions surfaces any host access granted by the profile's
+// workloads which was not previously accepted.
+func checkPermissions(profile *types.Profile, cfg *types.Config, accept bool) error {
+	dir := profile.Path
+	if !filepath.IsAbs(dir) {
+		var err error
+		dir, err = securejoin.SecureJoin(cfg.RootDir, profile.Path)
+		if err != nil {
+			return err
+		}
+	}
+
+	g, err := permissions.Load(profile, dir)
+	if err != nil {
+		return fmt.Errorf("cannot compute profile permissions: %w", err)
+	}
+	return permissions.Check(profile.Name, g, accept)
+}
+
+// Stop stops a running profile and waits for its qubesome process to
+// clean up after itself. Its firecracker workloads are shut down first,
+// as they depend on the profile's display.
+func Stop(runner, name string) error {
+	bin := files.ContainerRunnerBinary(runner)
+	cn := fmt.Sprintf(ContainerNameFormat, name)
+	if !container.Running(bin, cn) {
+		return fmt.Errorf("profile %q is not running", name)
+	}
+
+	if err := firecracker.StopProfile(name); err != nil {
+		slog.Warn("failed to stop firecracker workloads", "profile", name, "error", err)
+	}
+
+	slog.Debug(bin+" stop", "container-name", cn)
+	output, err := execabs.Command(bin, "stop", cn).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to stop profile %q: %s: %w", name, output, err)
+	}
+
+	// The process that started the profile removes the profile config
+	// symlink once the profile container is gone.
+	ln := files.ProfileConfig(name)
+	t := time.Now().Add(stopTimeout)
+	for t.After(time.Now()) {
+		if _, err := os.Lstat(ln); err != nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	slog.Debug("profile config not cleaned up in time, removing it", "path", ln)
+	return os.Remove(ln)
+}
+
 func proceed(prompt string) bool {
 	reader := bufio.NewReader(os.Stdin)
 	for {
@@ -441,43 +628,17 @@ func startWindowManager(bin, name, display, wm string) error {
 	return nil
 }
 
-func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile, display string, interactive bool, cfg *types.Config) error {
-	command := "Xephyr"
+func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile, backend displayBackend, display string, interactive bool, cfg *types.Config) error {
 	res, err := resolution.Primary()
 	if err != nil {
 		return err
 	}
-	cArgs := []string{
-		":" + display,
-		"-title", fmt.Sprintf("qubesome-%s :%s", profile.Name, display),
-		"-auth", "/home/xorg-user/.Xserver",
-		"-extension", "MIT-SHM",
-		"-extension", "XTEST",
-		"-nopn",
-		"-nolisten", "tcp",
-		"-screen", res,
-		"-resizeable",
-	}
-	if profile.XephyrArgs != "" {
-		cArgs = append(cArgs, strings.Split(profile.XephyrArgs, " ")...)
+	geometries := screenGeometries(profile.Screens, resolution.Outputs, res)
+	if _, ok := backend.(xephyr); !ok && len(geometries) > 1 {
+		slog.Warn("multiple screens are only supported by Xephyr, using the first one")
 	}
 
-	if strings.EqualFold(os.Getenv("XDG_SESSION_TYPE"), "wayland") {
-		command = "xwayland-run"
-		cArgs = []string{
-			"-host-grab",
-			"-geometry", res,
-			"-extension", "MIT-SHM",
-			"-extension", "XTEST",
-			"-nopn",
-			"-tst",
-			"-nolisten", "tcp",
-			"-auth", "/home/xorg-user/.Xserver",
-			"-verbose", "9",
-			"--",
-			strings.TrimPrefix(profile.WindowManager, "exec "),
-		}
-	}
+	command, cArgs := backend.command(profile, display, geometries)
 
 	server, err := files.ServerCookiePath(profile.Name)
 	if err != nil {
@@ -521,7 +682,11 @@ func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile,
 	}
 
 	x11Dir := "/tmp/.X11-unix"
-	if os.Getenv("WSL_DISTRO_NAME") != "" {
+	if !backend.needsHost() {
+		if err := ensureX11Dir(x11Dir); err != nil {
+			return err
+		}
+	} else if os.Getenv("WSL_DISTRO_NAME") != "" {
 		fmt.Println("\033[33mWARN: Running qubesome in WSL is experimental. Some features may not work as expected.\033[0m")
 		fp, err := filepath.EvalSymlinks(x11Dir)
 		if err != nil {
@@ -568,49 +733,28 @@ func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile,
 		dockerArgs = append(dockerArgs, "-d")
 	}
 
-	if strings.HasSuffix(bin, "podman") {
-		dockerArgs = append(dockerArgs, "--userns=keep-id")
-	}
-	if strings.EqualFold(os.Getenv("XDG_SESSION_TYPE"), "wayland") {
-		xdgRuntimeDir := os.Getenv("XDG_RUNTIME_DIR")
-		if xdgRuntimeDir == "" {
-			uid := os.Getuid()
-			if uid < 1000 {
-				return fmt.Errorf("qubesome does not support running under privileged users")
-			}
-			xdgRuntimeDir = "/run/user/" + strconv.Itoa(uid)
-		}
-
-		// TODO: Investigate ways to avoid sharing /run/user/1000 on Wayland.
-		dockerArgs = append(dockerArgs, "-e", "XDG_RUNTIME_DIR")
-		dockerArgs = append(dockerArgs, "-e", "XDG_BACKEND")
-		dockerArgs = append(dockerArgs, "-e", "XDG_SEAT")
-		dockerArgs = append(dockerArgs, "-e", "XDG_SESSION_TYPE")
-		dockerArgs = append(dockerArgs, "-e", "XDG_SESSION_ID")
-		dockerArgs = append(dockerArgs, "-e", "XDG_SESSION_CLASS")
-		dockerArgs = append(dockerArgs, "-e", "XDG_SESSION_DESKTOP")
-		dockerArgs = append(dockerArgs, "-e", "WAYLAND_DISPLAY")
-		dockerArgs = append(dockerArgs, "-e", "HYPRLAND_INSTANCE_SIGNATURE")
-		dockerArgs = append(dockerArgs, "-v="+xdgRuntimeDir+":/run/user/1000")
-	} else {
-		dockerArgs = append(dockerArgs, "-e", "XDG_SESSION_TYPE=X11")
+	dockerArgs = append(dockerArgs, container.UserArgs(bin, nil)...)
+	backendArgs, err := backend.containerArgs(profile)
+	if err != nil {
+		return err
 	}
+	dockerArgs = append(dockerArgs, backendArgs...)
 	if profile.Gpus != "" {
 		if gpus, ok := gpu.Supported(profile.Runner); ok {
 			dockerArgs = append(dockerArgs, gpus)
 		}
 	}
 
-	if profile.DNS != "" {
-		dockerArgs = append(dockerArgs, "--dns", profile.DNS)
+	for _, dns := range profile.DNSServers() {
+		dockerArgs = append(dockerArgs, "--dns", dns)
 	}
 
-	if profile.Network == "" {
+	if profile.NetworkName() == "" {
 		// Generally, xorg does not require network access so by
 		// default sets network to none.
 		dockerArgs = append(dockerArgs, "--network=none")
 	} else {
-		dockerArgs = append(dockerArgs, "--network="+profile.Network)
+		dockerArgs = append(dockerArgs, "--network="+profile.NetworkName())
 	}
 
 	// Write the machine-id file regardless of the profile using host dbus or not,
@@ -640,7 +784,7 @@ func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile,
 		paths = append(paths, "-v=/run/dbus/system_bus_socket:/run/dbus/system_bus_socket")
 		paths = append(paths, "-v=/etc/machine-id:/etc/machine-id:ro")
 	} else {
-		paths = append(paths, fmt.Sprintf("-v=%s:/run/user/1000", userDir))
+		paths = append(paths, fmt.Sprintf("-v=%s:%s", userDir, files.ContainerRuntimeDir))
 		paths = append(paths, fmt.Sprintf("-v=%s:/etc/machine-id:ro", machineIDPath))
 	}
 
@@ -653,7 +797,11 @@ func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile,
 
 	// Share IPC from the profile container to its workloads.
 	// dockerArgs = append(dockerArgs, "--ipc=shareable")
-	dockerArgs = append(dockerArgs, "--shm-size=128m")
+	shmSize := defaultShmSize
+	if profile.Resources.ShmSize != "" {
+		shmSize = profile.Resources.ShmSize
+	}
+	dockerArgs = append(dockerArgs, "--shm-size="+shmSize)
 
 	dockerArgs = append(dockerArgs, fmt.Sprintf("--name=%s", fmt.Sprintf(ContainerNameFormat, profile.Name)))
 	dockerArgs = append(dockerArgs, profile.Image)
@@ -665,9 +813,11 @@ func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile,
 		dockerArgs = append(dockerArgs, command)
 		dockerArgs = append(dockerArgs, cArgs...)
 
-		fmt.Println(
-			"INFO: For best experience use input grabber shortcuts:",
-			grabberShortcut())
+		if backend.needsHost() && !profile.Keyboard.NoGrab() {
+			fmt.Println(
+				"INFO: For best experience use input grabber shortcuts:",
+				grabberShortcut())
+		}
 	}
 
 	slog.Debug("exec", "binary", bin, "args", dockerArgs) //nolint:gosec // G706: binary path is from trusted config
@@ -695,6 +845,13 @@ func createNewDisplay(bin string, ca, cert, key []byte, profile *types.Profile,
 	if err != nil {
 		return fmt.Errorf("%s: %w", output, err)
 	}
+
+	if !backend.needsHost() {
+		if err := checkHeadless(bin, fmt.Sprintf(ContainerNameFormat, profile.Name)); err != nil {
+			return err
+		}
+		fmt.Printf("INFO: Headless display started, view it with: qubesome attach %s\n", profile.Name)
+	}
 	return nil
 }
 
@@ -962,3 +1119,14 @@ func writeMachineID(path string) error {
 	}
 	return nil
 }
+
+// ensureX11Dir creates the X11 socket dir on hosts without a display.
+func ensureX11Dir(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.Mkdir(dir, 0o777); err != nil {
+		return fmt.Errorf("failed to create %q: %w", dir, err)
+	}
+	return os.Chmod(dir, 0o777|os.ModeSticky)
+}
@@ -0,0 +1,64 @@
+package profiles
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/resolution"
+)
+
+// screenGeometries returns the geometries of the screens of a profile's
+// display, based on the host outputs and its primary resolution.
+func screenGeometries(s *types.Screens, outputs func() ([]resolution.Output, error), primary string) []string {
+	switch {
+	case s == nil:
+		return []string{primary}
+
+	case s.Auto:
+		outs, err := outputs()
+		if err != nil || len(outs) == 0 {
+			slog.Warn("cannot detect outputs, using a single screen", "error", err)
+			return []string{primary}
+		}
+
+		geometries := make([]string, 0, len(outs))
+		for _, o := range outs {
+			geometries = append(geometries, fmt.Sprintf("%s+%d+%d", o.Geometry(), o.X, o.Y))
+		}
+		return geometries
+
+	case len(s.Geometries) > 0:
+		return s.Geometries
+
+	case s.Count > 1:
+		geometries := make([]string, s.Count)
+		for i := range geometries {
+			geometries[i] = primary
+		}
+		return geometries
+	}
+
+	return []string{primary}
+}
+
+// screenArgs returns the Xephyr args for geometries. Multiple screens are
+// combined with Xinerama, so that they are seen as monitors of a single
+// screen by the window manager.
+func screenArgs(geometries []string) []string {
+	var args []string
+	for _, g := range geometries {
+		args = append(args, "-screen", g)
+	}
+	if len(geometries) > 1 {
+		args = append(args, "+xinerama")
+	}
+	return args
+}
+
+// size returns the size of geometry, without its offset.
+func size(geometry string) string {
+	s, _, _ := strings.Cut(geometry, "+")
+	return s
+}
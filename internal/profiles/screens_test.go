@@ -0,0 +1,71 @@
+package profiles
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/resolution"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScreenGeometries(t *testing.T) {
+	outputs := func() ([]resolution.Output, error) {
+		return []resolution.Output{
+			{Name: "HDMI-1", Width: 2560, Height: 1440, Primary: true},
+			{Name: "eDP-1", Width: 1920, Height: 1080, X: 2560},
+		}, nil
+	}
+	noOutputs := func() ([]resolution.Output, error) {
+		return nil, errors.New("no xrandr")
+	}
+
+	tests := []struct {
+		name    string
+		screens *types.Screens
+		outputs func() ([]resolution.Output, error)
+		want    []string
+	}{
+		{
+			name: "not set",
+			want: []string{"2560x1440"},
+		},
+		{
+			name:    "auto",
+			screens: &types.Screens{Auto: true},
+			outputs: outputs,
+			want:    []string{"2560x1440+0+0", "1920x1080+2560+0"},
+		},
+		{
+			name:    "auto without outputs",
+			screens: &types.Screens{Auto: true},
+			outputs: noOutputs,
+			want:    []string{"2560x1440"},
+		},
+		{
+			name:    "count",
+			screens: &types.Screens{Count: 2},
+			want:    []string{"2560x1440", "2560x1440"},
+		},
+		{
+			name:    "geometries",
+			screens: &types.Screens{Geometries: []string{"1920x1080", "1280x1024+1920+0"}},
+			want:    []string{"1920x1080", "1280x1024+1920+0"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, screenGeometries(tc.screens, tc.outputs, "2560x1440"))
+		})
+	}
+}
+
+func TestScreenArgs(t *testing.T) {
+	assert.Equal(t, []string{"-screen", "1920x1080"}, screenArgs([]string{"1920x1080"}))
+	assert.Equal(t, []string{
+		"-screen", "1920x1080+0+0",
+		"-screen", "1280x1024+1920+0",
+		"+xinerama",
+	}, screenArgs([]string{"1920x1080+0+0", "1280x1024+1920+0"}))
+}
@@ -0,0 +1,51 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+)
+
+// AllowedSigners returns the armored keyring that git commits must be
+// signed with, or an empty string when signed commits are not required.
+//
+// Signed commits are required when require is set or when the user-level
+// config enables requireSignedCommits. The setting is never sourced from
+// the git repository itself, as that is what is being verified.
+func AllowedSigners(require bool) (string, error) {
+	path := files.AllowedSignersPath()
+
+	cfgPath := files.QubesomeConfig()
+	if _, err := os.Stat(cfgPath); err == nil {
+		cfg, err := types.LoadConfig(cfgPath)
+		if err != nil {
+			return "", err
+		}
+		require = require || cfg.RequireSignedCommits
+		if cfg.AllowedSigners != "" {
+			path = os.ExpandEnv(cfg.AllowedSigners)
+		}
+	}
+
+	if !require {
+		return "", nil
+	}
+
+	slog.Debug("signed commits required", "allowed-signers", path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("signed commits are required but allowed signers file %q does not exist", path)
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("signed commits are required but allowed signers file %q is empty", path)
+	}
+
+	return string(data), nil
+}
@@ -15,8 +15,10 @@ import (
 	"github.com/qubesome/cli/internal/files"
 	"github.com/qubesome/cli/internal/images"
 	"github.com/qubesome/cli/internal/inception"
+	"github.com/qubesome/cli/internal/runners/bwrap"
 	"github.com/qubesome/cli/internal/runners/docker"
 	"github.com/qubesome/cli/internal/runners/firecracker"
+	"github.com/qubesome/cli/internal/runners/nerdctl"
 	"github.com/qubesome/cli/internal/runners/podman"
 	"github.com/qubesome/cli/internal/types"
 	"github.com/qubesome/cli/internal/util/dbus"
@@ -65,8 +67,7 @@ func Run(opts ...command.Option[Options]) error {
 	}
 
 	wg := sync.WaitGroup{}
-	bin := files.ContainerRunnerBinary(o.Runner)
-	if err := images.Pull(bin, o.Config, &wg); err != nil {
+	if err := images.Pull(o.Runner, o.Config, &wg); err != nil {
 		return err
 	}
 	in := WorkloadInfo{
@@ -173,6 +174,21 @@ func runner(in WorkloadInfo, runnerOverride string, headless bool) error {
 	w.Name = in.Name
 
 	ew := w.ApplyProfile(profile)
+	if ew.Workload.Runtime != w.Runtime {
+		err := fmt.Errorf("workload %s uses runtime %q which is not allowed by the profile", in.Name, w.Runtime)
+		dbus.NotifyOrLog("qubesome: access denied", err.Error())
+
+		return err
+	}
+
+	if ew.Workload.Security != w.Security {
+		err := fmt.Errorf("workload %s disables %s confinement which is forbidden by the profile",
+			in.Name, strings.Join(w.Security.Unconfined(), ", "))
+		dbus.NotifyOrLog("qubesome: access denied", err.Error())
+
+		return err
+	}
+
 	if !reflect.DeepEqual(ew.Workload.HostAccess, w.HostAccess) {
 		msg := diffMessage(w, ew)
 		if len(msg) > 0 {
@@ -196,7 +212,8 @@ func runner(in WorkloadInfo, runnerOverride string, headless bool) error {
 
 	ew.Workload.Args = append(ew.Workload.Args, in.Args...)
 
-	if runnerOverride != "" {
+	// Image-less bwrap workloads cannot be run by any other runner.
+	if runnerOverride != "" && (ew.Workload.Runner != "bwrap" || ew.Workload.Image != "") {
 		ew.Workload.Runner = runnerOverride
 	}
 
@@ -206,10 +223,14 @@ func runner(in WorkloadInfo, runnerOverride string, headless bool) error {
 	}
 
 	switch ew.Workload.Runner {
+	case "bwrap":
+		return bwrap.Run(ew)
 	case "firecracker":
 		return firecracker.Run(ew)
 	case "podman":
 		return podman.Run(ew)
+	case "nerdctl":
+		return nerdctl.Run(ew)
 
 	default:
 		return docker.Run(ew)
@@ -0,0 +1,64 @@
+package resolver
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+)
+
+// blocklist holds the blocked domains, in lower case and without the
+// trailing dot.
+type blocklist map[string]struct{}
+
+// loadBlockLists reads the block lists at paths, which are relative to
+// dir. Lines hold a single domain, or use the hosts file format, e.g.
+// "0.0.0.0 ads.example.com". Empty lines and comments are ignored.
+func loadBlockLists(dir string, paths []string) (blocklist, error) {
+	b := blocklist{}
+	for _, p := range paths {
+		fn, err := securejoin.SecureJoin(dir, p)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open block list: %w", err)
+		}
+
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line, _, _ := strings.Cut(s.Text(), "#")
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			b[normalise(fields[len(fields)-1])] = struct{}{}
+		}
+		f.Close()
+
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("cannot read block list %q: %w", p, err)
+		}
+	}
+	return b, nil
+}
+
+// blocks returns whether name, or any of its parent domains, is blocked.
+func (b blocklist) blocks(name string) bool {
+	name = normalise(name)
+	for name != "" {
+		if _, ok := b[name]; ok {
+			return true
+		}
+		_, name, _ = strings.Cut(name, ".")
+	}
+	return false
+}
+
+func normalise(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
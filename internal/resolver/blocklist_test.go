@@ -0,0 +1,45 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockList(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ads.txt"), []byte(`# ads
+ads.example.com
+0.0.0.0 Tracker.IO.  # hosts format
+
+`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "social.txt"), []byte("social.net\n"), 0o600))
+
+	b, err := loadBlockLists(dir, []string{"ads.txt", "social.txt"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"ads.example.com.", true},
+		{"eu.ads.example.com.", true},
+		{"example.com.", false},
+		{"tracker.io.", true},
+		{"cdn.TRACKER.io.", true},
+		{"social.net.", true},
+		{"antisocial.net.", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, b.blocks(tc.name))
+		})
+	}
+
+	_, err = loadBlockLists(dir, []string{"missing.txt"})
+	require.Error(t, err)
+}
@@ -0,0 +1,352 @@
+// Package resolver implements the DNS forwarder of a profile. Queries are
+// checked against the profile's block lists and forwarded to its
+// upstreams, which can be plain DNS or DNS-over-TLS servers.
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/freebind"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	upstreamTimeout = 5 * time.Second
+	tcpIdleTimeout  = 10 * time.Second
+
+	// minUDPSize is the largest response sent over UDP to clients that
+	// do not advertise a size via EDNS.
+	minUDPSize = 512
+	maxMsgSize = 65535
+)
+
+var (
+	// maxQueries is the number of queries handled concurrently. Further
+	// queries wait for one to finish, or are dropped by the kernel once
+	// its socket buffers fill up.
+	maxQueries = 256
+	// maxConns is the number of TCP connections served concurrently.
+	maxConns = 64
+)
+
+// exchangeFunc sends msg to u and returns its response. tcp is set when
+// the client used TCP, so that truncated UDP responses are passed on and
+// retried by the client.
+type exchangeFunc func(u types.Upstream, msg []byte, tcp bool) ([]byte, error)
+
+// Resolver is the DNS forwarder of a profile.
+type Resolver struct {
+	profile   string
+	upstreams []types.Upstream
+	blocked   blocklist
+	// queryLog and logFile are nil when the query log is disabled.
+	queryLog *slog.Logger
+	logFile  io.Closer
+	exchange exchangeFunc
+
+	// queries and conns limit the goroutines spawned by the listeners.
+	queries chan struct{}
+	conns   chan struct{}
+}
+
+// New returns the Resolver for p, loading its block lists from the
+// profile dir.
+func New(p *types.Profile) (*Resolver, error) {
+	if p.Resolver == nil {
+		return nil, fmt.Errorf("profile %q has no resolver", p.Name)
+	}
+
+	r := &Resolver{
+		profile:  p.Name,
+		exchange: exchange,
+	}
+	for _, s := range p.Resolver.Upstreams {
+		u, err := types.ParseUpstream(s)
+		if err != nil {
+			return nil, err
+		}
+		r.upstreams = append(r.upstreams, u)
+	}
+
+	blocked, err := loadBlockLists(p.Path, p.Resolver.BlockLists)
+	if err != nil {
+		return nil, err
+	}
+	r.blocked = blocked
+
+	if p.Resolver.QueryLog {
+		fn, err := files.DNSQueryLogPath(p.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(fn), files.DirMode); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, files.FileMode)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open DNS query log: %w", err)
+		}
+		r.queryLog = slog.New(slog.NewJSONHandler(f, nil))
+		r.logFile = f
+	}
+
+	return r, nil
+}
+
+// ListenAndServe serves DNS over UDP and TCP on addr, until the returned
+// io.Closer is closed. Closing it also closes the query log.
+func (r *Resolver) ListenAndServe(addr string) (io.Closer, error) {
+	c := closers{}
+	if r.logFile != nil {
+		c = append(c, r.logFile)
+	}
+
+	pc, err := freebind.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, errors.Join(
+			fmt.Errorf("cannot listen for DNS on %q (port 53 requires CAP_NET_BIND_SERVICE or net.ipv4.ip_unprivileged_port_start=53): %w", addr, err),
+			c.Close())
+	}
+	// The listeners are closed before the query log.
+	c = append(closers{pc}, c...)
+
+	l, err := freebind.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("cannot listen for DNS on %q: %w", addr, err), c.Close())
+	}
+	c = append(closers{l}, c...)
+
+	r.queries = make(chan struct{}, maxQueries)
+	r.conns = make(chan struct{}, maxConns)
+
+	go r.serveUDP(pc)
+	go r.serveTCP(l)
+
+	return c, nil
+}
+
+type closers []io.Closer
+
+func (c closers) Close() error {
+	var err error
+	for _, cl := range c {
+		err = errors.Join(err, cl.Close())
+	}
+	return err
+}
+
+func (r *Resolver) serveUDP(pc net.PacketConn) {
+	buf := make([]byte, maxMsgSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("DNS resolver stopped", "error", err)
+			}
+			return
+		}
+
+		query := make([]byte, n)
+		copy(query, buf[:n])
+		r.queries <- struct{}{}
+		go func() {
+			defer func() { <-r.queries }()
+			resp := r.handle(query, addr.String(), false)
+			if resp == nil {
+				return
+			}
+			if _, err := pc.WriteTo(truncate(resp, udpSize(query)), addr); err != nil {
+				slog.Debug("cannot write DNS response", "client", addr, "error", err)
+			}
+		}()
+	}
+}
+
+func (r *Resolver) serveTCP(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("DNS resolver stopped", "error", err)
+			}
+			return
+		}
+		r.conns <- struct{}{}
+		go func() {
+			defer func() { <-r.conns }()
+			r.serveConn(conn)
+		}()
+	}
+}
+
+func (r *Resolver) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	var mu sync.Mutex
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(tcpIdleTimeout))
+		query, err := readMsg(conn)
+		if err != nil {
+			return
+		}
+
+		// Clients may pipeline queries, so they are handled concurrently.
+		r.queries <- struct{}{}
+		go func() {
+			defer func() { <-r.queries }()
+			resp := r.handle(query, conn.RemoteAddr().String(), true)
+			if resp == nil {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			_ = writeMsg(conn, resp)
+		}()
+	}
+}
+
+// handle returns the response to query, or nil if query is invalid.
+func (r *Resolver) handle(query []byte, client string, tcp bool) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return reply(h, nil, dnsmessage.RCodeFormatError)
+	}
+
+	name := q.Name.String()
+	if r.blocked.blocks(name) {
+		r.log(client, q, "blocked", "")
+		return reply(h, &q, dnsmessage.RCodeNameError)
+	}
+
+	for _, u := range r.upstreams {
+		resp, err := r.exchange(u, query, tcp)
+		if err != nil {
+			slog.Debug("DNS upstream failed", "upstream", u.Addr, "error", err)
+			continue
+		}
+		r.log(client, q, "forwarded", u.Addr)
+		return resp
+	}
+
+	r.log(client, q, "failed", "")
+	return reply(h, &q, dnsmessage.RCodeServerFailure)
+}
+
+func (r *Resolver) log(client string, q dnsmessage.Question, action, upstream string) {
+	if r.queryLog == nil {
+		return
+	}
+	r.queryLog.Info("query",
+		"profile", r.profile, "client", client,
+		"name", q.Name.String(), "type", q.Type.String(),
+		"action", action, "upstream", upstream)
+}
+
+// reply returns a response to the query with header h, without answers.
+func reply(h dnsmessage.Header, q *dnsmessage.Question, rcode dnsmessage.RCode) []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 h.ID,
+		Response:           true,
+		OpCode:             h.OpCode,
+		RecursionDesired:   h.RecursionDesired,
+		RecursionAvailable: true,
+		RCode:              rcode,
+	})
+	if q != nil {
+		if err := b.StartQuestions(); err != nil {
+			return nil
+		}
+		if err := b.Question(*q); err != nil {
+			return nil
+		}
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+// udpSize returns the largest response the client accepts over UDP.
+func udpSize(query []byte) int {
+	var p dnsmessage.Parser
+	if _, err := p.Start(query); err != nil {
+		return minUDPSize
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return minUDPSize
+	}
+	if err := p.SkipAllAnswers(); err != nil {
+		return minUDPSize
+	}
+	if err := p.SkipAllAuthorities(); err != nil {
+		return minUDPSize
+	}
+	for {
+		h, err := p.AdditionalHeader()
+		if err != nil {
+			return minUDPSize
+		}
+		if h.Type == dnsmessage.TypeOPT {
+			// The class of OPT records holds the UDP payload size.
+			return max(int(h.Class), minUDPSize)
+		}
+		if err := p.SkipAdditional(); err != nil {
+			return minUDPSize
+		}
+	}
+}
+
+// truncate returns resp if it fits in size, or otherwise only its header
+// and question with the TC bit set, so that the client retries over TCP.
+func truncate(resp []byte, size int) []byte {
+	if len(resp) <= size {
+		return resp
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(resp)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+		ID:                 h.ID,
+		Response:           true,
+		OpCode:             h.OpCode,
+		Truncated:          true,
+		RecursionDesired:   h.RecursionDesired,
+		RecursionAvailable: h.RecursionAvailable,
+		RCode:              h.RCode,
+	})
+	if err := b.StartQuestions(); err != nil {
+		return nil
+	}
+	if err := b.Question(q); err != nil {
+		return nil
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil
+	}
+	return msg
+}
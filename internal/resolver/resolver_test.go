@@ -0,0 +1,276 @@
+package resolver
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func query(t *testing.T, name string, udpSize uint16) []byte {
+	t.Helper()
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 42, RecursionDesired: true})
+	require.NoError(t, b.StartQuestions())
+	require.NoError(t, b.Question(dnsmessage.Question{
+		Name:  dnsmessage.MustNewName(name),
+		Type:  dnsmessage.TypeA,
+		Class: dnsmessage.ClassINET,
+	}))
+	if udpSize > 0 {
+		require.NoError(t, b.StartAdditionals())
+		var opt dnsmessage.ResourceHeader
+		require.NoError(t, opt.SetEDNS0(int(udpSize), dnsmessage.RCodeSuccess, false))
+		require.NoError(t, b.OPTResource(opt, dnsmessage.OPTResource{}))
+	}
+	msg, err := b.Finish()
+	require.NoError(t, err)
+	return msg
+}
+
+func answer(t *testing.T, q []byte, ips ...[4]byte) []byte {
+	t.Helper()
+
+	var p dnsmessage.Parser
+	h, err := p.Start(q)
+	require.NoError(t, err)
+	question, err := p.Question()
+	require.NoError(t, err)
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true})
+	require.NoError(t, b.StartQuestions())
+	require.NoError(t, b.Question(question))
+	require.NoError(t, b.StartAnswers())
+	for _, ip := range ips {
+		require.NoError(t, b.AResource(dnsmessage.ResourceHeader{
+			Name:  question.Name,
+			Class: dnsmessage.ClassINET,
+			TTL:   60,
+		}, dnsmessage.AResource{A: ip}))
+	}
+	msg, err := b.Finish()
+	require.NoError(t, err)
+	return msg
+}
+
+func rcode(t *testing.T, msg []byte) dnsmessage.RCode {
+	t.Helper()
+
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	require.NoError(t, err)
+	assert.True(t, h.Response)
+	assert.Equal(t, uint16(42), h.ID)
+	return h.RCode
+}
+
+func TestHandle(t *testing.T) {
+	first := types.Upstream{Addr: "192.0.2.1:53"}
+	second := types.Upstream{Addr: "dns.example:853", TLS: true, ServerName: "dns.example"}
+
+	tests := []struct {
+		name      string
+		query     string
+		failing   map[types.Upstream]bool
+		want      dnsmessage.RCode
+		wantTried []types.Upstream
+	}{
+		{
+			name:      "forwarded",
+			query:     "github.com.",
+			want:      dnsmessage.RCodeSuccess,
+			wantTried: []types.Upstream{first},
+		},
+		{
+			name:      "fallback upstream",
+			query:     "github.com.",
+			failing:   map[types.Upstream]bool{first: true},
+			want:      dnsmessage.RCodeSuccess,
+			wantTried: []types.Upstream{first, second},
+		},
+		{
+			name:      "all upstreams failing",
+			query:     "github.com.",
+			failing:   map[types.Upstream]bool{first: true, second: true},
+			want:      dnsmessage.RCodeServerFailure,
+			wantTried: []types.Upstream{first, second},
+		},
+		{
+			name:  "blocked",
+			query: "ads.example.com.",
+			want:  dnsmessage.RCodeNameError,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var tried []types.Upstream
+			r := &Resolver{
+				upstreams: []types.Upstream{first, second},
+				blocked:   blocklist{"example.com": {}},
+				exchange: func(u types.Upstream, msg []byte, _ bool) ([]byte, error) {
+					tried = append(tried, u)
+					if tc.failing[u] {
+						return nil, errors.New("timeout")
+					}
+					return answer(t, msg, [4]byte{192, 0, 2, 10}), nil
+				},
+			}
+
+			resp := r.handle(query(t, tc.query, 0), "172.30.0.3:1234", false)
+			assert.Equal(t, tc.want, rcode(t, resp))
+			assert.Equal(t, tc.wantTried, tried)
+		})
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer func(n int) { maxQueries = n }(maxQueries)
+	maxQueries = 2
+
+	r, err := New(&types.Profile{Name: "i3", Resolver: &types.Resolver{
+		Upstreams: []string{"192.0.2.1"},
+		QueryLog:  true,
+	}})
+	require.NoError(t, err)
+
+	var inflight, peak atomic.Int32
+	release := make(chan struct{})
+	r.exchange = func(_ types.Upstream, msg []byte, _ bool) ([]byte, error) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		<-release
+		return answer(t, msg), nil
+	}
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := pc.LocalAddr().String()
+	require.NoError(t, pc.Close())
+
+	c, err := r.ListenAndServe(addr)
+	require.NoError(t, err)
+
+	conn, err := net.Dial("udp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	const queries = 5
+	for range queries {
+		_, err := conn.Write(query(t, "github.com.", 0))
+		require.NoError(t, err)
+	}
+
+	// Queries beyond maxQueries wait for others to finish.
+	require.Eventually(t, func() bool { return inflight.Load() == 2 }, time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), peak.Load())
+	close(release)
+
+	buf := make([]byte, maxMsgSize)
+	for range queries {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := conn.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, dnsmessage.RCodeSuccess, rcode(t, buf[:n]))
+	}
+
+	// Closing the listeners also closes the query log.
+	require.NoError(t, c.Close())
+	_, err = r.logFile.(*os.File).WriteString("query")
+	require.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestHandleInvalid(t *testing.T) {
+	r := &Resolver{}
+	assert.Nil(t, r.handle([]byte{0, 1}, "172.30.0.3:1234", false))
+}
+
+func TestTruncate(t *testing.T) {
+	q := query(t, "many.example.com.", 0)
+	ips := make([][4]byte, 50)
+	for i := range ips {
+		ips[i] = [4]byte{192, 0, 2, byte(i)}
+	}
+	resp := answer(t, q, ips...)
+	require.Greater(t, len(resp), minUDPSize)
+
+	assert.Equal(t, minUDPSize, udpSize(q))
+	assert.Equal(t, 4096, udpSize(query(t, "many.example.com.", 4096)))
+	assert.Equal(t, resp, truncate(resp, 4096))
+
+	got := truncate(resp, udpSize(q))
+	var p dnsmessage.Parser
+	h, err := p.Start(got)
+	require.NoError(t, err)
+	assert.True(t, h.Truncated)
+	assert.Less(t, len(got), minUDPSize)
+}
+
+func TestExchange(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udp.Close()
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+
+	go func() {
+		buf := make([]byte, maxMsgSize)
+		for {
+			n, addr, err := udp.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = udp.WriteTo(answer(t, buf[:n], [4]byte{192, 0, 2, 1}), addr)
+		}
+	}()
+	go func() {
+		for {
+			conn, err := tcp.Accept()
+			if err != nil {
+				return
+			}
+			msg, err := readMsg(conn)
+			if err == nil {
+				_ = writeMsg(conn, answer(t, msg, [4]byte{192, 0, 2, 2}))
+			}
+			conn.Close()
+		}
+	}()
+
+	q := query(t, "github.com.", 0)
+
+	resp, err := exchange(types.Upstream{Addr: udp.LocalAddr().String()}, q, false)
+	require.NoError(t, err)
+	assert.Equal(t, answer(t, q, [4]byte{192, 0, 2, 1}), resp)
+
+	resp, err = exchange(types.Upstream{Addr: tcp.Addr().String()}, q, true)
+	require.NoError(t, err)
+	assert.Equal(t, answer(t, q, [4]byte{192, 0, 2, 2}), resp)
+}
+
+func TestMsgFraming(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, writeMsg(&buf, []byte("query")))
+	assert.Equal(t, []byte{0, 5, 'q', 'u', 'e', 'r', 'y'}, buf.Bytes())
+
+	msg, err := readMsg(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("query"), msg)
+
+	_, err = readMsg(bytes.NewReader([]byte{0, 0}))
+	require.Error(t, err)
+}
@@ -0,0 +1,88 @@
+package resolver
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/qubesome/cli/internal/types"
+)
+
+// exchange sends msg to u over UDP, or over TCP when tcp is set. DNS-over-
+// TLS upstreams always use TCP.
+func exchange(u types.Upstream, msg []byte, tcp bool) ([]byte, error) {
+	d := &net.Dialer{Timeout: upstreamTimeout}
+
+	var conn net.Conn
+	var err error
+	switch {
+	case u.TLS:
+		conn, err = tls.DialWithDialer(d, "tcp", u.Addr, &tls.Config{
+			ServerName: u.ServerName,
+			MinVersion: tls.VersionTLS12,
+		})
+	case tcp:
+		conn, err = d.Dial("tcp", u.Addr)
+	default:
+		conn, err = d.Dial("udp", u.Addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(upstreamTimeout)); err != nil {
+		return nil, err
+	}
+
+	if u.TLS || tcp {
+		if err := writeMsg(conn, msg); err != nil {
+			return nil, err
+		}
+		return readMsg(conn)
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMsgSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// readMsg reads a DNS message with the two byte length prefix used over
+// TCP.
+func readMsg(r io.Reader) ([]byte, error) {
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, errors.New("empty DNS message")
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("cannot read DNS message: %w", err)
+	}
+	return msg, nil
+}
+
+func writeMsg(w io.Writer, msg []byte) error {
+	if len(msg) > maxMsgSize {
+		return fmt.Errorf("DNS message too large: %d bytes", len(msg))
+	}
+
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg))) //nolint:gosec // G115: checked above
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,280 @@
+// Package bwrap runs host binaries within bubblewrap sandboxes, mapping
+// the qubesome HostAccess model onto namespaces and bind mounts.
+package bwrap
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/util/usb"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/env"
+	"golang.org/x/sys/execabs"
+)
+
+var (
+	ErrPrivilegedNotSupported     = errors.New("bwrap runner does not support privileged workloads")
+	ErrSingleInstanceNotSupported = errors.New("bwrap runner does not support single instance")
+	ErrCapsNotSupported           = errors.New("bwrap runner only supports capsAdd when bwrap is setuid or run as root")
+)
+
+// host holds the host specific settings used to build the bwrap args.
+type host struct {
+	display        uint8
+	wayland        bool
+	waylandDisplay string
+	runtimeDir     string
+	cookiePath     string
+	home           string
+	devices        []string
+	cameras        []string
+	// privileged is set when bwrap can grant capabilities, which
+	// requires it to be setuid or to run as root.
+	privileged bool
+}
+
+func Run(ew types.EffectiveWorkload) error {
+	if err := ew.Validate(); err != nil {
+		return err
+	}
+
+	if ew.Workload.SingleInstance {
+		return ErrSingleInstanceNotSupported
+	}
+
+	h, err := hostSettings(ew)
+	if err != nil {
+		return err
+	}
+
+	args, err := buildArgs(ew, h)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("exec", "binary", files.BwrapBinary, "args", args)
+	cmd := execabs.Command(files.BwrapBinary, args...)
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+
+	return cmd.Run()
+}
+
+func hostSettings(ew types.EffectiveWorkload) (*host, error) {
+	h := &host{
+		display:        ew.Profile.Display,
+		wayland:        strings.EqualFold(os.Getenv("XDG_SESSION_TYPE"), "wayland"),
+		waylandDisplay: os.Getenv("WAYLAND_DISPLAY"),
+		runtimeDir:     files.HostRuntimeDir(),
+		home:           os.ExpandEnv("${HOME}"),
+		privileged:     os.Geteuid() == 0,
+	}
+
+	if fi, err := os.Stat(files.BwrapBinary); err == nil && fi.Mode()&os.ModeSetuid != 0 {
+		h.privileged = true
+	}
+
+	if h.wayland {
+		h.display = 0
+	}
+
+	pp, err := files.ClientCookiePath(ew.Profile.Name)
+	if err != nil {
+		return nil, err
+	}
+	h.cookiePath = pp
+
+	ndevs, err := usb.NamedDevices(ew.Workload.HostAccess.USBDevices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get named devices: %w", err)
+	}
+	h.devices = append(h.devices, ndevs...)
+
+	if ew.Workload.HostAccess.Camera {
+		h.cameras, _ = filepath.Glob("/dev/video*")
+	}
+
+	return h, nil
+}
+
+// buildArgs returns the bwrap args for the effective workload. Everything
+// is unshared by default, and access is added based on HostAccess.
+func buildArgs(ew types.EffectiveWorkload, h *host) ([]string, error) {
+	wl := ew.Workload
+	ha := wl.HostAccess
+
+	if wl.Command == "" {
+		return nil, fmt.Errorf("workload %q must set a command to run with bwrap", wl.Name)
+	}
+	if ha.Privileged {
+		return nil, ErrPrivilegedNotSupported
+	}
+	if wl.Runtime != "" {
+		return nil, fmt.Errorf("bwrap runner does not support runtime %q", wl.Runtime)
+	}
+	if wl.Egress != nil && ha.Network != "none" {
+		return nil, fmt.Errorf("bwrap runner does not support egress filtering")
+	}
+	if len(ha.CapsAdd) > 0 && !h.privileged {
+		return nil, ErrCapsNotSupported
+	}
+
+	args := []string{
+		"--die-with-parent",
+		"--new-session",
+		"--unshare-all",
+		"--hostname", ew.Name,
+		"--clearenv",
+	}
+
+	// bwrap can only share the host network or have none. As with the
+	// container runners, an unset network means the workload is online.
+	switch ha.Network {
+	case "none":
+	case "", "host":
+		args = append(args, "--share-net")
+	default:
+		return nil, fmt.Errorf("bwrap runner does not support network %q", ha.Network)
+	}
+
+	// Read-only view of the host system, so that host binaries and
+	// their libraries can be executed.
+	args = append(args,
+		"--ro-bind", "/usr", "/usr",
+		"--ro-bind-try", "/bin", "/bin",
+		"--ro-bind-try", "/sbin", "/sbin",
+		"--ro-bind-try", "/lib", "/lib",
+		"--ro-bind-try", "/lib64", "/lib64",
+		"--ro-bind-try", "/etc", "/etc",
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--dev-bind-try", "/dev/dri", "/dev/dri",
+		"--tmpfs", "/tmp",
+		"--tmpfs", "/run",
+		"--tmpfs", h.home,
+		"--dir", h.runtimeDir,
+		"--setenv", "HOME", h.home,
+		"--setenv", "PATH", "/usr/local/bin:/usr/bin:/bin",
+		"--setenv", "XDG_RUNTIME_DIR", h.runtimeDir,
+		"--setenv", "QUBESOME_PROFILE", ew.Profile.Name,
+	)
+
+	if h.wayland {
+		sock := filepath.Join(h.runtimeDir, h.waylandDisplay)
+		args = append(args,
+			"--ro-bind", sock, sock,
+			"--setenv", "WAYLAND_DISPLAY", h.waylandDisplay,
+			"--setenv", "XDG_SESSION_TYPE", "wayland",
+		)
+	}
+
+	x11 := fmt.Sprintf("/tmp/.X11-unix/X%d", h.display)
+	args = append(args,
+		"--ro-bind", x11, x11,
+		"--ro-bind", h.cookiePath, "/tmp/.Xauthority",
+		"--setenv", "DISPLAY", fmt.Sprintf(":%d", h.display),
+		"--setenv", "XAUTHORITY", "/tmp/.Xauthority",
+	)
+
+	if ew.Profile.Timezone != "" {
+		args = append(args, "--setenv", "TZ", ew.Profile.Timezone)
+	}
+
+	if ha.VarRunUser {
+		args = append(args, "--bind", h.runtimeDir, h.runtimeDir)
+	} else {
+		if ha.Dbus || ha.Bluetooth {
+			bus := filepath.Join(h.runtimeDir, "bus")
+			args = append(args, "--bind-try", bus, bus)
+		}
+		if ha.Microphone || ha.Speakers {
+			pw := filepath.Join(h.runtimeDir, "pipewire-0")
+			args = append(args, "--bind-try", pw, pw)
+		}
+	}
+
+	if ha.Dbus || ha.Bluetooth {
+		args = append(args,
+			"--ro-bind-try", "/run/dbus/system_bus_socket", "/run/dbus/system_bus_socket",
+			"--setenv", "DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(h.runtimeDir, "bus"),
+		)
+	}
+	if ha.Microphone || ha.Speakers {
+		args = append(args, "--dev-bind-try", "/dev/snd", "/dev/snd")
+	}
+
+	for _, dev := range h.cameras {
+		args = append(args, "--dev-bind", dev, dev)
+	}
+	for _, dev := range ha.Devices {
+		args = append(args, "--dev-bind", dev, dev)
+	}
+	for _, dev := range h.devices {
+		args = append(args, "--dev-bind", dev, dev)
+	}
+
+	for _, c := range ha.CapsAdd {
+		if !strings.HasPrefix(c, "CAP_") {
+			c = "CAP_" + c
+		}
+		args = append(args, "--cap-add", c)
+	}
+
+	for _, p := range ha.Paths {
+		ps := strings.Split(p, ":")
+		if len(ps) < 2 {
+			slog.Warn("failed to mount path", "path", p)
+			continue
+		}
+
+		src := env.Expand(ps[0])
+		if _, err := os.Stat(src); err != nil {
+			slog.Warn("failed to mount path", "path", src, "error", err)
+			continue
+		}
+
+		bind := "--bind"
+		if len(ps) > 2 && ps[2] == "ro" {
+			bind = "--ro-bind"
+		}
+		args = append(args, bind, src, ps[1])
+	}
+
+	// The host system is always mounted read-only, so ReadOnlyRootfs is
+	// implied and only the tmpfs mounts are added.
+	for _, t := range wl.Tmpfs {
+		if t.Mode != "" {
+			args = append(args, "--perms", t.Mode)
+		}
+		if t.Size != "" {
+			size, err := types.ParseSize(t.Size)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, "--size", strconv.FormatInt(size, 10))
+		}
+		args = append(args, "--tmpfs", t.Path)
+	}
+
+	if ha.Mime {
+		slog.Debug("mime handling is not supported by the bwrap runner")
+	}
+	if wl.Resources != (types.Resources{}) {
+		slog.Warn("resource limits are not enforced by the bwrap runner", "workload", wl.Name)
+	}
+	if wl.Security != (types.Security{}) {
+		slog.Warn("security settings are not supported by the bwrap runner", "workload", wl.Name)
+	}
+
+	args = append(args, "--", wl.Command)
+	args = append(args, wl.Args...)
+
+	return args, nil
+}
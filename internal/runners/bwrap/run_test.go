@@ -0,0 +1,136 @@
+package bwrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildArgs(t *testing.T) {
+	dir := t.TempDir()
+	h := &host{
+		display:    3,
+		runtimeDir: "/run/user/1000",
+		cookiePath: "/home/user/.qubesome/run/i3/.Xclient-cookie",
+		home:       "/home/user",
+		privileged: true,
+	}
+	profile := &types.Profile{Name: "i3", Timezone: "Europe/London"}
+
+	tests := []struct {
+		name         string
+		workload     types.Workload
+		unprivileged bool
+		want         []string
+		notWant      []string
+		wantErr      string
+	}{
+		{
+			name:     "defaults",
+			workload: types.Workload{Name: "xterm", Command: "/usr/bin/xterm", Args: []string{"-e", "top"}},
+			want: []string{
+				"--unshare-all",
+				"--share-net",
+				"--hostname xterm-i3",
+				"--ro-bind /tmp/.X11-unix/X3 /tmp/.X11-unix/X3",
+				"--ro-bind /home/user/.qubesome/run/i3/.Xclient-cookie /tmp/.Xauthority",
+				"--setenv DISPLAY :3",
+				"--setenv TZ Europe/London",
+				"-- /usr/bin/xterm -e top",
+			},
+			notWant: []string{"/dev/snd", "/run/user/1000/bus", "--cap-add"},
+		},
+		{
+			name: "no network",
+			workload: types.Workload{Name: "xterm", Command: "/usr/bin/xterm",
+				HostAccess: types.HostAccess{Network: "none"}},
+			notWant: []string{"--share-net"},
+		},
+		{
+			name: "host access",
+			workload: types.Workload{Name: "xterm", Command: "/usr/bin/xterm",
+				HostAccess: types.HostAccess{
+					Dbus:     true,
+					Speakers: true,
+					CapsAdd:  []string{"NET_ADMIN"},
+					Devices:  []string{"/dev/ttyUSB0"},
+					Paths:    []string{dir + ":/data:ro", dir + ":/rw", "/does/not/exist:/missing"},
+				}},
+			want: []string{
+				"--bind-try /run/user/1000/bus /run/user/1000/bus",
+				"--setenv DBUS_SESSION_BUS_ADDRESS unix:path=/run/user/1000/bus",
+				"--bind-try /run/user/1000/pipewire-0 /run/user/1000/pipewire-0",
+				"--dev-bind-try /dev/snd /dev/snd",
+				"--dev-bind /dev/ttyUSB0 /dev/ttyUSB0",
+				"--cap-add CAP_NET_ADMIN",
+				"--ro-bind " + dir + " /data",
+				"--bind " + dir + " /rw",
+			},
+			notWant: []string{"/missing"},
+		},
+		{
+			name: "tmpfs",
+			workload: types.Workload{Name: "xterm", Command: "/usr/bin/xterm",
+				Tmpfs: []types.Tmpfs{{Path: "/cache"}, {Path: "/data", Size: "1m", Mode: "0700"}}},
+			want: []string{
+				"--tmpfs /cache",
+				"--perms 0700 --size 1048576 --tmpfs /data",
+			},
+		},
+		{
+			name:     "command required",
+			workload: types.Workload{Name: "xterm"},
+			wantErr:  "must set a command",
+		},
+		{
+			name: "privileged",
+			workload: types.Workload{Name: "xterm", Command: "/usr/bin/xterm",
+				HostAccess: types.HostAccess{Privileged: true}},
+			wantErr: "does not support privileged",
+		},
+		{
+			name: "caps with unprivileged bwrap",
+			workload: types.Workload{Name: "xterm", Command: "/usr/bin/xterm",
+				HostAccess: types.HostAccess{CapsAdd: []string{"NET_ADMIN"}}},
+			unprivileged: true,
+			wantErr:      "only supports capsAdd",
+		},
+		{
+			name: "container network",
+			workload: types.Workload{Name: "xterm", Command: "/usr/bin/xterm",
+				HostAccess: types.HostAccess{Network: "qubesome"}},
+			wantErr: "does not support network",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ew := types.EffectiveWorkload{
+				Name:     tc.workload.Name + "-" + profile.Name,
+				Profile:  profile,
+				Workload: tc.workload,
+			}
+
+			hh := *h
+			hh.privileged = !tc.unprivileged
+
+			args, err := buildArgs(ew, &hh)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			got := strings.Join(args, " ")
+			for _, w := range tc.want {
+				assert.Contains(t, got, w)
+			}
+			for _, w := range tc.notWant {
+				assert.NotContains(t, got, w)
+			}
+		})
+	}
+}
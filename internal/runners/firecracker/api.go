@@ -0,0 +1,191 @@
+package firecracker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	apiTimeout = 30 * time.Second
+
+	StateRunning = "Running"
+	StatePaused  = "Paused"
+)
+
+// apiClient talks to the Firecracker API, which is served over HTTP on
+// the unix socket passed via --api-sock.
+type apiClient struct {
+	c *http.Client
+}
+
+// instanceInfo is the response of GET /.
+type instanceInfo struct {
+	ID         string `json:"id"`
+	State      string `json:"state"`
+	VMMVersion string `json:"vmm_version"`
+}
+
+type apiError struct {
+	FaultMessage string `json:"fault_message"`
+}
+
+func newAPIClient(socket string) *apiClient {
+	return &apiClient{
+		c: &http.Client{
+			Timeout: apiTimeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// waitForSocket waits until the API socket accepts connections.
+func waitForSocket(socket string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.Dial("unix", socket)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("firecracker API socket %q not available: %w", socket, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// configure applies cfg to a VM that has not yet been started.
+func (a *apiClient) configure(cfg vmConfig) error {
+	if err := a.put("/boot-source", cfg.BootSource); err != nil {
+		return err
+	}
+	if err := a.put("/machine-config", cfg.MachineConfig); err != nil {
+		return err
+	}
+	for _, d := range cfg.Drives {
+		if err := a.put("/drives/"+d.DriveID, d); err != nil {
+			return err
+		}
+	}
+	for _, n := range cfg.NetworkInterfaces {
+		if err := a.put("/network-interfaces/"+n.IfaceID, n); err != nil {
+			return err
+		}
+	}
+	if cfg.Vsock != nil {
+		if err := a.put("/vsock", cfg.Vsock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *apiClient) start() error {
+	return a.action("InstanceStart")
+}
+
+// shutdown asks the guest to shut down. The guest init disables
+// Ctrl+Alt+Del reboots, so it receives SIGINT instead and stops the
+// workload before rebooting. With reboot=k set in the boot args, the
+// Firecracker process exits once the guest has rebooted.
+func (a *apiClient) shutdown() error {
+	return a.action("SendCtrlAltDel")
+}
+
+func (a *apiClient) info() (instanceInfo, error) {
+	var i instanceInfo
+	err := a.do(http.MethodGet, "/", nil, &i)
+	return i, err
+}
+
+func (a *apiClient) pause() error {
+	return a.do(http.MethodPatch, "/vm", map[string]string{"state": StatePaused}, nil)
+}
+
+func (a *apiClient) resume() error {
+	return a.do(http.MethodPatch, "/vm", map[string]string{"state": "Resumed"}, nil)
+}
+
+// createSnapshot writes a full snapshot of a paused VM.
+func (a *apiClient) createSnapshot(statePath, memPath string) error {
+	return a.put("/snapshot/create", map[string]string{
+		"snapshot_type": "Full",
+		"snapshot_path": statePath,
+		"mem_file_path": memPath,
+	})
+}
+
+// loadSnapshot restores a snapshot into a VM that has not yet been
+// configured, and resumes it.
+func (a *apiClient) loadSnapshot(statePath, memPath string) error {
+	return a.put("/snapshot/load", map[string]any{
+		"snapshot_path": statePath,
+		"mem_backend": map[string]string{
+			"backend_type": "File",
+			"backend_path": memPath,
+		},
+		"resume_vm": true,
+	})
+}
+
+func (a *apiClient) action(action string) error {
+	return a.put("/actions", map[string]string{"action_type": action})
+}
+
+func (a *apiClient) put(path string, body any) error {
+	return a.do(http.MethodPut, path, body, nil)
+}
+
+func (a *apiClient) do(method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	// The host is ignored, as requests are sent over the unix socket.
+	req, err := http.NewRequest(method, "http://localhost"+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.c.Do(req)
+	if err != nil {
+		return fmt.Errorf("firecracker API %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e apiError
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return fmt.Errorf("firecracker API %s %s: %s: %s", method, path, resp.Status, e.FaultMessage)
+	}
+
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// running returns whether a VM is serving its API on socket.
+func running(socket string) bool {
+	_, err := newAPIClient(socket).info()
+	return err == nil
+}
@@ -0,0 +1,173 @@
+package firecracker
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	method string
+	path   string
+	body   map[string]any
+}
+
+// fakeFirecracker serves a minimal Firecracker API on a unix socket.
+type fakeFirecracker struct {
+	srv      *httptest.Server
+	mu       sync.Mutex
+	requests []request
+	state    string
+}
+
+func newFakeFirecracker(t *testing.T, socket string) *fakeFirecracker {
+	t.Helper()
+
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	f := &fakeFirecracker{state: "Not started"}
+	f.srv = httptest.NewUnstartedServer(http.HandlerFunc(f.handle))
+	f.srv.Listener = l
+	f.srv.Start()
+	t.Cleanup(f.close)
+
+	return f
+}
+
+func (f *fakeFirecracker) close() {
+	f.srv.Close()
+}
+
+func (f *fakeFirecracker) handle(w http.ResponseWriter, r *http.Request) {
+	req := request{method: r.Method, path: r.URL.Path}
+	_ = json.NewDecoder(r.Body).Decode(&req.body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/":
+		_ = json.NewEncoder(w).Encode(instanceInfo{ID: "test", State: f.state, VMMVersion: "1.11.0"})
+		return
+	case r.URL.Path == "/invalid":
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(apiError{FaultMessage: "invalid request"})
+		return
+	case r.URL.Path == "/actions":
+		switch req.body["action_type"] {
+		case "InstanceStart":
+			f.state = StateRunning
+		case "SendCtrlAltDel":
+			// Mimic the process exiting once the guest shuts down.
+			go f.close()
+		}
+	case r.URL.Path == "/vm":
+		f.state = req.body["state"].(string)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeFirecracker) paths() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var paths []string
+	for _, r := range f.requests {
+		paths = append(paths, r.method+" "+r.path)
+	}
+	return paths
+}
+
+func TestAPIClientConfigure(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), apiSocket)
+	f := newFakeFirecracker(t, socket)
+
+	require.NoError(t, waitForSocket(socket, apiTimeout))
+
+	cfg, err := newConfig(vm{
+		kernel:    "/vmlinux",
+		rootfs:    "/rootfs.ext4",
+		drives:    []drive{{DriveID: "config", PathOnHost: "/config.ext4", IsReadOnly: true}},
+		tap:       &tap{index: 1},
+		vsockPath: "/vsock.sock",
+	}, types.Resources{})
+	require.NoError(t, err)
+
+	a := newAPIClient(socket)
+	require.NoError(t, a.configure(cfg))
+	require.NoError(t, a.start())
+
+	assert.Equal(t, []string{
+		"PUT /boot-source",
+		"PUT /machine-config",
+		"PUT /drives/rootfs",
+		"PUT /drives/config",
+		"PUT /network-interfaces/eth0",
+		"PUT /vsock",
+		"PUT /actions",
+	}, f.paths())
+
+	i, err := a.info()
+	require.NoError(t, err)
+	assert.Equal(t, StateRunning, i.State)
+
+	err = a.put("/invalid", nil)
+	require.ErrorContains(t, err, "invalid request")
+}
+
+func TestStopAndSnapshot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	name := "app-personal"
+	dir, err := files.FirecrackerRunDir(name)
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, files.DirMode))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, rootfsFile), []byte("rootfs"), files.FileMode))
+	require.NoError(t, writeState(dir, &vmState{Pid: 1, Digest: "sha256:abc"}))
+
+	err = Stop(name)
+	require.ErrorIs(t, err, ErrNotRunning)
+
+	f := newFakeFirecracker(t, filepath.Join(dir, apiSocket))
+	f.state = StateRunning
+
+	vms, err := VMs()
+	require.NoError(t, err)
+	assert.Equal(t, []VM{{Name: name, State: StateRunning, Version: "1.11.0"}}, vms)
+
+	require.NoError(t, Snapshot(name))
+	assert.Equal(t, []string{
+		"GET /",
+		"GET /",
+		"PATCH /vm",
+		"PUT /snapshot/create",
+		"PATCH /vm",
+	}, f.paths())
+
+	sd, s, ok := snapshot(name, "sha256:abc")
+	require.True(t, ok)
+	assert.Equal(t, "sha256:abc", s.Digest)
+	assert.FileExists(t, filepath.Join(sd, rootfsFile))
+
+	_, _, ok = snapshot(name, "sha256:other")
+	assert.False(t, ok)
+
+	require.NoError(t, Stop(name))
+	assert.False(t, running(filepath.Join(dir, apiSocket)))
+
+	require.NoError(t, DeleteSnapshot(name))
+	_, _, ok = snapshot(name, "sha256:abc")
+	assert.False(t, ok)
+}
@@ -0,0 +1,136 @@
+package firecracker
+
+import (
+	"math"
+	"strings"
+
+	"github.com/qubesome/cli/internal/types"
+)
+
+const (
+	defaultVcpus  = 2
+	defaultMemMiB = 256
+	maxVcpus      = 32
+
+	bootArgs = "keep_bootcon console=ttyS0 reboot=k panic=1 pci=off"
+
+	// guestCID is the vsock context ID of the guest. Each VM has its own
+	// vsock device backed by a unix socket, so the CID can be reused.
+	guestCID = 3
+)
+
+type vmConfig struct {
+	BootSource        bootSource         `json:"boot-source"`
+	Drives            []drive            `json:"drives"`
+	MachineConfig     machineConfig      `json:"machine-config"`
+	NetworkInterfaces []networkInterface `json:"network-interfaces"`
+	Vsock             *vsock             `json:"vsock,omitempty"`
+}
+
+type bootSource struct {
+	KernelImagePath string `json:"kernel_image_path"`
+	BootArgs        string `json:"boot_args"`
+}
+
+type drive struct {
+	DriveID      string `json:"drive_id"`
+	PathOnHost   string `json:"path_on_host"`
+	IsRootDevice bool   `json:"is_root_device"`
+	IsReadOnly   bool   `json:"is_read_only"`
+	CacheType    string `json:"cache_type,omitempty"`
+	IoEngine     string `json:"io_engine,omitempty"`
+}
+
+type machineConfig struct {
+	VcpuCount  int  `json:"vcpu_count"`
+	MemSizeMib int  `json:"mem_size_mib"`
+	Smt        bool `json:"smt"`
+}
+
+type networkInterface struct {
+	IfaceID     string `json:"iface_id"`
+	GuestMac    string `json:"guest_mac"`
+	HostDevName string `json:"host_dev_name"`
+}
+
+type vsock struct {
+	GuestCID int    `json:"guest_cid"`
+	UdsPath  string `json:"uds_path"`
+}
+
+// vm holds the host side resources allocated for a VM.
+type vm struct {
+	kernel    string
+	rootfs    string
+	drives    []drive
+	tap       *tap
+	vsockPath string
+	// args are extra kernel boot args, used to pass settings to the
+	// guest init.
+	args []string
+}
+
+// newConfig returns the Firecracker configuration for v, sized based
+// on the workload resources.
+func newConfig(v vm, r types.Resources) (vmConfig, error) {
+	mc, err := machine(r)
+	if err != nil {
+		return vmConfig{}, err
+	}
+
+	args := []string{bootArgs}
+	cfg := vmConfig{
+		Drives: []drive{{
+			DriveID:      "rootfs",
+			PathOnHost:   v.rootfs,
+			IsRootDevice: true,
+			CacheType:    "Unsafe",
+			IoEngine:     "Sync",
+		}},
+		MachineConfig:     mc,
+		NetworkInterfaces: []networkInterface{},
+	}
+	cfg.Drives = append(cfg.Drives, v.drives...)
+
+	if v.tap != nil {
+		cfg.NetworkInterfaces = append(cfg.NetworkInterfaces, networkInterface{
+			IfaceID:     "eth0",
+			GuestMac:    v.tap.mac(),
+			HostDevName: v.tap.name(),
+		})
+		args = append(args, v.tap.bootArg())
+	}
+
+	if v.vsockPath != "" {
+		cfg.Vsock = &vsock{GuestCID: guestCID, UdsPath: v.vsockPath}
+	}
+
+	cfg.BootSource = bootSource{
+		KernelImagePath: v.kernel,
+		BootArgs:        strings.Join(append(args, v.args...), " "),
+	}
+	return cfg, nil
+}
+
+// machine returns the machine config for r. CPUs are rounded up, as
+// Firecracker only supports whole vCPUs. Other limits, such as pids,
+// are not enforced for VMs.
+func machine(r types.Resources) (machineConfig, error) {
+	mc := machineConfig{
+		VcpuCount:  defaultVcpus,
+		MemSizeMib: defaultMemMiB,
+	}
+
+	if r.CPUs > 0 {
+		mc.VcpuCount = min(int(math.Ceil(r.CPUs)), maxVcpus)
+	}
+	if r.Memory != "" {
+		b, err := types.ParseSize(r.Memory)
+		if err != nil {
+			return mc, err
+		}
+		mc.MemSizeMib = max(int((b+(1<<20)-1)>>20), 1)
+	}
+
+	return mc, nil
+}
@@ -0,0 +1,178 @@
+package firecracker
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/firecracker/guest"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachine(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       types.Resources
+		want    machineConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: machineConfig{VcpuCount: defaultVcpus, MemSizeMib: defaultMemMiB},
+		},
+		{
+			name: "fractional cpus are rounded up",
+			r:    types.Resources{CPUs: 1.5, Memory: "1g"},
+			want: machineConfig{VcpuCount: 2, MemSizeMib: 1024},
+		},
+		{
+			name: "cpus are capped",
+			r:    types.Resources{CPUs: 64, Memory: "1536k"},
+			want: machineConfig{VcpuCount: maxVcpus, MemSizeMib: 2},
+		},
+		{
+			name:    "invalid memory",
+			r:       types.Resources{Memory: "lots"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := machine(tc.r)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNewConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		vm       vm
+		wantNics int
+		wantArgs string
+	}{
+		{
+			name:     "no network",
+			vm:       vm{kernel: "/k", rootfs: "/r"},
+			wantArgs: bootArgs,
+		},
+		{
+			name:     "tap",
+			vm:       vm{kernel: "/k", rootfs: "/r", tap: &tap{index: 7}, args: []string{"foo=bar"}},
+			wantNics: 1,
+			wantArgs: bootArgs + " ip=172.16.7.2::172.16.7.1:255.255.255.252::eth0:off foo=bar",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := newConfig(tc.vm, types.Resources{})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantArgs, got.BootSource.BootArgs)
+			assert.Len(t, got.NetworkInterfaces, tc.wantNics)
+			require.NotEmpty(t, got.Drives)
+			assert.True(t, got.Drives[0].IsRootDevice)
+			assert.Nil(t, got.Vsock)
+		})
+	}
+}
+
+func TestAllocateTap(t *testing.T) {
+	taken := map[string]bool{"qfc0": true, "qfc1": true}
+	got, err := allocateTap(func(name string) bool { return taken[name] })
+	require.NoError(t, err)
+	assert.Equal(t, "qfc2", got.name())
+	assert.Equal(t, "06:00:AC:10:02:02", got.mac())
+
+	_, err = allocateTap(func(string) bool { return true })
+	assert.ErrorIs(t, err, ErrNoFreeTap)
+}
+
+func TestTapIsolationRules(t *testing.T) {
+	tp := tap{index: 3}
+
+	assert.Equal(t, `iptables -I FORWARD -i qfc3 -o qfc+ -j DROP
+iptables -I INPUT -i qfc3 -m conntrack --ctstate NEW -j DROP`, tp.isolationRules("-I"))
+	assert.Contains(t, tp.isolationRules("-D"), "iptables -D FORWARD -i qfc3 -o qfc+ -j DROP")
+}
+
+func TestPathDrives(t *testing.T) {
+	if _, err := exec.LookPath(files.MkfsExt4Binary); err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(src, 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "foo"), []byte("bar"), 0o600))
+
+	drives, mounts, err := pathDrives(dir, []string{
+		src + ":/data:ro",
+		"/does/not/exist:/foo",
+		"invalid",
+		src + ":/data2",
+	}, 2)
+	require.NoError(t, err)
+
+	assert.Equal(t, []drive{
+		{DriveID: "path0", PathOnHost: filepath.Join(dir, "path0.ext4"), IsReadOnly: true},
+		{DriveID: "path1", PathOnHost: filepath.Join(dir, "path1.ext4"), IsReadOnly: true},
+	}, drives)
+	assert.Equal(t, []guest.Mount{
+		{Device: "/dev/vdc", Target: "/data", ReadOnly: true},
+		{Device: "/dev/vdd", Target: "/data2", ReadOnly: true},
+	}, mounts)
+
+	img := filepath.Join(dir, "disk.img")
+	require.NoError(t, os.WriteFile(img, nil, 0o600))
+
+	_, _, err = pathDrives(dir, []string{img + ":/data"}, 2)
+	assert.ErrorIs(t, err, ErrUnsupportedPath)
+}
+
+func TestConfigDrive(t *testing.T) {
+	if _, err := exec.LookPath(files.MkfsExt4Binary); err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+
+	dir := t.TempDir()
+	cookie := filepath.Join(dir, "cookie")
+	require.NoError(t, os.WriteFile(cookie, []byte("magic"), 0o600))
+
+	c := guestConfig{
+		profile:    "personal",
+		display:    3,
+		timezone:   "Europe/London",
+		cookiePath: cookie,
+		command:    []string{"firefox", "--new-window", "https://example.com"},
+		mounts:     []guest.Mount{{Device: "/dev/vdc", Target: "/data", ReadOnly: true}},
+		uid:        1000,
+	}
+	d, err := configDrive(dir, c)
+	require.NoError(t, err)
+	assert.Equal(t, drive{DriveID: "config", PathOnHost: filepath.Join(dir, "config.ext4"), IsReadOnly: true}, d)
+
+	got, err := guest.ReadConfig(filepath.Join(dir, "config"))
+	require.NoError(t, err)
+	assert.Equal(t, &guest.Config{
+		Env:     []string{"DISPLAY=:3", "XAUTHORITY=/tmp/.Xauthority", "QUBESOME_PROFILE=personal", "TZ=Europe/London"},
+		Command: c.command,
+		Mounts:  c.mounts,
+		UID:     1000,
+	}, got)
+
+	c.command = []string{"sh", "-c", "echo\nrm -rf /"}
+	_, err = configDrive(dir, c)
+	assert.Error(t, err)
+}
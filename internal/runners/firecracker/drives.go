@@ -0,0 +1,196 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/firecracker/guest"
+	"github.com/qubesome/cli/internal/util/env"
+	"golang.org/x/sys/execabs"
+)
+
+// imageOverhead is added to the size of ext4 images, to account for the
+// file system metadata.
+const imageOverhead = 16 * MB
+
+var ErrUnsupportedPath = errors.New("firecracker can only mount directories and block devices")
+
+// guestDevice returns the guest block device name of the nth drive.
+// Firecracker exposes drives in order, starting with the rootfs at vda.
+func guestDevice(n int) string {
+	return "/dev/vd" + string(rune('a'+n))
+}
+
+// pathDrives returns the drives and guest mounts for the workload paths,
+// in the src:dst[:ro] format. Block devices are attached as is.
+// Directories are copied into ext4 images within dir and are always
+// mounted read-only, as changes made by the guest could not be written
+// back to the host. Other files cannot be mounted by the guest.
+func pathDrives(dir string, paths []string, first int) ([]drive, []guest.Mount, error) {
+	var drives []drive
+	var mounts []guest.Mount
+
+	for _, p := range paths {
+		ps := strings.Split(p, ":")
+		if len(ps) < 2 {
+			slog.Warn("failed to mount path", "path", p)
+			continue
+		}
+
+		src := env.Expand(ps[0])
+		ro := len(ps) > 2 && ps[2] == "ro"
+
+		fi, err := os.Stat(src)
+		if err != nil {
+			slog.Warn("failed to mount path", "path", src, "error", err)
+			continue
+		}
+
+		id := fmt.Sprintf("path%d", len(drives))
+		switch {
+		case fi.IsDir():
+			if !ro {
+				slog.Warn("directories are mounted read-only within firecracker VMs", "path", src)
+				ro = true
+			}
+			img := filepath.Join(dir, id+".ext4")
+			if err := ext4Image(src, img); err != nil {
+				return nil, nil, fmt.Errorf("failed to create image for %q: %w", src, err)
+			}
+			src = img
+		case fi.Mode().Type() == fs.ModeDevice:
+			// Block devices are attached as is.
+		default:
+			return nil, nil, fmt.Errorf("%w: %q", ErrUnsupportedPath, src)
+		}
+
+		drives = append(drives, drive{
+			DriveID:    id,
+			PathOnHost: src,
+			IsReadOnly: ro,
+		})
+		mounts = append(mounts, guest.Mount{
+			Device:   guestDevice(first + len(mounts)),
+			Target:   ps[1],
+			ReadOnly: ro,
+		})
+	}
+
+	return drives, mounts, nil
+}
+
+// ext4Image creates an ext4 image at img with the contents of dir.
+func ext4Image(dir, img string) error {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(img, os.O_RDWR|os.O_CREATE|os.O_TRUNC, files.FileMode)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size + size/5 + imageOverhead); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	slog.Debug("creating ext4 image", "dir", dir, "image", img)
+	cmd := execabs.Command(files.MkfsExt4Binary, "-q", "-F", "-d", dir, img)
+	cmd.Stderr = os.Stderr
+
+	return cmd.Run()
+}
+
+// guestConfig holds the settings read by the guest init from the
+// config drive.
+type guestConfig struct {
+	profile    string
+	display    uint8
+	timezone   string
+	cookiePath string
+	command    []string
+	mounts     []guest.Mount
+	uid        int
+}
+
+// configDrive writes c into an ext4 image within dir, which is attached
+// read-only to the VM.
+func configDrive(dir string, c guestConfig) (drive, error) {
+	src := filepath.Join(dir, "config")
+	if err := os.MkdirAll(src, files.DirMode); err != nil {
+		return drive{}, err
+	}
+
+	for _, arg := range c.command {
+		if strings.Contains(arg, "\n") {
+			return drive{}, fmt.Errorf("command args cannot contain new lines: %q", arg)
+		}
+	}
+
+	cookie, err := os.ReadFile(c.cookiePath)
+	if err != nil {
+		return drive{}, fmt.Errorf("failed to read X11 cookie: %w", err)
+	}
+
+	envs := []string{
+		fmt.Sprintf("DISPLAY=:%d", c.display),
+		"XAUTHORITY=" + guest.Xauthority,
+		"QUBESOME_PROFILE=" + c.profile,
+	}
+	if c.timezone != "" {
+		envs = append(envs, "TZ="+c.timezone)
+	}
+
+	var mounts []string
+	for _, m := range c.mounts {
+		mounts = append(mounts, m.String())
+	}
+
+	for name, data := range map[string][]byte{
+		guest.XauthorityFile: cookie,
+		guest.EnvFile:        lines(envs),
+		guest.CommandFile:    lines(c.command),
+		guest.MountsFile:     lines(mounts),
+		guest.UIDFile:        lines([]string{strconv.Itoa(c.uid)}),
+	} {
+		if err := os.WriteFile(filepath.Join(src, name), data, files.FileMode); err != nil {
+			return drive{}, err
+		}
+	}
+
+	img := filepath.Join(dir, "config.ext4")
+	if err := ext4Image(src, img); err != nil {
+		return drive{}, fmt.Errorf("failed to create config drive: %w", err)
+	}
+
+	return drive{
+		DriveID:    "config",
+		PathOnHost: img,
+		IsReadOnly: true,
+	}, nil
+}
+
+func lines(s []string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(s, "\n") + "\n")
+}
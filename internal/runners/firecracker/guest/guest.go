@@ -0,0 +1,399 @@
+// Package guest is the init of firecracker VMs. The qubesome binary is
+// copied into the root file system of each VM and started by the kernel
+// as init, which mounts the config drive and workload paths, bridges
+// X11 to the host over vsock and then runs the workload command.
+package guest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// InitPath is where the init is copied to within the guest root fs.
+	InitPath = "/qubesome-init"
+
+	// ConfigArg is the kernel arg holding the config drive device.
+	ConfigArg = "qubesome.config"
+	// X11Arg is the kernel arg holding the vsock port of the X11 bridge,
+	// in the vsock:<port> format.
+	X11Arg = "qubesome.x11"
+
+	// Files within the config drive.
+	EnvFile        = "env"
+	CommandFile    = "command"
+	MountsFile     = "mounts"
+	UIDFile        = "uid"
+	XauthorityFile = "Xauthority"
+
+	// Xauthority is where the X11 cookie is copied to within the guest.
+	Xauthority = "/tmp/.Xauthority"
+
+	configDir   = "/run/qubesome"
+	defaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+	// stopGrace is how long the workload has to exit once the VM is
+	// asked to shut down, before it is killed.
+	stopGrace = 5 * time.Second
+)
+
+// Mount describes where the guest mounts a drive.
+type Mount struct {
+	Device   string
+	Target   string
+	ReadOnly bool
+}
+
+func (m Mount) String() string {
+	mode := "rw"
+	if m.ReadOnly {
+		mode = "ro"
+	}
+	return fmt.Sprintf("%s %s %s", m.Device, m.Target, mode)
+}
+
+// ParseMount parses a line of the mounts file.
+func ParseMount(s string) (Mount, error) {
+	f := strings.Fields(s)
+	if len(f) != 3 || (f[2] != "ro" && f[2] != "rw") {
+		return Mount{}, fmt.Errorf("invalid mount %q", s)
+	}
+	return Mount{Device: f[0], Target: f[1], ReadOnly: f[2] == "ro"}, nil
+}
+
+// Config is the guest configuration read from the config drive.
+type Config struct {
+	Env     []string
+	Command []string
+	Mounts  []Mount
+	UID     int
+}
+
+// ReadConfig reads the config drive mounted at dir.
+func ReadConfig(dir string) (*Config, error) {
+	c := &Config{}
+
+	var err error
+	if c.Env, err = readLines(filepath.Join(dir, EnvFile)); err != nil {
+		return nil, err
+	}
+	if c.Command, err = readLines(filepath.Join(dir, CommandFile)); err != nil {
+		return nil, err
+	}
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return nil, errors.New("no command to run")
+	}
+
+	mounts, err := readLines(filepath.Join(dir, MountsFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range mounts {
+		m, err := ParseMount(l)
+		if err != nil {
+			return nil, err
+		}
+		c.Mounts = append(c.Mounts, m)
+	}
+
+	uid, err := os.ReadFile(filepath.Join(dir, UIDFile))
+	if err != nil {
+		return nil, err
+	}
+	if c.UID, err = strconv.Atoi(strings.TrimSpace(string(uid))); err != nil {
+		return nil, fmt.Errorf("invalid uid: %w", err)
+	}
+	return c, nil
+}
+
+// IsInit returns whether qubesome is running as the init of a VM.
+func IsInit() bool {
+	return os.Getpid() == 1 && os.Args[0] == InitPath
+}
+
+// Main sets the guest up and runs the workload, shutting the VM down
+// once it exits. It never returns.
+func Main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "qubesome-init:", err)
+	}
+
+	unix.Sync()
+	// With reboot=k, a reboot makes firecracker exit.
+	_ = unix.Reboot(unix.LINUX_REBOOT_CMD_RESTART)
+	select {}
+}
+
+func run() error {
+	// The kernel starts init with an almost empty environment.
+	if err := os.Setenv("PATH", strings.TrimPrefix(defaultPath, "PATH=")); err != nil {
+		return err
+	}
+
+	// With Ctrl+Alt+Del reboots disabled, the kernel sends SIGINT to init
+	// instead, so that the workload can be stopped gracefully.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, unix.SIGINT, unix.SIGTERM)
+	if err := unix.Reboot(unix.LINUX_REBOOT_CMD_CAD_OFF); err != nil {
+		return fmt.Errorf("failed to disable ctrl-alt-del: %w", err)
+	}
+
+	for _, m := range []struct{ fstype, target, data string }{
+		{"proc", "/proc", ""},
+		{"sysfs", "/sys", ""},
+		{"devtmpfs", "/dev", ""},
+		{"tmpfs", "/tmp", "mode=1777"},
+		{"tmpfs", "/run", "mode=755"},
+	} {
+		if err := os.MkdirAll(m.target, 0o755); err != nil {
+			return err
+		}
+		if err := unix.Mount(m.fstype, m.target, m.fstype, 0, m.data); err != nil && !errors.Is(err, unix.EBUSY) {
+			return fmt.Errorf("failed to mount %s: %w", m.target, err)
+		}
+	}
+
+	cmdline, err := os.ReadFile("/proc/cmdline")
+	if err != nil {
+		return err
+	}
+	args := BootArgs(string(cmdline))
+
+	if err := mount(Mount{Device: args[ConfigArg], Target: configDir, ReadOnly: true}); err != nil {
+		return fmt.Errorf("failed to mount config drive: %w", err)
+	}
+	c, err := ReadConfig(configDir)
+	if err != nil {
+		return err
+	}
+	for _, m := range c.Mounts {
+		if err := mount(m); err != nil {
+			return fmt.Errorf("failed to mount %s: %w", m.Target, err)
+		}
+	}
+
+	u := lookupUser(c.UID)
+	if err := copyXauthority(u); err != nil {
+		return err
+	}
+
+	if port, ok := strings.CutPrefix(args[X11Arg], "vsock:"); ok {
+		p, err := strconv.ParseUint(port, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid x11 port %q: %w", port, err)
+		}
+		if err := bridgeX11(display(c.Env), uint32(p)); err != nil {
+			return err
+		}
+	}
+
+	cmd := exec.Command(c.Command[0], c.Command[1:]...)
+	cmd.Env = append([]string{defaultPath, "HOME=" + u.home}, c.Env...)
+	cmd.Dir = u.home
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{Uid: uint32(u.uid), Gid: uint32(u.gid)},
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %q: %w", c.Command[0], err)
+	}
+	return supervise(cmd.Process.Pid, sigs)
+}
+
+// supervise reaps processes until the workload with pid exits. Once a
+// signal is received on sigs, the workload is sent SIGTERM, and killed
+// if it did not exit within stopGrace. As init, orphaned processes are
+// reaped here as well.
+func supervise(pid int, sigs <-chan os.Signal) error {
+	exited := make(chan struct{})
+	defer close(exited)
+
+	go func() {
+		select {
+		case sig := <-sigs:
+			fmt.Fprintln(os.Stderr, "qubesome-init: received", sig, "stopping workload")
+			_ = unix.Kill(pid, unix.SIGTERM)
+		case <-exited:
+			return
+		}
+
+		select {
+		case <-time.After(stopGrace):
+			fmt.Fprintln(os.Stderr, "qubesome-init: workload did not stop, killing it")
+			_ = unix.Kill(pid, unix.SIGKILL)
+		case <-exited:
+		}
+	}()
+
+	for {
+		var ws unix.WaitStatus
+		p, err := unix.Wait4(-1, &ws, 0, nil)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil || p == pid {
+			return err
+		}
+	}
+}
+
+// BootArgs returns the key=value args within the kernel cmdline.
+func BootArgs(cmdline string) map[string]string {
+	args := map[string]string{}
+	for _, f := range strings.Fields(cmdline) {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			args[k] = v
+		}
+	}
+	return args
+}
+
+func mount(m Mount) error {
+	if m.Device == "" {
+		return errors.New("no device set")
+	}
+	if err := os.MkdirAll(m.Target, 0o755); err != nil {
+		return err
+	}
+
+	var flags uintptr
+	if m.ReadOnly {
+		flags |= unix.MS_RDONLY
+	}
+	return unix.Mount(m.Device, m.Target, "ext4", flags, "")
+}
+
+type guestUser struct {
+	uid, gid int
+	home     string
+}
+
+// lookupUser returns the user for uid within the guest root fs, which is
+// created with a user matching the host's.
+func lookupUser(uid int) guestUser {
+	u := guestUser{uid: uid, gid: uid, home: "/tmp"}
+	if pu, err := user.LookupId(strconv.Itoa(uid)); err == nil {
+		if gid, err := strconv.Atoi(pu.Gid); err == nil {
+			u.gid = gid
+		}
+		if pu.HomeDir != "" {
+			u.home = pu.HomeDir
+		}
+	}
+	return u
+}
+
+func copyXauthority(u guestUser) error {
+	data, err := os.ReadFile(filepath.Join(configDir, XauthorityFile))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(Xauthority, data, 0o600); err != nil {
+		return err
+	}
+	return os.Chown(Xauthority, u.uid, u.gid)
+}
+
+func display(env []string) string {
+	for _, e := range env {
+		if d, ok := strings.CutPrefix(e, "DISPLAY=:"); ok {
+			return d
+		}
+	}
+	return "0"
+}
+
+// bridgeX11 listens on the X11 socket of display, forwarding connections
+// to the host over vsock.
+func bridgeX11(display string, port uint32) error {
+	dir := "/tmp/.X11-unix"
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return err
+	}
+	if err := os.Chmod(dir, 0o777|os.ModeSticky); err != nil {
+		return err
+	}
+
+	l, err := net.Listen("unix", filepath.Join(dir, "X"+display))
+	if err != nil {
+		return fmt.Errorf("failed to listen for X11: %w", err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "X"+display), 0o777); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go proxyVsock(conn, port)
+		}
+	}()
+	return nil
+}
+
+func proxyVsock(conn net.Conn, port uint32) {
+	defer conn.Close()
+
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "qubesome-init: x11 bridge:", err)
+		return
+	}
+	if err := unix.Connect(fd, &unix.SockaddrVM{CID: unix.VMADDR_CID_HOST, Port: port}); err != nil {
+		unix.Close(fd)
+		fmt.Fprintln(os.Stderr, "qubesome-init: x11 bridge:", err)
+		return
+	}
+	host := os.NewFile(uintptr(fd), "vsock")
+	defer host.Close()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(host, conn)
+		_ = unix.Shutdown(fd, unix.SHUT_WR)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(conn, host)
+		if c, ok := conn.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if l := s.Text(); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines, s.Err()
+}
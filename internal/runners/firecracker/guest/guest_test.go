@@ -0,0 +1,94 @@
+package guest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBootArgs(t *testing.T) {
+	got := BootArgs("console=ttyS0 reboot=k pci=off root=/dev/vda rw init=/qubesome-init qubesome.config=/dev/vdb qubesome.x11=vsock:6000\n")
+
+	assert.Equal(t, "/dev/vdb", got[ConfigArg])
+	assert.Equal(t, "vsock:6000", got[X11Arg])
+	assert.Equal(t, InitPath, got["init"])
+	assert.NotContains(t, got, "rw")
+}
+
+func TestParseMount(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Mount
+		wantErr bool
+	}{
+		{in: "/dev/vdc /data ro", want: Mount{Device: "/dev/vdc", Target: "/data", ReadOnly: true}},
+		{in: "/dev/vdd /home/user/src rw", want: Mount{Device: "/dev/vdd", Target: "/home/user/src"}},
+		{in: "/dev/vdc /data", wantErr: true},
+		{in: "/dev/vdc /data rx", wantErr: true},
+		{in: "/dev/vdc /my data ro", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseMount(tc.in)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.in, got.String())
+		})
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600))
+	}
+
+	write(EnvFile, "DISPLAY=:2\nTZ=UTC\n")
+	write(MountsFile, "")
+	write(UIDFile, "1000\n")
+
+	_, err := ReadConfig(dir)
+	assert.Error(t, err, "command is required")
+
+	write(CommandFile, "code\n--wait\n")
+	got, err := ReadConfig(dir)
+	require.NoError(t, err)
+	assert.Equal(t, &Config{
+		Env:     []string{"DISPLAY=:2", "TZ=UTC"},
+		Command: []string{"code", "--wait"},
+		UID:     1000,
+	}, got)
+	assert.Equal(t, "2", display(got.Env))
+
+	write(MountsFile, "/dev/vdc /data\n")
+	_, err = ReadConfig(dir)
+	assert.Error(t, err)
+}
+
+func TestSuperviseStopsWorkload(t *testing.T) {
+	cmd := exec.Command("sleep", "60")
+	require.NoError(t, cmd.Start())
+
+	sigs := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- supervise(cmd.Process.Pid, sigs) }()
+
+	sigs <- os.Interrupt
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(stopGrace / 2):
+		_ = cmd.Process.Kill()
+		t.Fatal("workload was not stopped on SIGINT")
+	}
+}
@@ -0,0 +1,183 @@
+package firecracker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/dbus"
+)
+
+// defaultKernelURL from https://s3.amazonaws.com/spec.ccfc.min/
+const defaultKernelURL = "https://s3.amazonaws.com/spec.ccfc.min/firecracker-ci/v1.11/x86_64/vmlinux-6.1.102"
+
+var (
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+	ErrNoChecksum       = errors.New("kernelSHA256 must be set in the qubesome config to download the kernel image")
+	ErrDownloadTooLarge = errors.New("download exceeds the maximum size")
+)
+
+// kernel defines where the kernel image is sourced from, and its
+// expected SHA-256.
+type kernel struct {
+	url    string
+	sha256 string
+}
+
+// kernelSettings returns the kernel settings from the user-level config.
+func kernelSettings() kernel {
+	k := kernel{url: defaultKernelURL}
+
+	if _, err := os.Stat(files.QubesomeConfig()); err == nil {
+		cfg, err := types.LoadConfig(files.QubesomeConfig())
+		if err != nil {
+			slog.Warn("failed to load qubesome config", "error", err)
+			return k
+		}
+		if cfg.Firecracker.KernelURL != "" {
+			k.url = cfg.Firecracker.KernelURL
+		}
+		k.sha256 = strings.ToLower(cfg.Firecracker.KernelSHA256)
+	}
+
+	return k
+}
+
+// ensureKernel downloads the kernel image into target, unless it already
+// exists and matches the expected checksum.
+func ensureKernel(target string, k kernel) error {
+	f, err := os.Open(target)
+	if err == nil {
+		defer f.Close()
+		if k.sha256 == "" {
+			return nil
+		}
+
+		err = verify(f, k.sha256)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("kernel image does not match the expected checksum, downloading it again", "path", target, "error", err)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Downloads are never used unverified. Kernel images imported with
+	// import-kernel need no checksum.
+	if k.sha256 == "" {
+		return fmt.Errorf("%w: %s (or use qubesome firecracker import-kernel)", ErrNoChecksum, k.url)
+	}
+
+	dbus.NotifyOrLog("firecracker", "downloading fresh kernel image")
+	if err := download(k.url, target, k.sha256); err != nil {
+		return fmt.Errorf("failed to download kernel image: %w", err)
+	}
+	return nil
+}
+
+// ImportKernel installs file as the kernel image used to boot firecracker
+// VMs, for machines that cannot download it. The file is verified against
+// the kernelSHA256 from the user-level config, when set.
+func ImportKernel(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := os.MkdirAll(files.QubesomeDir(), files.DirMode); err != nil {
+		return err
+	}
+
+	target := files.FirecrackerKernelPath()
+	if err := install(f, target, kernelSettings().sha256); err != nil {
+		return fmt.Errorf("failed to import kernel image: %w", err)
+	}
+
+	slog.Info("kernel image imported", "path", target)
+	return nil
+}
+
+func download(url, target, sum string) error {
+	slog.Info("downloading file", "url", url, "target", target)
+
+	r, err := http.Get(url) //nolint
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status: %s", r.Status)
+	}
+	if r.ContentLength > maxDownloadSize {
+		return fmt.Errorf("%w: %d bytes", ErrDownloadTooLarge, r.ContentLength)
+	}
+
+	return install(&maxReader{r: r.Body, n: maxDownloadSize}, target, sum)
+}
+
+// maxReader reads from r, failing once more than n bytes are read.
+type maxReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	if m.n < 0 {
+		return n, ErrDownloadTooLarge
+	}
+	return n, err
+}
+
+// install writes the contents of r into a temporary file next to target,
+// which is only renamed to target once its checksum has been verified.
+// This ensures that partial or tampered files are never used.
+func install(r io.Reader, target, sum string) error {
+	f, err := os.CreateTemp(filepath.Dir(target), filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(h.Sum(nil)); sum != "" && got != sum {
+		return fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, got, sum)
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), target)
+}
+
+func verify(r io.Reader, sum string) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != sum {
+		return fmt.Errorf("%w: got %s, want %s", ErrChecksumMismatch, got, sum)
+	}
+	return nil
+}
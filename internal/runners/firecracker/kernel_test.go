@@ -0,0 +1,125 @@
+package firecracker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sum(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestInstall(t *testing.T) {
+	tests := []struct {
+		name    string
+		sum     string
+		wantErr error
+	}{
+		{
+			name: "no checksum",
+		},
+		{
+			name: "matching checksum",
+			sum:  sum("kernel"),
+		},
+		{
+			name:    "checksum mismatch",
+			sum:     sum("other"),
+			wantErr: ErrChecksumMismatch,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			target := filepath.Join(dir, "vmlinux")
+
+			err := install(strings.NewReader("kernel"), target, tc.sum)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				assert.NoFileExists(t, target)
+			} else {
+				require.NoError(t, err)
+				got, err := os.ReadFile(target)
+				require.NoError(t, err)
+				assert.Equal(t, "kernel", string(got))
+			}
+
+			// Temporary files must never be left behind.
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(entries), 1)
+		})
+	}
+}
+
+func TestEnsureKernel(t *testing.T) {
+	var downloads int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		downloads++
+		_, _ = w.Write([]byte("kernel"))
+	}))
+	defer srv.Close()
+
+	target := filepath.Join(t.TempDir(), "vmlinux")
+	k := kernel{url: srv.URL, sha256: sum("kernel")}
+
+	require.NoError(t, ensureKernel(target, k))
+	assert.Equal(t, 1, downloads)
+
+	// A valid kernel is not downloaded again.
+	require.NoError(t, ensureKernel(target, k))
+	assert.Equal(t, 1, downloads)
+
+	// A corrupted kernel is replaced.
+	require.NoError(t, os.WriteFile(target, []byte("corrupted"), 0o600))
+	require.NoError(t, ensureKernel(target, k))
+	assert.Equal(t, 2, downloads)
+
+	// A download that does not match the checksum is rejected.
+	require.NoError(t, os.Remove(target))
+	err := ensureKernel(target, kernel{url: srv.URL, sha256: sum("other")})
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.NoFileExists(t, target)
+}
+
+func TestEnsureKernelRequiresChecksum(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "vmlinux")
+
+	err := ensureKernel(target, kernel{url: defaultKernelURL})
+	require.ErrorIs(t, err, ErrNoChecksum)
+	assert.NoFileExists(t, target)
+
+	// Imported kernels are used as is.
+	require.NoError(t, os.WriteFile(target, []byte("kernel"), 0o600))
+	require.NoError(t, ensureKernel(target, kernel{url: defaultKernelURL}))
+
+	// Including when the kernel URL is overridden.
+	require.NoError(t, ensureKernel(target, kernel{url: "https://example.com/vmlinux"}))
+
+	require.NoError(t, os.Remove(target))
+	err = ensureKernel(target, kernel{url: "https://example.com/vmlinux"})
+	require.ErrorIs(t, err, ErrNoChecksum)
+}
+
+func TestMaxReader(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "vmlinux")
+
+	err := install(&maxReader{r: strings.NewReader("kernel"), n: 5}, target, "")
+	require.ErrorIs(t, err, ErrDownloadTooLarge)
+	assert.NoFileExists(t, target)
+
+	require.NoError(t, install(&maxReader{r: strings.NewReader("kernel"), n: 6}, target, sum("kernel")))
+	assert.FileExists(t, target)
+}
@@ -0,0 +1,119 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+
+	"github.com/qubesome/cli/internal/files"
+	"golang.org/x/sys/execabs"
+)
+
+const (
+	tapPrefix = "qfc"
+	maxTaps   = 256
+)
+
+var ErrNoFreeTap = errors.New("no free tap device available for firecracker")
+
+// tap is a host tap device dedicated to a single VM. Each tap has its
+// own /30 subnet within 172.16.0.0/16, and forwarding between taps and
+// new connections to the host are dropped, so that VMs can only reach
+// external networks.
+type tap struct {
+	index int
+}
+
+func (t tap) name() string {
+	return fmt.Sprintf("%s%d", tapPrefix, t.index)
+}
+
+func (t tap) hostIP() string {
+	return fmt.Sprintf("172.16.%d.1", t.index)
+}
+
+func (t tap) guestIP() string {
+	return fmt.Sprintf("172.16.%d.2", t.index)
+}
+
+// subnet returns the /30 subnet of the tap.
+func (t tap) subnet() string {
+	return fmt.Sprintf("172.16.%d.0/30", t.index)
+}
+
+func (t tap) mac() string {
+	return fmt.Sprintf("06:00:AC:10:%02X:02", t.index)
+}
+
+// bootArg returns the kernel ip= arg that configures the guest interface.
+func (t tap) bootArg() string {
+	return fmt.Sprintf("ip=%s::%s:255.255.255.252::eth0:off", t.guestIP(), t.hostIP())
+}
+
+// allocateTap returns the first tap whose device does not yet exist.
+func allocateTap(exists func(name string) bool) (*tap, error) {
+	for i := range maxTaps {
+		t := &tap{index: i}
+		if !exists(t.name()) {
+			return t, nil
+		}
+	}
+	return nil, ErrNoFreeTap
+}
+
+func tapExists(name string) bool {
+	_, err := net.InterfaceByName(name)
+	return err == nil
+}
+
+// setupTap creates the tap device and its subnet on the host, owned by
+// the current user so that firecracker can open it. Traffic from the
+// guest is masqueraded, and isolated from other VMs and the host.
+func setupTap(t *tap) error {
+	slog.Debug("setting up tap", "device name", t.name(), "ip", t.hostIP())
+	script := fmt.Sprintf(`set -e
+ip tuntap add dev %[1]s mode tap user %[2]d
+ip addr add %[3]s/30 dev %[1]s
+ip link set %[1]s up
+sysctl -qw net.ipv4.ip_forward=1
+%[5]s
+iptables -t nat -A POSTROUTING -s %[4]s -j MASQUERADE`,
+		t.name(), os.Getuid(), t.hostIP(), t.subnet(), t.isolationRules("-I"))
+
+	return helper(firecrackerImg, "sh", "-c", script)
+}
+
+// removeTap deletes the tap device and its rules from the host.
+func removeTap(t *tap) error {
+	slog.Debug("removing tap", "device name", t.name())
+	script := fmt.Sprintf(`ip link del %[1]s
+%[3]s
+iptables -t nat -D POSTROUTING -s %[2]s -j MASQUERADE`, t.name(), t.subnet(), t.isolationRules("-D"))
+
+	return helper(firecrackerImg, "sh", "-c", script)
+}
+
+// isolationRules returns the iptables commands that drop traffic from the
+// tap to other taps and new connections to the host, for the iptables
+// action op (-I or -D).
+func (t tap) isolationRules(op string) string {
+	return fmt.Sprintf(`iptables %[1]s FORWARD -i %[2]s -o %[3]s+ -j DROP
+iptables %[1]s INPUT -i %[2]s -m conntrack --ctstate NEW -j DROP`, op, t.name(), tapPrefix)
+}
+
+// helper runs args within a privileged container on the host network,
+// so that taps can be managed without qubesome requiring root.
+func helper(args ...string) error {
+	bin := files.ContainerRunnerBinary("docker")
+	cmd := execabs.Command(bin, append([]string{
+		"run", "--rm", "--privileged",
+		"--network", "host",
+	}, args...)...)
+
+	cmd.Stderr = os.Stderr
+	cmd.Stdout = os.Stdout
+
+	return cmd.Run()
+}
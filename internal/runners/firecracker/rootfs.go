@@ -0,0 +1,184 @@
+package firecracker
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/firecracker/guest"
+	"golang.org/x/sys/execabs"
+)
+
+// cachedRootFs returns a root file system for img within dir. Root file
+// systems are cached by image digest, so they are only built once per
+// image version. Each VM gets its own copy, as the guest writes to it.
+func cachedRootFs(dir, img, digest string) (string, error) {
+	cached, err := files.FirecrackerRootfsPath(digest)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(cached); errors.Is(err, fs.ErrNotExist) {
+		if err := buildRootFs(cached, img); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	} else {
+		slog.Debug("using cached root fs", "image", img, "path", cached)
+	}
+
+	target := filepath.Join(dir, rootfsFile)
+	if err := copyFile(cached, target); err != nil {
+		return "", fmt.Errorf("failed to copy root fs: %w", err)
+	}
+	return target, nil
+}
+
+// imageDigest returns the ID of img, pulling it when not found locally.
+func imageDigest(img string) (string, error) {
+	bin := files.ContainerRunnerBinary("docker")
+
+	inspect := func() (string, error) {
+		out, err := execabs.Command(bin, "image", "inspect", "--format={{.Id}}", img).Output()
+		return string(bytes.TrimSpace(out)), err
+	}
+
+	id, err := inspect()
+	if err != nil {
+		slog.Debug("image not found locally, pulling it", "image", img)
+		cmd := execabs.Command(bin, "pull", img)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("failed to pull image %q: %w", img, err)
+		}
+		if id, err = inspect(); err != nil {
+			return "", fmt.Errorf("failed to inspect image %q: %w", img, err)
+		}
+	}
+
+	if id == "" || strings.ContainsAny(id, "/\\") {
+		return "", fmt.Errorf("invalid digest %q for image %q", id, img)
+	}
+	return id, nil
+}
+
+// buildRootFs creates the root file system for img at target. It is built
+// within a temporary dir, and only moved to target once complete.
+func buildRootFs(target, img string) error {
+	slog.Info("creating root fs", "image", img)
+	if err := os.MkdirAll(filepath.Dir(target), files.DirMode); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(target), "build-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	rootfs := filepath.Join(dir, "rootfs.ext4")
+	bin := files.ContainerRunnerBinary("docker")
+	cmd := execabs.Command(bin,
+		"run", "--rm", "--privileged",
+		"-v", dir+":"+dir,
+		img,
+		"create_rootfs", rootfs, strconv.Itoa(os.Getuid()),
+	)
+
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	return os.Rename(rootfs, target)
+}
+
+// installInit copies the qubesome binary into rootfs as the guest init.
+// The binary must be statically linked, as the guest may not have a
+// compatible libc.
+func installInit(rootfs string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if err := checkStatic(exe); err != nil {
+		return err
+	}
+
+	slog.Debug("installing guest init", "rootfs", rootfs, "init", guest.InitPath)
+	return writeInit(rootfs, exe)
+}
+
+// writeInit writes the file at exe into rootfs as the guest init.
+func writeInit(rootfs, exe string) error {
+	if strings.Contains(exe, `"`) {
+		return fmt.Errorf("unsupported path for the qubesome binary: %q", exe)
+	}
+
+	name := strings.TrimPrefix(guest.InitPath, "/")
+	cmds := filepath.Join(filepath.Dir(rootfs), "init.debugfs")
+	script := fmt.Sprintf("rm %[1]s\nwrite \"%[2]s\" %[1]s\nsif %[1]s mode 0100755\nsif %[1]s uid 0\nsif %[1]s gid 0\n", name, exe)
+	if err := os.WriteFile(cmds, []byte(script), files.FileMode); err != nil {
+		return err
+	}
+	defer os.Remove(cmds)
+
+	if out, err := execabs.Command(files.DebugfsBinary, "-w", "-f", cmds, rootfs).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to install guest init: %w: %s", err, out)
+	}
+
+	// debugfs does not fail when its commands do, so check the result.
+	out, err := execabs.Command(files.DebugfsBinary, "-R", "stat "+name, rootfs).CombinedOutput()
+	if err != nil || !bytes.Contains(out, []byte("Type: regular")) {
+		return fmt.Errorf("failed to install guest init: %s", out)
+	}
+	return nil
+}
+
+// checkStatic returns an error if the binary at path is dynamically linked.
+func checkStatic(path string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_INTERP {
+			return fmt.Errorf("%s is dynamically linked: firecracker requires a statically linked qubesome binary", path)
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, files.FileMode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,41 @@
+package firecracker
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteInit(t *testing.T) {
+	for _, bin := range []string{files.MkfsExt4Binary, files.DebugfsBinary} {
+		if _, err := exec.LookPath(bin); err != nil {
+			t.Skipf("%s not found", bin)
+		}
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	require.NoError(t, os.MkdirAll(src, 0o700))
+	rootfs := filepath.Join(dir, rootfsFile)
+	require.NoError(t, ext4Image(src, rootfs))
+
+	exe := filepath.Join(dir, "qubesome bin")
+	require.NoError(t, os.WriteFile(exe, []byte("#!/bin/true\n"), 0o700))
+
+	// Writing twice replaces the existing init.
+	require.NoError(t, writeInit(rootfs, exe))
+	require.NoError(t, writeInit(rootfs, exe))
+
+	out, err := exec.Command(files.DebugfsBinary, "-R", "stat qubesome-init", rootfs).CombinedOutput()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Mode:  0755")
+	assert.Contains(t, string(out), "User:     0   Group:     0")
+
+	assert.Error(t, writeInit(rootfs, filepath.Join(dir, "missing")))
+	assert.Error(t, writeInit(rootfs, filepath.Join(dir, `a"b`)))
+}
@@ -1,24 +1,20 @@
 package firecracker
 
 import (
-	"context"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
-	"text/template"
+	"strings"
+	"time"
 
 	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/firecracker/guest"
 	"github.com/qubesome/cli/internal/types"
 	"golang.org/x/sys/execabs"
 )
 
-type configParams struct {
-	KernelImagePath string
-	RootFsPath      string
-	HostDeviceName  string
-}
-
 func Run(ew types.EffectiveWorkload) error {
 	slog.Warn("use of firecracker is experimental")
 
@@ -26,67 +22,194 @@ func Run(ew types.EffectiveWorkload) error {
 		return err
 	}
 
-	if ew.Workload.SingleInstance {
+	wl := ew.Workload
+	if wl.SingleInstance {
 		return fmt.Errorf("firecracker does not support single instance")
 	}
 
+	if wl.Runtime != "" {
+		return fmt.Errorf("firecracker does not support runtime %q", wl.Runtime)
+	}
+
+	if wl.Egress != nil && wl.HostAccess.Network != "none" {
+		return fmt.Errorf("firecracker does not support egress filtering")
+	}
+
+	if wl.Security != (types.Security{}) {
+		slog.Warn("security settings do not apply to firecracker VMs", "workload", wl.Name)
+	}
+	if wl.ReadOnlyRootfs || len(wl.Tmpfs) > 0 {
+		slog.Warn("readOnlyRootfs and tmpfs are not supported by the firecracker runner", "workload", wl.Name)
+	}
+
 	if err := ensureDependencies(); err != nil {
 		return err
 	}
 
-	d, err := os.MkdirTemp("", "qubesome-")
+	d, err := files.FirecrackerRunDir(ew.Name)
+	if err != nil {
+		return err
+	}
+
+	socket := filepath.Join(d, apiSocket)
+	if running(socket) {
+		return fmt.Errorf("%w: %s", ErrAlreadyRunning, ew.Name)
+	}
+
+	// The run dir is stable across runs, as snapshots reference the
+	// paths of the drives and sockets of the VM they were taken from.
+	if err := os.RemoveAll(d); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d, files.DirMode); err != nil {
+		return err
+	}
+	defer os.RemoveAll(d)
+
+	digest, err := imageDigest(wl.Image)
+	if err != nil {
+		return err
+	}
+
+	state := &vmState{Digest: digest, Profile: ew.Profile.Name}
+	snapDir, snap, restore := snapshot(ew.Name, digest)
+
+	var rootfs string
+	if restore {
+		slog.Debug("restoring VM from snapshot", "name", ew.Name, "snapshot", snapDir)
+		rootfs = filepath.Join(d, rootfsFile)
+		if err := copyFile(filepath.Join(snapDir, rootfsFile), rootfs); err != nil {
+			return fmt.Errorf("failed to copy snapshot root fs: %w", err)
+		}
+	} else {
+		rootfs, err = cachedRootFs(d, wl.Image, digest)
+		if err != nil {
+			return err
+		}
+		if err := installInit(rootfs); err != nil {
+			return err
+		}
+	}
+
+	v := vm{
+		kernel:    files.FirecrackerKernelPath(),
+		rootfs:    rootfs,
+		vsockPath: filepath.Join(d, "vsock.sock"),
+		args: []string{
+			"init=" + guest.InitPath,
+			guest.ConfigArg + "=" + guestDevice(1),
+			fmt.Sprintf("%s=vsock:%d", guest.X11Arg, x11Port),
+		},
+	}
+
+	// The config drive is attached right after the rootfs, followed by
+	// the drives for the workload paths.
+	drives, mounts, err := pathDrives(d, wl.HostAccess.Paths, 2)
 	if err != nil {
 		return err
 	}
 
-	rootfs, err := createRootFs(d, ew.Workload.Image)
+	display := ew.Profile.Display
+	if strings.EqualFold(os.Getenv("XDG_SESSION_TYPE"), "wayland") {
+		display = 0
+	}
+
+	cookie, err := files.ClientCookiePath(ew.Profile.Name)
 	if err != nil {
 		return err
 	}
 
-	kfile := filepath.Join(files.QubesomeDir(), kernelFile)
-	params := configParams{
-		KernelImagePath: kfile,
-		RootFsPath:      rootfs,
-		HostDeviceName:  networkDevName,
+	cd, err := configDrive(d, guestConfig{
+		profile:    ew.Profile.Name,
+		display:    display,
+		timezone:   ew.Profile.Timezone,
+		cookiePath: cookie,
+		command:    append([]string{wl.Command}, wl.Args...),
+		mounts:     mounts,
+		uid:        os.Getuid(),
+	})
+	if err != nil {
+		return err
+	}
+	v.drives = append([]drive{cd}, drives...)
+
+	if wl.HostAccess.Network != "none" {
+		t := &tap{}
+		if restore && snap.Tap != nil {
+			t.index = *snap.Tap
+		} else if t, err = allocateTap(tapExists); err != nil {
+			return err
+		}
+
+		if err := setupTap(t); err != nil {
+			return fmt.Errorf("failed to set up tap %q: %w", t.name(), err)
+		}
+		defer func() {
+			if err := removeTap(t); err != nil {
+				slog.Warn("failed to remove tap", "device name", t.name(), "error", err)
+			}
+		}()
+		v.tap = t
+		state.Tap = &t.index
 	}
 
-	cfgPath := filepath.Join(d, "firecracker.cfg")
+	bridge, err := newX11Bridge(v.vsockPath, fmt.Sprintf("/tmp/.X11-unix/X%d", display))
+	if err != nil {
+		return err
+	}
+	defer bridge.Close()
+	go bridge.Serve()
 
-	slog.Debug("writing firecracker config file", "path", cfgPath)
-	t, err := template.New("config").Parse(configTmpl)
+	cfg, err := newConfig(v, wl.Resources)
 	if err != nil {
 		return err
 	}
 
-	f, err := os.OpenFile(cfgPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, files.FileMode)
+	cmd, err := start(socket)
 	if err != nil {
-		return fmt.Errorf("failed to open firecracker config file: %w", err)
+		return err
 	}
 
-	if err := t.Execute(f, params); err != nil {
-		return fmt.Errorf("failed to create firecracker config contents: %w", err)
+	state.Pid = cmd.Process.Pid
+	if err := writeState(d, state); err != nil {
+		_ = cmd.Process.Kill()
+		return err
 	}
 
-	args := []string{
-		"--api-sock",
-		filepath.Join(d, "firecracker.sock"),
-		"--config-file",
-		cfgPath,
+	a := newAPIClient(socket)
+	if restore {
+		err = a.loadSnapshot(filepath.Join(snapDir, vmstateFile), filepath.Join(snapDir, memFile))
+	} else if err = a.configure(cfg); err == nil {
+		err = a.start()
+	}
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("failed to start VM: %w", err)
 	}
 
-	return run(args)
+	return cmd.Wait()
 }
 
-func run(args []string) error {
+// start starts the firecracker process, and waits for its API socket
+// to become available.
+func start(socket string) (*exec.Cmd, error) {
+	args := []string{"--api-sock", socket}
 	slog.Debug(files.FireCrackerBinary, "args", args)
 
-	ctx := context.Background()
-	cmd := execabs.CommandContext(ctx, files.FireCrackerBinary, args...)
-
+	cmd := execabs.Command(files.FireCrackerBinary, args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 
-	return cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	if err := waitForSocket(socket, 5*time.Second); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	return cmd, nil
 }
@@ -0,0 +1,266 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/qubesome/cli/internal/files"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	apiSocket   = "firecracker.sock"
+	stateFile   = "vm.yaml"
+	rootfsFile  = "rootfs.ext4"
+	vmstateFile = "vmstate"
+	memFile     = "mem"
+
+	stopTimeout = 10 * time.Second
+)
+
+var (
+	ErrNotRunning     = errors.New("firecracker workload is not running")
+	ErrAlreadyRunning = errors.New("firecracker workload is already running")
+)
+
+// vmState records how a VM was started, so that it can be managed
+// while running and restored from its snapshots.
+type vmState struct {
+	Pid    int    `yaml:"pid"`
+	Digest string `yaml:"digest"`
+	// Tap is the index of the tap device used by the VM, if any.
+	Tap *int `yaml:"tap,omitempty"`
+	// Profile is the profile the VM was started for.
+	Profile string `yaml:"profile,omitempty"`
+}
+
+// VM describes a running firecracker workload.
+type VM struct {
+	Name    string
+	State   string
+	Version string
+}
+
+func readState(dir string) (*vmState, error) {
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &vmState{}
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal firecracker state: %w", err)
+	}
+	return s, nil
+}
+
+func writeState(dir string, s *vmState) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, stateFile), data, files.FileMode)
+}
+
+// client returns an API client for the running workload name.
+func client(name string) (*apiClient, string, error) {
+	dir, err := files.FirecrackerRunDir(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	socket := filepath.Join(dir, apiSocket)
+	if !running(socket) {
+		return nil, "", fmt.Errorf("%w: %s", ErrNotRunning, name)
+	}
+	return newAPIClient(socket), dir, nil
+}
+
+// Stop gracefully shuts down the firecracker workload name, killing its
+// process if the guest does not shut down in time.
+func Stop(name string) error {
+	a, dir, err := client(name)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("shutting down firecracker VM", "name", name)
+	if err := a.shutdown(); err != nil {
+		return err
+	}
+
+	socket := filepath.Join(dir, apiSocket)
+	deadline := time.Now().Add(stopTimeout)
+	for time.Now().Before(deadline) {
+		if !running(socket) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	s, err := readState(dir)
+	if err != nil {
+		return fmt.Errorf("VM did not shut down and its pid is unknown: %w", err)
+	}
+
+	slog.Warn("VM did not shut down in time, killing it", "name", name, "pid", s.Pid)
+	p, err := os.FindProcess(s.Pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
+
+// StopProfile gracefully shuts down the firecracker workloads started
+// for profile.
+func StopProfile(profile string) error {
+	vms, err := VMs()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, v := range vms {
+		dir, err := files.FirecrackerRunDir(v.Name)
+		if err != nil {
+			return err
+		}
+		s, err := readState(dir)
+		if err != nil || s.Profile != profile {
+			continue
+		}
+		if err := Stop(v.Name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to stop %q: %w", v.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Snapshot takes a snapshot of the running firecracker workload name,
+// which is used to restore it on its next start. The VM is paused while
+// the snapshot is taken.
+func Snapshot(name string) error {
+	a, dir, err := client(name)
+	if err != nil {
+		return err
+	}
+
+	s, err := readState(dir)
+	if err != nil {
+		return err
+	}
+
+	sd, err := files.FirecrackerSnapshotDir(name)
+	if err != nil {
+		return err
+	}
+
+	// The snapshot is written to a temporary dir, so that an incomplete
+	// snapshot never replaces a good one.
+	tmp := sd + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tmp, files.DirMode); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := a.pause(); err != nil {
+		return err
+	}
+	defer func() {
+		if err := a.resume(); err != nil {
+			slog.Warn("failed to resume VM", "name", name, "error", err)
+		}
+	}()
+
+	if err := a.createSnapshot(filepath.Join(tmp, vmstateFile), filepath.Join(tmp, memFile)); err != nil {
+		return err
+	}
+	// The disk must match the memory state, so it is copied while paused.
+	if err := copyFile(filepath.Join(dir, rootfsFile), filepath.Join(tmp, rootfsFile)); err != nil {
+		return err
+	}
+	if err := writeState(tmp, s); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(sd); err != nil {
+		return err
+	}
+	return os.Rename(tmp, sd)
+}
+
+// DeleteSnapshot removes the snapshot of the firecracker workload name.
+func DeleteSnapshot(name string) error {
+	sd, err := files.FirecrackerSnapshotDir(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(sd); err != nil {
+		return fmt.Errorf("no snapshot found for %q: %w", name, err)
+	}
+	return os.RemoveAll(sd)
+}
+
+// snapshot returns the snapshot dir of name, if it can be restored for
+// a VM running the image with the given digest.
+func snapshot(name, digest string) (string, *vmState, bool) {
+	sd, err := files.FirecrackerSnapshotDir(name)
+	if err != nil {
+		return "", nil, false
+	}
+
+	s, err := readState(sd)
+	if err != nil {
+		return "", nil, false
+	}
+	if s.Digest != digest {
+		slog.Debug("ignoring snapshot of a different image", "name", name)
+		return "", nil, false
+	}
+	// The guest network config is part of the snapshot, so it can only
+	// be restored on the same tap device.
+	if s.Tap != nil && tapExists((&tap{index: *s.Tap}).name()) {
+		slog.Debug("ignoring snapshot as its tap is in use", "name", name)
+		return "", nil, false
+	}
+	return sd, s, true
+}
+
+// VMs returns the running firecracker workloads.
+func VMs() ([]VM, error) {
+	base := filepath.Join(files.RunUserQubesome(), "firecracker")
+	entries, err := os.ReadDir(base)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var vms []VM
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+
+		i, err := newAPIClient(filepath.Join(base, e.Name(), apiSocket)).info()
+		if err != nil {
+			continue
+		}
+		vms = append(vms, VM{Name: e.Name(), State: i.State, Version: i.VMMVersion})
+	}
+
+	slices.SortFunc(vms, func(a, b VM) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return vms, nil
+}
@@ -0,0 +1,78 @@
+package firecracker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+)
+
+// x11Port is the vsock port the guest connects to for X11. Firecracker
+// forwards guest connections on port P to the host unix socket at
+// <uds_path>_P.
+const x11Port = 6000
+
+// x11Bridge forwards X11 connections from the guest to the display of
+// the profile on the host.
+type x11Bridge struct {
+	l      net.Listener
+	target string
+}
+
+// newX11Bridge listens for guest connections on the vsock at vsockPath,
+// forwarding them to the host X11 socket at target.
+func newX11Bridge(vsockPath, target string) (*x11Bridge, error) {
+	path := fmt.Sprintf("%s_%d", vsockPath, x11Port)
+	_ = os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", path, err)
+	}
+
+	return &x11Bridge{l: l, target: target}, nil
+}
+
+func (b *x11Bridge) Serve() {
+	for {
+		conn, err := b.l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("x11 bridge stopped", "error", err)
+			}
+			return
+		}
+		go b.proxy(conn)
+	}
+}
+
+func (b *x11Bridge) Close() error {
+	return b.l.Close()
+}
+
+func (b *x11Bridge) proxy(conn net.Conn) {
+	defer conn.Close()
+
+	x, err := net.Dial("unix", b.target)
+	if err != nil {
+		slog.Warn("failed to connect to X11 display", "target", b.target, "error", err)
+		return
+	}
+	defer x.Close()
+
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go cp(x, conn)
+	go cp(conn, x)
+
+	<-done
+	<-done
+}
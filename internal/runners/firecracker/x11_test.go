@@ -0,0 +1,48 @@
+package firecracker
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestX11Bridge(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "X0")
+
+	x, err := net.Listen("unix", target)
+	require.NoError(t, err)
+	defer x.Close()
+
+	go func() {
+		conn, err := x.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(conn, conn)
+	}()
+
+	vsockPath := filepath.Join(dir, "vsock.sock")
+	b, err := newX11Bridge(vsockPath, target)
+	require.NoError(t, err)
+	defer b.Close()
+	go b.Serve()
+
+	conn, err := net.Dial("unix", fmt.Sprintf("%s_%d", vsockPath, x11Port))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	got := make([]byte, 4)
+	_, err = io.ReadFull(conn, got)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(got))
+}
@@ -0,0 +1,45 @@
+package nerdctl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"github.com/qubesome/cli/internal/types"
+	"golang.org/x/sys/execabs"
+)
+
+var runnerBinary = files.ContainerRunnerBinary("nerdctl")
+
+// engine returns the nerdctl settings. The containerd namespace is set
+// via CONTAINERD_NAMESPACE, which nerdctl reads for all commands.
+func engine() container.Engine {
+	// nerdctl relies on tini being installed on the host for --init.
+	_, err := execabs.LookPath("tini")
+
+	return container.Engine{
+		Binary: runnerBinary,
+		GPU:    "nerdctl",
+		Init:   err == nil,
+	}
+}
+
+func checkDaemon() error {
+	if !container.Rootless(runnerBinary) {
+		return nil
+	}
+
+	if _, err := os.Stat(filepath.Join(files.HostRuntimeDir(), "containerd-rootless")); err != nil {
+		return fmt.Errorf("rootless containerd not found, set it up with containerd-rootless-setuptool.sh install: %w", err)
+	}
+	return nil
+}
+
+func Run(ew types.EffectiveWorkload) error {
+	if err := checkDaemon(); err != nil {
+		return err
+	}
+	return container.Run(engine(), ew)
+}
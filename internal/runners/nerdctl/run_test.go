@@ -0,0 +1,105 @@
+package nerdctl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunArgs(t *testing.T) {
+	t.Setenv("XDG_SESSION_TYPE", "x11")
+	t.Setenv("CONTAINERD_NAMESPACE", "qubesome")
+
+	dir := t.TempDir()
+	profile := &types.Profile{Name: "i3", Display: 3, Timezone: "Europe/London"}
+	xterm := types.Workload{
+		Name:    "xterm",
+		Image:   "ghcr.io/qubesome/xterm:latest",
+		Command: "/usr/bin/xterm",
+		Args:    []string{"-e", "top"},
+	}
+
+	tests := []struct {
+		name    string
+		init    bool
+		modify  func(w *types.Workload)
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "defaults",
+			want: []string{
+				"run --rm -d --security-opt=no-new-privileges=true",
+				"-e=DISPLAY=:3",
+				"-v=/tmp/.X11-unix/X3:/tmp/.X11-unix/X3",
+				"-e=XAUTHORITY=/tmp/.Xauthority",
+				"-e=TZ=Europe/London",
+				"-h xterm-i3",
+				"--security-opt=label=disable",
+				"ghcr.io/qubesome/xterm:latest /usr/bin/xterm -e top",
+			},
+			notWant: []string{"--namespace", "--init", "--name=", "--privileged", "/dev/snd"},
+		},
+		{
+			name: "init with tini",
+			init: true,
+			want: []string{"--init"},
+		},
+		{
+			name: "single instance",
+			modify: func(w *types.Workload) {
+				w.SingleInstance = true
+			},
+			want: []string{"--name=xterm-i3"},
+		},
+		{
+			name: "host access",
+			modify: func(w *types.Workload) {
+				w.HostAccess = types.HostAccess{
+					Network:  "none",
+					Speakers: true,
+					CapsAdd:  []string{"NET_ADMIN"},
+					Paths:    []string{dir + ":/data", "/does/not/exist:/missing"},
+				}
+			},
+			want: []string{
+				"--network=none",
+				"--device=/dev/snd",
+				"--group-add=audio",
+				"--cap-add=NET_ADMIN",
+				"-v=" + dir + ":/data",
+			},
+			notWant: []string{"/missing"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := xterm
+			if tc.modify != nil {
+				tc.modify(&w)
+			}
+			e := engine()
+			e.Init = tc.init
+
+			args, err := container.RunArgs(e, types.EffectiveWorkload{
+				Name:     w.Name + "-" + profile.Name,
+				Profile:  profile,
+				Workload: w,
+			})
+			require.NoError(t, err)
+
+			got := strings.Join(args, " ")
+			for _, want := range tc.want {
+				assert.Contains(t, got, want)
+			}
+			for _, notWant := range tc.notWant {
+				assert.NotContains(t, got, notWant)
+			}
+		})
+	}
+}
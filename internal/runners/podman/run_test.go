@@ -0,0 +1,87 @@
+package podman
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunArgs(t *testing.T) {
+	t.Setenv("XDG_SESSION_TYPE", "x11")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	profile := &types.Profile{Name: "i3", Display: 3}
+	xterm := types.Workload{
+		Name:    "xterm",
+		Image:   "ghcr.io/qubesome/xterm:latest",
+		Command: "/usr/bin/xterm",
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(w *types.Workload)
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "defaults",
+			want: []string{
+				"run --rm -d --security-opt=no-new-privileges=true --group-add=keep-groups",
+				":/run/user/1000:z",
+				"--init",
+				"-e=DISPLAY=:3",
+				"ghcr.io/qubesome/xterm:latest /usr/bin/xterm",
+			},
+		},
+		{
+			name: "audio and camera keep the host groups",
+			modify: func(w *types.Workload) {
+				w.HostAccess = types.HostAccess{Speakers: true, Camera: true}
+			},
+			want: []string{
+				"-v=/run/user/1000/pipewire-0:/run/user/1000/pipewire-0:z",
+				"--device=/dev/snd",
+			},
+			notWant: []string{"--group-add=audio", "--group-add=video"},
+		},
+		{
+			name: "host dbus",
+			modify: func(w *types.Workload) {
+				w.HostAccess = types.HostAccess{Dbus: true}
+			},
+			want: []string{
+				"-v=/run/user/1000:/run/user/1000:z",
+				"-v=/run/dbus/system_bus_socket:/run/dbus/system_bus_socket:z",
+				"-v=/etc/machine-id:/etc/machine-id:ro",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := xterm
+			if tc.modify != nil {
+				tc.modify(&w)
+			}
+
+			args, err := container.RunArgs(engine(), types.EffectiveWorkload{
+				Name:     w.Name + "-" + profile.Name,
+				Profile:  profile,
+				Workload: w,
+			})
+			require.NoError(t, err)
+
+			got := strings.Join(args, " ")
+			for _, want := range tc.want {
+				assert.Contains(t, got, want)
+			}
+			for _, notWant := range tc.notWant {
+				assert.NotContains(t, got, notWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/qubesome/cli/internal/types"
+	"golang.org/x/sys/execabs"
+)
+
+const (
+	managedLabel = "io.qubesome.managed"
+	profileLabel = "io.qubesome.profile"
+
+	// WorkloadLabel holds the name of the effective workload.
+	WorkloadLabel = "io.qubesome.workload"
+	// EgressLabel holds the comma separated domains the workload may
+	// reach through the egress proxy.
+	EgressLabel = "io.qubesome.egress"
+)
+
+var (
+	// ErrRootlessGateway is returned when a profile listens on the gateway
+	// of its managed network with a rootless engine, whose networks are
+	// within a network namespace of their own.
+	ErrRootlessGateway = errors.New("the resolver and egress proxy require a rootful container engine")
+	// ErrGatewayNotOnHost is returned when the gateway of the managed
+	// network is not a host address.
+	ErrGatewayNotOnHost = errors.New("managed network gateway is not a host address")
+
+	interfaceAddrs = net.InterfaceAddrs
+)
+
+// listensOnGateway returns whether qubesome listens on the gateway of the
+// managed network of p.
+func listensOnGateway(p *types.Profile) bool {
+	return p.ManagedNetwork != nil && (p.Resolver != nil || p.Egress != nil)
+}
+
+// CheckGatewayEngine fails when p listens on the gateway of its managed
+// network and bin is a rootless engine, as the gateway would never be
+// reachable from the host.
+func CheckGatewayEngine(bin string, p *types.Profile) error {
+	if listensOnGateway(p) && Rootless(bin) {
+		return fmt.Errorf("%w: %s is rootless", ErrRootlessGateway, bin)
+	}
+	return nil
+}
+
+// WaitHostGateway waits for the gateway of the managed network of p to be
+// assigned to a host interface, which some engines only do once the
+// first container joins the network.
+func WaitHostGateway(p *types.Profile, timeout time.Duration) error {
+	if !listensOnGateway(p) {
+		return nil
+	}
+	gw, err := p.ManagedNetwork.Gateway()
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := hostAddr(gw)
+		if err != nil || ok {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%w: %s of network %q", ErrGatewayNotOnHost, gw, p.NetworkName())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func hostAddr(addr netip.Addr) (bool, error) {
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return false, err
+	}
+	for _, a := range addrs {
+		if n, ok := a.(*net.IPNet); ok {
+			if ip, ok := netip.AddrFromSlice(n.IP); ok && ip.Unmap() == addr {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// EnsureNetwork creates the managed network of the profile, unless it
+// already exists.
+func EnsureNetwork(bin string, p *types.Profile) error {
+	if p.ManagedNetwork == nil {
+		return nil
+	}
+
+	name := p.NetworkName()
+	if networkExists(bin, name) {
+		slog.Debug("managed network already exists", "network", name)
+		return nil
+	}
+
+	args, err := networkCreateArgs(p)
+	if err != nil {
+		return err
+	}
+	slog.Debug(bin+" network create", "args", args)
+	out, err := execabs.Command(bin, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to create network %q: %s: %w", name, out, err)
+	}
+	return nil
+}
+
+// RemoveNetwork removes the managed network of the profile. Networks not
+// created by qubesome are left untouched.
+func RemoveNetwork(bin string, p *types.Profile) error {
+	if p.ManagedNetwork == nil {
+		return nil
+	}
+
+	name := p.NetworkName()
+	out, err := execabs.Command(bin, "network", "inspect", "--format",
+		fmt.Sprintf("{{index .Labels %q}}", managedLabel), name).Output()
+	if err != nil {
+		return fmt.Errorf("cannot inspect network %q: %w", name, err)
+	}
+	if strings.TrimSpace(string(out)) != "true" {
+		slog.Debug("skipping removal of network not managed by qubesome", "network", name)
+		return nil
+	}
+
+	slog.Debug(bin+" network rm", "network", name)
+	out, err = execabs.Command(bin, "network", "rm", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to remove network %q: %s: %w", name, out, err)
+	}
+	return nil
+}
+
+func networkExists(bin, name string) bool {
+	return execabs.Command(bin, "network", "inspect", name).Run() == nil
+}
+
+func networkCreateArgs(p *types.Profile) ([]string, error) {
+	n := p.ManagedNetwork
+	args := []string{
+		"network", "create",
+		"--driver", "bridge",
+		"--label", managedLabel + "=true",
+		"--label", profileLabel + "=" + p.Name,
+	}
+	if n.Subnet != "" {
+		// The gateway is set explicitly, as the egress proxy listens on
+		// it across container engines.
+		gw, err := n.Gateway()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--subnet", n.Subnet, "--gateway", gw.String())
+	}
+	if n.Internal {
+		args = append(args, "--internal")
+	}
+	return append(args, p.NetworkName()), nil
+}
+
+// EgressArgs returns the args that route the workload through the egress
+// proxy of its profile. The labels identify the workload to the proxy.
+func EgressArgs(ew types.EffectiveWorkload) ([]string, error) {
+	if ew.Profile.Egress == nil || ew.Workload.HostAccess.Network == "none" {
+		return nil, nil
+	}
+
+	addr, err := ew.Profile.EgressProxy()
+	if err != nil {
+		return nil, err
+	}
+
+	var allow []string
+	if ew.Workload.Egress != nil {
+		allow = ew.Workload.Egress.Allow
+	}
+
+	args := []string{
+		"--label", WorkloadLabel + "=" + ew.Name,
+		"--label", EgressLabel + "=" + strings.Join(allow, ","),
+	}
+	for _, v := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		args = append(args, "-e", v+"=http://"+addr)
+	}
+	for _, v := range []string{"NO_PROXY", "no_proxy"} {
+		args = append(args, "-e", v+"=localhost,127.0.0.1")
+	}
+	return args, nil
+}
@@ -0,0 +1,146 @@
+package container
+
+import (
+	"net"
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkCreateArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile types.Profile
+		want    []string
+	}{
+		{
+			name: "default name",
+			profile: types.Profile{
+				Name:           "work",
+				ManagedNetwork: &types.ManagedNetwork{},
+			},
+			want: []string{
+				"network", "create", "--driver", "bridge",
+				"--label", "io.qubesome.managed=true",
+				"--label", "io.qubesome.profile=work",
+				"qubesome-work",
+			},
+		},
+		{
+			name: "internal network with subnet",
+			profile: types.Profile{
+				Name: "work",
+				ManagedNetwork: &types.ManagedNetwork{
+					Name:     "isolated",
+					Subnet:   "172.30.0.0/24",
+					Internal: true,
+				},
+			},
+			want: []string{
+				"network", "create", "--driver", "bridge",
+				"--label", "io.qubesome.managed=true",
+				"--label", "io.qubesome.profile=work",
+				"--subnet", "172.30.0.0/24",
+				"--gateway", "172.30.0.1",
+				"--internal",
+				"isolated",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := networkCreateArgs(&tc.profile)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestEgressArgs(t *testing.T) {
+	profile := &types.Profile{
+		Name:   "work",
+		Egress: &types.Egress{Allow: []string{"*.slack.com", "github.com"}},
+		ManagedNetwork: &types.ManagedNetwork{
+			Subnet:   "172.30.0.0/24",
+			Internal: true,
+		},
+	}
+
+	tests := []struct {
+		name    string
+		profile *types.Profile
+		network string
+		egress  *types.Egress
+		want    []string
+	}{
+		{
+			name:    "no egress proxy",
+			profile: &types.Profile{Name: "work"},
+		},
+		{
+			name:    "network none",
+			profile: profile,
+			network: "none",
+		},
+		{
+			name:    "workload domains",
+			profile: profile,
+			network: "qubesome-work",
+			egress:  &types.Egress{Allow: []string{"app.slack.com"}},
+			want: []string{
+				"--label", "io.qubesome.workload=slack-work",
+				"--label", "io.qubesome.egress=app.slack.com",
+				"-e", "HTTP_PROXY=http://172.30.0.1:3128",
+				"-e", "HTTPS_PROXY=http://172.30.0.1:3128",
+				"-e", "http_proxy=http://172.30.0.1:3128",
+				"-e", "https_proxy=http://172.30.0.1:3128",
+				"-e", "NO_PROXY=localhost,127.0.0.1",
+				"-e", "no_proxy=localhost,127.0.0.1",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ew := types.EffectiveWorkload{
+				Name:    "slack-work",
+				Profile: tc.profile,
+				Workload: types.Workload{
+					HostAccess: types.HostAccess{Network: tc.network},
+					Egress:     tc.egress,
+				},
+			}
+
+			got, err := EgressArgs(ew)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestWaitHostGateway(t *testing.T) {
+	old := interfaceAddrs
+	t.Cleanup(func() { interfaceAddrs = old })
+
+	addrs := []net.Addr{&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}}
+	interfaceAddrs = func() ([]net.Addr, error) { return addrs, nil }
+
+	p := &types.Profile{
+		Name:           "work",
+		ManagedNetwork: &types.ManagedNetwork{Subnet: "172.30.0.0/24"},
+		Resolver:       &types.Resolver{},
+	}
+
+	err := WaitHostGateway(p, 0)
+	require.ErrorIs(t, err, ErrGatewayNotOnHost)
+
+	addrs = append(addrs, &net.IPNet{IP: net.ParseIP("172.30.0.1"), Mask: net.CIDRMask(24, 32)})
+	require.NoError(t, WaitHostGateway(p, 0))
+
+	// Profiles that do not listen on the gateway are not checked.
+	addrs = nil
+	require.NoError(t, WaitHostGateway(&types.Profile{ManagedNetwork: p.ManagedNetwork}, 0))
+}
@@ -0,0 +1,34 @@
+package container
+
+import (
+	"strconv"
+
+	"github.com/qubesome/cli/internal/types"
+)
+
+// ResourceArgs returns the run args that enforce r, which are shared by
+// docker, podman and nerdctl.
+func ResourceArgs(r types.Resources) []string {
+	var args []string
+
+	if r.CPUs > 0 {
+		args = append(args, "--cpus="+strconv.FormatFloat(r.CPUs, 'f', -1, 64))
+	}
+	if r.Memory != "" {
+		args = append(args, "--memory="+r.Memory)
+	}
+	if r.MemorySwap != "" {
+		args = append(args, "--memory-swap="+r.MemorySwap)
+	}
+	if r.Pids > 0 {
+		args = append(args, "--pids-limit="+strconv.FormatInt(r.Pids, 10))
+	}
+	if r.ShmSize != "" {
+		args = append(args, "--shm-size="+r.ShmSize)
+	}
+	if r.BlkioWeight > 0 {
+		args = append(args, "--blkio-weight="+strconv.Itoa(int(r.BlkioWeight)))
+	}
+
+	return args
+}
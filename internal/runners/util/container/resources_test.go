@@ -0,0 +1,45 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		r    types.Resources
+		want []string
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name: "all limits",
+			r: types.Resources{
+				CPUs:        1.5,
+				Memory:      "1g",
+				MemorySwap:  "2g",
+				Pids:        256,
+				ShmSize:     "64m",
+				BlkioWeight: 100,
+			},
+			want: []string{
+				"--cpus=1.5",
+				"--memory=1g",
+				"--memory-swap=2g",
+				"--pids-limit=256",
+				"--shm-size=64m",
+				"--blkio-weight=100",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ResourceArgs(tc.r))
+		})
+	}
+}
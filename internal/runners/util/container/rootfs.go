@@ -0,0 +1,35 @@
+package container
+
+import (
+	"strings"
+
+	"github.com/qubesome/cli/internal/types"
+)
+
+// RootfsArgs returns the run args for a read-only root file system and
+// the tmpfs mounts of a workload.
+func RootfsArgs(readOnly bool, tmpfs []types.Tmpfs) []string {
+	var args []string
+
+	if readOnly {
+		args = append(args, "--read-only")
+	}
+
+	for _, t := range tmpfs {
+		var opts []string
+		if t.Size != "" {
+			opts = append(opts, "size="+t.Size)
+		}
+		if t.Mode != "" {
+			opts = append(opts, "mode="+t.Mode)
+		}
+
+		arg := "--tmpfs=" + t.Path
+		if len(opts) > 0 {
+			arg += ":" + strings.Join(opts, ",")
+		}
+		args = append(args, arg)
+	}
+
+	return args
+}
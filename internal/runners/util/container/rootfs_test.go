@@ -0,0 +1,42 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootfsArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		readOnly bool
+		tmpfs    []types.Tmpfs
+		want     []string
+	}{
+		{
+			name: "writable rootfs",
+		},
+		{
+			name:     "read-only rootfs with tmpfs",
+			readOnly: true,
+			tmpfs: []types.Tmpfs{
+				{Path: "/tmp"},
+				{Path: "/home/chrome/.cache", Size: "512m"},
+				{Path: "/run/app", Size: "1m", Mode: "0700"},
+			},
+			want: []string{
+				"--read-only",
+				"--tmpfs=/tmp",
+				"--tmpfs=/home/chrome/.cache:size=512m",
+				"--tmpfs=/run/app:size=1m,mode=0700",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, RootfsArgs(tc.readOnly, tc.tmpfs))
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package container
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/execabs"
+)
+
+// runcShim is the containerd shim used to run runc compatible runtimes.
+const runcShim = "containerd-shim-runc-v2"
+
+// ValidRuntime checks that the OCI runtime is available to the container
+// engine behind bin.
+func ValidRuntime(bin, runtime string) error {
+	if runtime == "" {
+		return nil
+	}
+
+	switch filepath.Base(bin) {
+	case "docker":
+		out, err := Info(bin, "{{json .Runtimes}}")
+		if err != nil {
+			return fmt.Errorf("cannot list docker runtimes: %w", err)
+		}
+
+		runtimes, err := dockerRuntimes(out)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(runtimes, runtime) {
+			return fmt.Errorf("runtime %q is not configured in docker: available runtimes: %s", runtime, strings.Join(runtimes, ", "))
+		}
+		return nil
+
+	case "podman":
+		out, err := Info(bin, "{{json .Host.OCIRuntime}}")
+		if err != nil {
+			return fmt.Errorf("cannot get podman runtime: %w", err)
+		}
+
+		def, err := podmanRuntime(out)
+		if err != nil {
+			return err
+		}
+		if runtime == def {
+			return nil
+		}
+
+		runtimes, err := podmanRuntimes(containersConfs())
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(runtimes, runtime) {
+			return fmt.Errorf("runtime %q is not configured in podman's containers.conf [engine.runtimes] (default runtime is %q)", runtime, def)
+		}
+		return nil
+
+	default:
+		// containerd resolves runtimes to their shim binaries, while
+		// nerdctl runs other runc compatible binaries with the runc shim.
+		shim := shimBinary(runtime)
+		if _, err := execabs.LookPath(shim); err == nil {
+			return nil
+		}
+		if !strings.HasPrefix(runtime, "io.containerd.") {
+			if _, err := execabs.LookPath(runcShim); err == nil {
+				if _, err := execabs.LookPath(runtime); err == nil {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("runtime %q not found: %s is not installed", runtime, shim)
+	}
+}
+
+// dockerRuntimes returns the sorted runtime names from the JSON output
+// of docker info's Runtimes.
+func dockerRuntimes(data []byte) ([]string, error) {
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("cannot parse docker runtimes: %w", err)
+	}
+
+	runtimes := make([]string, 0, len(m))
+	for name := range m {
+		runtimes = append(runtimes, name)
+	}
+	slices.Sort(runtimes)
+	return runtimes, nil
+}
+
+// podmanRuntime returns the name of the default runtime from the JSON
+// output of podman info's Host.OCIRuntime.
+func podmanRuntime(data []byte) (string, error) {
+	var r struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return "", fmt.Errorf("cannot parse podman runtime: %w", err)
+	}
+	return r.Name, nil
+}
+
+// containersConfs returns the containers.conf files podman loads, which
+// CONTAINERS_CONF replaces altogether.
+func containersConfs() []string {
+	if f := os.Getenv("CONTAINERS_CONF"); f != "" {
+		return []string{f}
+	}
+
+	dirs := []string{"/usr/share/containers", "/etc/containers"}
+	if config, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(config, "containers"))
+	}
+
+	var confs []string
+	for _, dir := range dirs {
+		confs = append(confs, filepath.Join(dir, "containers.conf"))
+		dropIns, _ := filepath.Glob(filepath.Join(dir, "containers.conf.d", "*.conf"))
+		confs = append(confs, dropIns...)
+	}
+	if f := os.Getenv("CONTAINERS_CONF_OVERRIDE"); f != "" {
+		confs = append(confs, f)
+	}
+	return confs
+}
+
+var (
+	tomlTable = regexp.MustCompile(`^\[\s*([^\]]+?)\s*\]\s*(#.*)?$`)
+	tomlKey   = regexp.MustCompile(`^"?([A-Za-z0-9_.-]+)"?\s*=`)
+)
+
+// podmanRuntimes returns the sorted runtime names set under the
+// [engine.runtimes] table of the given containers.conf files. Missing
+// files are skipped.
+func podmanRuntimes(confs []string) ([]string, error) {
+	var runtimes []string
+	for _, fn := range confs {
+		f, err := os.Open(fn)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		in := false
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			line := strings.TrimSpace(s.Text())
+			if m := tomlTable.FindStringSubmatch(line); m != nil {
+				in = m[1] == "engine.runtimes"
+				continue
+			}
+			if m := tomlKey.FindStringSubmatch(line); in && m != nil && !slices.Contains(runtimes, m[1]) {
+				runtimes = append(runtimes, m[1])
+			}
+		}
+		f.Close()
+		if err := s.Err(); err != nil {
+			return nil, fmt.Errorf("cannot read %q: %w", fn, err)
+		}
+	}
+
+	slices.Sort(runtimes)
+	return runtimes, nil
+}
+
+// shimBinary returns the containerd shim that backs a runtime. Runtime
+// names (io.containerd.<name>.<version>) map to their shims, and short
+// names to the v1 shim of the same name, e.g. containerd-shim-runsc-v1.
+func shimBinary(runtime string) string {
+	parts := strings.Split(runtime, ".")
+	if len(parts) == 4 && parts[0] == "io" && parts[1] == "containerd" {
+		return fmt.Sprintf("containerd-shim-%s-%s", parts[2], parts[3])
+	}
+	return fmt.Sprintf("containerd-shim-%s-v1", runtime)
+}
@@ -0,0 +1,110 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDockerRuntimes(t *testing.T) {
+	out := []byte(`{"io.containerd.runc.v2":{"path":"runc"},"runc":{"path":"runc"},"runsc":{"path":"/usr/local/bin/runsc"}}` + "\n")
+
+	got, err := dockerRuntimes(out)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"io.containerd.runc.v2", "runc", "runsc"}, got)
+
+	_, err = dockerRuntimes([]byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestPodmanRuntimes(t *testing.T) {
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "containers.conf")
+	require.NoError(t, os.WriteFile(conf, []byte(`[containers]
+runc = ["/usr/bin/runc"]
+
+[engine]
+runtime = "crun"
+
+[engine.runtimes]
+# Comments are ignored.
+kata = [
+  "/usr/bin/kata-runtime",
+]
+"runsc" = ["/usr/local/bin/runsc"]
+
+[engine.runtimes_flags]
+youki = ["--debug"]
+`), 0o600))
+	dropIn := filepath.Join(dir, "override.conf")
+	require.NoError(t, os.WriteFile(dropIn, []byte("[ engine.runtimes ]\ncrun-vm = [\"/usr/bin/crun-vm\"]\nkata = []\n"), 0o600))
+
+	got, err := podmanRuntimes([]string{conf, dropIn, filepath.Join(dir, "missing.conf")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"crun-vm", "kata", "runsc"}, got)
+}
+
+func TestShimBinary(t *testing.T) {
+	tests := map[string]string{
+		"runsc":                  "containerd-shim-runsc-v1",
+		"crun":                   "containerd-shim-crun-v1",
+		"io.containerd.runsc.v1": "containerd-shim-runsc-v1",
+		"io.containerd.kata.v2":  "containerd-shim-kata-v2",
+	}
+
+	for in, want := range tests {
+		t.Run(in, func(t *testing.T) {
+			assert.Equal(t, want, shimBinary(in))
+		})
+	}
+}
+
+func TestValidRuntime(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	for _, name := range []string{"containerd-shim-runsc-v1", runcShim, "crun"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o755))
+	}
+
+	old := infoFunc
+	t.Cleanup(func() { infoFunc = old })
+	infoFunc = func(string, string) ([]byte, error) {
+		return []byte(`{"name":"crun","package":"crun-1.14","path":"/usr/bin/crun"}`), nil
+	}
+	conf := filepath.Join(t.TempDir(), "containers.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("[engine.runtimes]\nrunsc = [\"/usr/local/bin/runsc\"]\n"), 0o600))
+	t.Setenv("CONTAINERS_CONF", conf)
+	podman := filepath.Join(t.TempDir(), "podman")
+	nerdctl := filepath.Join(t.TempDir(), "nerdctl")
+
+	tests := []struct {
+		name    string
+		bin     string
+		runtime string
+		wantErr bool
+	}{
+		{name: "podman default", bin: podman, runtime: "crun"},
+		{name: "podman configured", bin: podman, runtime: "runsc"},
+		{name: "podman only on PATH", bin: podman, runtime: "containerd-shim-runsc-v1", wantErr: true},
+		{name: "podman missing", bin: podman, runtime: "kata", wantErr: true},
+		{name: "nerdctl shim", bin: nerdctl, runtime: "runsc"},
+		{name: "nerdctl full name", bin: nerdctl, runtime: "io.containerd.runsc.v1"},
+		{name: "nerdctl runc compatible", bin: nerdctl, runtime: "crun"},
+		{name: "nerdctl missing binary", bin: nerdctl, runtime: "youki", wantErr: true},
+		{name: "nerdctl missing", bin: nerdctl, runtime: "io.containerd.kata.v2", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidRuntime(tc.bin, tc.runtime)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package container
+
+import (
+	"fmt"
+	"os"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/qubesome/cli/internal/types"
+)
+
+// SecurityArgs returns the run args that apply s. Seccomp profiles are
+// resolved within profileDir. SELinux labeling is disabled unless s sets
+// a label, as mounts are not relabeled.
+func SecurityArgs(s types.Security, profileDir string) ([]string, error) {
+	var args []string
+
+	switch s.Seccomp {
+	case "", types.SeccompDefault:
+	case types.SeccompUnconfined:
+		args = append(args, "--security-opt=seccomp=unconfined")
+	default:
+		path, err := securejoin.SecureJoin(profileDir, s.Seccomp)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("cannot find seccomp profile: %w", err)
+		}
+		args = append(args, "--security-opt=seccomp="+path)
+	}
+
+	if s.AppArmor != "" {
+		args = append(args, "--security-opt=apparmor="+s.AppArmor)
+	}
+	label := s.SELinuxLabel
+	if label == "" {
+		label = types.SELinuxDisable
+	}
+	args = append(args, "--security-opt=label="+label)
+
+	return args, nil
+}
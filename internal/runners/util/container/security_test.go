@@ -0,0 +1,72 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityArgs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "seccomp.json"), []byte("{}"), 0o600))
+
+	tests := []struct {
+		name    string
+		s       types.Security
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "engine defaults",
+			want: []string{"--security-opt=label=disable"},
+		},
+		{
+			name: "explicit default seccomp",
+			s:    types.Security{Seccomp: types.SeccompDefault},
+			want: []string{"--security-opt=label=disable"},
+		},
+		{
+			name: "selinux label",
+			s:    types.Security{SELinuxLabel: "type:container_t"},
+			want: []string{"--security-opt=label=type:container_t"},
+		},
+		{
+			name: "unconfined",
+			s:    types.Security{Seccomp: "unconfined", AppArmor: "unconfined", SELinuxLabel: "disable"},
+			want: []string{
+				"--security-opt=seccomp=unconfined",
+				"--security-opt=apparmor=unconfined",
+				"--security-opt=label=disable",
+			},
+		},
+		{
+			name: "seccomp profile within profile dir",
+			s:    types.Security{Seccomp: "seccomp.json"},
+			want: []string{
+				"--security-opt=seccomp=" + filepath.Join(dir, "seccomp.json"),
+				"--security-opt=label=disable",
+			},
+		},
+		{
+			name:    "missing seccomp profile",
+			s:       types.Security{Seccomp: "missing.json"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := SecurityArgs(tc.s, dir)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/qubesome/cli/internal/files"
+	"golang.org/x/sys/execabs"
+)
+
+// containerUID is the UID of the user within qubesome images.
+const containerUID = 1000
+
+type infoKey struct {
+	bin    string
+	format string
+}
+
+var (
+	infoMu    sync.Mutex
+	infoCache = map[infoKey][]byte{}
+
+	// infoFunc runs <bin> info with the given format.
+	infoFunc = func(bin, format string) ([]byte, error) {
+		return execabs.Command(bin, "info", "--format", format).Output()
+	}
+)
+
+// Info returns the output of <bin> info for format. Successful results are
+// cached, as info is slow on some engines.
+func Info(bin, format string) ([]byte, error) {
+	infoMu.Lock()
+	defer infoMu.Unlock()
+
+	k := infoKey{bin, format}
+	if out, ok := infoCache[k]; ok {
+		return out, nil
+	}
+	out, err := infoFunc(bin, format)
+	if err != nil {
+		return nil, err
+	}
+	out = bytes.TrimSpace(out)
+	infoCache[k] = out
+	return out, nil
+}
+
+// Rootless returns whether the container engine behind bin runs rootless.
+func Rootless(bin string) bool {
+	format := "{{json .SecurityOptions}}"
+	if filepath.Base(bin) == "podman" {
+		format = "{{.Host.Security.Rootless}}"
+	}
+
+	out, err := Info(bin, format)
+	if err != nil {
+		slog.Debug("cannot detect whether engine is rootless", "binary", bin, "error", err)
+		return false
+	}
+	return bytes.Equal(out, []byte("true")) || bytes.Contains(out, []byte("rootless"))
+}
+
+// UserArgs returns the run args that map the host user into the container,
+// so that the host sockets and files mounted into it remain accessible.
+// When user is set, it takes precedence over the container user.
+func UserArgs(bin string, user *int) []string {
+	// The podman user namespace does not depend on whether it is rootless.
+	if filepath.Base(bin) == "podman" {
+		return userArgs(bin, false, user, os.Getuid(), os.Getgid())
+	}
+	return userArgs(bin, Rootless(bin), user, os.Getuid(), os.Getgid())
+}
+
+func userArgs(bin string, rootless bool, user *int, uid, gid int) []string {
+	engine := filepath.Base(bin)
+	switch {
+	case engine == "podman":
+		// Always map the host user to the container user, so that
+		// the host files are accessible regardless of the user the
+		// workload runs as.
+		args := []string{fmt.Sprintf("--userns=keep-id:uid=%d,gid=%d", containerUID, containerUID)}
+		if user != nil {
+			args = append(args, fmt.Sprintf("--user=%d", *user))
+		}
+		return args
+	case user != nil:
+		return []string{fmt.Sprintf("--user=%d", *user)}
+	case rootless:
+		// Rootless docker and nerdctl map the container root user to
+		// the host user, so that is the only user that can access
+		// the host files.
+		return []string{"--user=0:0"}
+	case uid != containerUID:
+		return []string{fmt.Sprintf("--user=%d:%d", uid, gid)}
+	}
+
+	return nil
+}
+
+// RuntimeDirEnv returns the env vars that point to the runtime dir
+// within the container, as the host values may refer to a different UID.
+func RuntimeDirEnv(containerDir string) []string {
+	env := []string{"-e=XDG_RUNTIME_DIR=" + containerDir}
+	if addr := os.Getenv("DBUS_SESSION_BUS_ADDRESS"); addr != "" {
+		addr = strings.ReplaceAll(addr, files.HostRuntimeDir(), containerDir)
+		env = append(env, "-e=DBUS_SESSION_BUS_ADDRESS="+addr)
+	}
+	return env
+}
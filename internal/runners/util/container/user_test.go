@@ -0,0 +1,116 @@
+package container
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		bin      string
+		rootless bool
+		user     *int
+		uid      int
+		want     []string
+	}{
+		{
+			name: "rootful docker with uid 1000",
+			bin:  "/usr/bin/docker",
+			uid:  1000,
+		},
+		{
+			name: "rootful docker with other uid",
+			bin:  "/usr/bin/docker",
+			uid:  1001,
+			want: []string{"--user=1001:100"},
+		},
+		{
+			name:     "rootless docker",
+			bin:      "/usr/bin/docker",
+			rootless: true,
+			uid:      1001,
+			want:     []string{"--user=0:0"},
+		},
+		{
+			name:     "rootless nerdctl",
+			bin:      "/usr/local/bin/nerdctl",
+			rootless: true,
+			uid:      1000,
+			want:     []string{"--user=0:0"},
+		},
+		{
+			name:     "rootless podman",
+			bin:      "/usr/bin/podman",
+			rootless: true,
+			uid:      1001,
+			want:     []string{"--userns=keep-id:uid=1000,gid=1000"},
+		},
+		{
+			name: "rootful podman with other uid",
+			bin:  "/usr/bin/podman",
+			uid:  500,
+			want: []string{"--userns=keep-id:uid=1000,gid=1000"},
+		},
+		{
+			name: "podman with explicit user",
+			bin:  "/usr/bin/podman",
+			user: ptr(0),
+			uid:  1001,
+			want: []string{"--userns=keep-id:uid=1000,gid=1000", "--user=0"},
+		},
+		{
+			name:     "rootless docker with explicit user",
+			bin:      "/usr/bin/docker",
+			rootless: true,
+			user:     ptr(1000),
+			uid:      1001,
+			want:     []string{"--user=1000"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, userArgs(tc.bin, tc.rootless, tc.user, tc.uid, 100))
+		})
+	}
+}
+
+func TestUserArgsPodmanInfoFails(t *testing.T) {
+	old := infoFunc
+	t.Cleanup(func() { infoFunc = old })
+	infoFunc = func(string, string) ([]byte, error) { return nil, errors.New("podman info failed") }
+
+	assert.Equal(t, []string{"--userns=keep-id:uid=1000,gid=1000"}, UserArgs("/usr/bin/podman", nil))
+}
+
+func TestInfoCache(t *testing.T) {
+	old := infoFunc
+	t.Cleanup(func() { infoFunc = old })
+
+	calls := 0
+	infoFunc = func(string, string) ([]byte, error) {
+		calls++
+		return []byte("[\"name=rootless\"]\n"), nil
+	}
+
+	assert.True(t, Rootless("/test/docker"))
+	assert.True(t, Rootless("/test/docker"))
+	assert.Equal(t, 1, calls)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestRuntimeDirEnv(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1001")
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/run/user/1001/bus")
+
+	assert.Equal(t, []string{
+		"-e=XDG_RUNTIME_DIR=/run/user/1000",
+		"-e=DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/1000/bus",
+	}, RuntimeDirEnv("/run/user/1000"))
+}
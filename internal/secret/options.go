@@ -0,0 +1,49 @@
+package secret
+
+import (
+	"fmt"
+
+	"github.com/qubesome/cli/internal/command"
+	"github.com/qubesome/cli/internal/keyring"
+)
+
+// settable holds the secrets that users can manage. Other secrets, such
+// as the mTLS ones, are managed by qubesome itself.
+var settable = map[keyring.SecretName]struct{}{
+	keyring.GitToken: {},
+}
+
+type Options struct {
+	Name   string
+	Host   string
+	Delete bool
+}
+
+func WithName(name string) command.Option[Options] {
+	return func(o *Options) {
+		o.Name = name
+	}
+}
+
+func WithHost(host string) command.Option[Options] {
+	return func(o *Options) {
+		o.Host = host
+	}
+}
+
+func WithDelete() command.Option[Options] {
+	return func(o *Options) {
+		o.Delete = true
+	}
+}
+
+func (o *Options) Validate() error {
+	if _, ok := settable[keyring.SecretName(o.Name)]; !ok {
+		return fmt.Errorf("unsupported secret %q: supported secrets: %s", o.Name, keyring.GitToken)
+	}
+	// Tokens are only ever sent to the host they were set for.
+	if o.Host == "" {
+		return fmt.Errorf("secret %q requires a host", o.Name)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package secret
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/qubesome/cli/internal/command"
+	"github.com/qubesome/cli/internal/keyring"
+	"github.com/qubesome/cli/internal/keyring/backend"
+	"golang.org/x/term"
+)
+
+// Run stores a secret in the qubesome keyring, reading its value from
+// stdin, or deletes it.
+func Run(opts ...command.Option[Options]) error {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	ks := keyring.New(keyring.GitHostScope(o.Host), backend.New())
+	name := keyring.SecretName(o.Name)
+
+	if o.Delete {
+		return ks.Delete(name)
+	}
+
+	value, err := readValue(o.Name)
+	if err != nil {
+		return err
+	}
+
+	return ks.Set(name, value)
+}
+
+func readValue(name string) (string, error) {
+	fd := int(os.Stdin.Fd()) //nolint:gosec // G115: fd values fit in int
+	if term.IsTerminal(fd) {
+		fmt.Printf("Enter value for %s: ", name)
+		data, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		return validValue(string(data))
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("cannot read secret from stdin: %w", err)
+	}
+	return validValue(line)
+}
+
+func validValue(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", errors.New("secret value cannot be empty")
+	}
+	return v, nil
+}
@@ -0,0 +1,59 @@
+package types
+
+import (
+	"regexp"
+	"slices"
+)
+
+// DefaultClipboardTargets are the selection targets transferred when a
+// profile does not set its own. They are listed by preference, which is
+// used to pick a target when none is requested.
+var DefaultClipboardTargets = []string{
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"UTF8_STRING",
+	"text/plain;charset=utf-8",
+	"text/plain",
+	"STRING",
+	"text/uri-list",
+	"text/html",
+}
+
+// clipboardTargetRegex matches MIME types, with an optional charset, and
+// X atoms such as UTF8_STRING.
+var clipboardTargetRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9!#$&^_.+\-]*(/[a-zA-Z0-9][a-zA-Z0-9!#$&^_.+\-]*(;charset=[a-zA-Z0-9_\-]+)?)?$`)
+
+// Clipboard defines how the clipboard of a profile is shared.
+type Clipboard struct {
+	// Targets are the selection targets which can be copied to and from
+	// the profile, by preference. Defaults to DefaultClipboardTargets.
+	Targets []string `yaml:"targets"`
+}
+
+func (c Clipboard) Validate() error {
+	for _, t := range c.Targets {
+		if err := valid(t, "clipboard target", 100, false, clipboardTargetRegex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClipboardTargets returns the selection targets allowed for the profile.
+func (p *Profile) ClipboardTargets() []string {
+	if p.Clipboard == nil || len(p.Clipboard.Targets) == 0 {
+		return DefaultClipboardTargets
+	}
+	return p.Clipboard.Targets
+}
+
+// ValidClipboardTarget returns whether t is a well-formed target.
+func ValidClipboardTarget(t string) bool {
+	return len(t) <= 100 && clipboardTargetRegex.MatchString(t)
+}
+
+// AllowsClipboardTarget returns whether the profile allows t.
+func (p *Profile) AllowsClipboardTarget(t string) bool {
+	return slices.Contains(p.ClipboardTargets(), t)
+}
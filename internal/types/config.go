@@ -18,8 +18,10 @@ var (
 	nameRegex         = regexp.MustCompile(`^[a-zA-Z0-9\-]+$`)
 	imageRegex        = regexp.MustCompile(`^(?:(?:[a-z0-9]+(?:[._-][a-z0-9]+)*)+\/)?(?:[a-z0-9]+(?:[._-][a-z0-9]+)*)+(?:[:/][a-z0-9]+(?:[._-][a-z0-9]+)*)+$`)
 	ipRegex           = regexp.MustCompile(`^(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.(25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)$`)
-	runnerRegex       = regexp.MustCompile(`^(docker|podman|firecracker)$`)
+	runnerRegex       = regexp.MustCompile(`^(docker|podman|nerdctl|firecracker|bwrap)$`)
+	backendRegex      = regexp.MustCompile(`^(xephyr|xwayland|headless)$`)
 	externalPathRegex = regexp.MustCompile(`^[a-zA-Z0-9\-]+:/[^:]+:/[^:]+$`)
+	runtimeRegex      = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._\-]*$`)
 	pathRegex         = regexp.MustCompile(`^(\${[a-zA-Z0-9\-]+}){0,1}/[^:]+:/[^:]+(:ro){0,1}$`)
 )
 
@@ -36,6 +38,25 @@ type Config struct {
 	// WorkloadPullMode defines how workload images should be pulled.
 	WorkloadPullMode WorkloadPullMode `yaml:"workloadPullMode"`
 
+	// RequireSignedCommits blocks profiles from being started or updated
+	// from git commits that are not signed by one of the AllowedSigners.
+	// This is only honoured in the user-level config.
+	RequireSignedCommits bool `yaml:"requireSignedCommits"`
+
+	// AllowedSigners is the path to an armored OpenPGP keyring with the
+	// keys trusted to sign commits. Defaults to ~/.qubesome/allowed-signers.asc.
+	AllowedSigners string `yaml:"allowedSigners"`
+
+	// GitSSHKey is the private key file used to authenticate against SSH
+	// git remotes. When not set, the IdentityFile from ~/.ssh/config or
+	// the ssh-agent are used instead. This is only honoured in the
+	// user-level config.
+	GitSSHKey string `yaml:"gitSSHKey"`
+
+	// Firecracker configures the firecracker runner. This is only
+	// honoured in the user-level config.
+	Firecracker Firecracker `yaml:"firecracker"`
+
 	RootDir string
 }
 
@@ -86,6 +107,14 @@ func (c *Config) WorkloadFiles() ([]string, error) {
 	return matches, nil
 }
 
+type Firecracker struct {
+	// KernelURL is where the kernel image used to boot VMs is downloaded from.
+	KernelURL string `yaml:"kernelURL"`
+	// KernelSHA256 is the expected hex-encoded SHA-256 of the kernel image.
+	// It is required to download the kernel image.
+	KernelSHA256 string `yaml:"kernelSHA256"`
+}
+
 type Logging struct {
 	LogToFile   bool   `yaml:"logToFile"`
 	LogToStdout bool   `yaml:"logToStdout"`
@@ -109,6 +138,23 @@ type Profile struct {
 	Path   string `yaml:"path"`
 	Runner string `yaml:"runner"`
 
+	// Runtimes defines the OCI runtimes (e.g. runsc, crun) workloads
+	// are allowed to select. Workloads can only use the engine's default
+	// runtime when this is empty.
+	Runtimes []string `yaml:"runtimes"`
+
+	// ReadOnlyRootfs enforces a read-only root file system on all of the
+	// profile's workloads.
+	ReadOnlyRootfs bool `yaml:"readOnlyRootfs"`
+
+	// ForbidUnconfined blocks workloads from disabling seccomp or AppArmor
+	// confinement.
+	ForbidUnconfined bool `yaml:"forbidUnconfined"`
+
+	// Resources defines the resource limits for the profile's workloads.
+	// Workload limits above these are capped.
+	Resources Resources `yaml:"resources"`
+
 	// HostAccess defines all the access request which are allowed for
 	// its workloads.
 	HostAccess `yaml:"hostAccess"`
@@ -136,6 +182,21 @@ type Profile struct {
 
 	DNS string `yaml:"dns"`
 
+	// ManagedNetwork defines a network for the profile's containers,
+	// which is created and removed by qubesome. When set, Network must
+	// be empty or match its name.
+	ManagedNetwork *ManagedNetwork `yaml:"managedNetwork"`
+
+	// Egress enables a filtering proxy for the profile, which becomes
+	// the only route out of its ManagedNetwork. This requires the managed
+	// network to be internal and have a subnet.
+	Egress *Egress `yaml:"egress"`
+
+	// Resolver enables a DNS forwarder for the profile, which is used by
+	// its containers instead of DNS. This requires a ManagedNetwork with a
+	// subnet.
+	Resolver *Resolver `yaml:"resolver"`
+
 	// WindowManager holds the command to run the Window Manager once
 	// the X server is running.
 	//
@@ -144,6 +205,33 @@ type Profile struct {
 
 	// XephyrArgs defines additional args to be passed on to Xephyr.
 	XephyrArgs string `yaml:"xephyrArgs"`
+
+	// DisplayBackend selects the X server of the profile: xephyr,
+	// xwayland or headless. When not set, xwayland is used on Wayland
+	// hosts and xephyr otherwise. Headless displays run on Xvfb and are
+	// viewed with qubesome attach, which requires Xvfb and x11vnc to be
+	// installed in the profile image.
+	DisplayBackend string `yaml:"displayBackend"`
+
+	// Screens defines the screens of the profile's display. When not set,
+	// a single screen with the primary output's resolution is used.
+	Screens *Screens `yaml:"screens"`
+
+	// DPI sets the resolution of the profile's display, e.g. 144. When not
+	// set, it is based on Scale.
+	DPI int `yaml:"dpi"`
+
+	// Scale sets the display resolution relative to 96 DPI, e.g. 1.5 for
+	// HiDPI screens.
+	Scale float64 `yaml:"scale"`
+
+	// Keyboard defines the keyboard layout of the profile's display, which
+	// can differ from the host's.
+	Keyboard *Keyboard `yaml:"keyboard"`
+
+	// Clipboard defines the selection targets which can be copied to and
+	// from the profile.
+	Clipboard *Clipboard `yaml:"clipboard"`
 }
 
 func valid(val, field string, maxLen int, allowEmpty bool, format *regexp.Regexp) error {
@@ -184,6 +272,65 @@ func (p Profile) Validate() error {
 	if err := valid(p.Runner, "runner", 20, true, runnerRegex); err != nil {
 		return err
 	}
+	if err := valid(p.DisplayBackend, "displayBackend", 20, true, backendRegex); err != nil {
+		return err
+	}
+	if err := p.Resources.Validate(); err != nil {
+		return err
+	}
+	if p.ManagedNetwork != nil {
+		if err := p.ManagedNetwork.Validate(); err != nil {
+			return err
+		}
+		if p.Network != "" && p.Network != p.NetworkName() {
+			return fmt.Errorf("network %q does not match managedNetwork %q", p.Network, p.NetworkName())
+		}
+	}
+	if p.Egress != nil {
+		if err := p.Egress.Validate(); err != nil {
+			return err
+		}
+		if p.ManagedNetwork == nil || !p.ManagedNetwork.Internal || p.ManagedNetwork.Subnet == "" {
+			return fmt.Errorf("egress requires an internal managedNetwork with a subnet")
+		}
+	}
+	if p.DPI != 0 && (p.DPI < minDPI || p.DPI > maxDPI) {
+		return fmt.Errorf("dpi must be between %d and %d: %d", minDPI, maxDPI, p.DPI)
+	}
+	if p.Scale != 0 && (p.Scale < minScale || p.Scale > maxScale) {
+		return fmt.Errorf("scale must be between %v and %v: %v", minScale, maxScale, p.Scale)
+	}
+	if p.Keyboard != nil {
+		if err := p.Keyboard.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Clipboard != nil {
+		if err := p.Clipboard.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Screens != nil {
+		if err := p.Screens.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Resolver != nil {
+		if err := p.Resolver.Validate(); err != nil {
+			return err
+		}
+		if p.ManagedNetwork == nil || p.ManagedNetwork.Subnet == "" {
+			return fmt.Errorf("resolver requires a managedNetwork with a subnet")
+		}
+		if p.DNS != "" || len(p.ManagedNetwork.DNS) > 0 {
+			return fmt.Errorf("resolver cannot be used with dns servers")
+		}
+	}
+	for _, rt := range p.Runtimes {
+		if err := valid(rt, "runtimes", 50, false, runtimeRegex); err != nil {
+			return err
+		}
+	}
 	for _, path := range p.Paths {
 		if err := valid(path, "paths", 500, false, pathRegex); err != nil {
 			return err
@@ -259,6 +259,205 @@ func TestProfileValidate(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"managedNetwork: valid",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				ManagedNetwork: &ManagedNetwork{
+					Subnet: "172.30.0.0/24",
+					DNS:    []string{"172.30.0.2"},
+				},
+			},
+			false,
+		},
+		{
+			"managedNetwork: valid matching network",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				HostAccess:     HostAccess{Network: "qubesome-valid"},
+				ManagedNetwork: &ManagedNetwork{},
+			},
+			false,
+		},
+		{
+			"managedNetwork: invalid mismatching network",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				HostAccess:     HostAccess{Network: "other"},
+				ManagedNetwork: &ManagedNetwork{},
+			},
+			true,
+		},
+		{
+			"managedNetwork: invalid subnet",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				ManagedNetwork: &ManagedNetwork{Subnet: "172.30.0.0"},
+			},
+			true,
+		},
+		{
+			"egress: valid",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Egress:        &Egress{Allow: []string{"*.slack.com"}},
+				ManagedNetwork: &ManagedNetwork{
+					Subnet:   "172.30.0.0/24",
+					Internal: true,
+				},
+			},
+			false,
+		},
+		{
+			"egress: invalid external network",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				Egress:         &Egress{Allow: []string{"*.slack.com"}},
+				ManagedNetwork: &ManagedNetwork{Subnet: "172.30.0.0/24"},
+			},
+			true,
+		},
+		{
+			"egress: invalid without managed network",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Egress:        &Egress{Allow: []string{"*.slack.com"}},
+			},
+			true,
+		},
+		{
+			"resolver: valid",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				Resolver:       &Resolver{Upstreams: []string{"tls://1.1.1.1"}},
+				ManagedNetwork: &ManagedNetwork{Subnet: "172.30.0.0/24"},
+			},
+			false,
+		},
+		{
+			"resolver: invalid with dns",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				DNS:            "1.1.1.1",
+				Resolver:       &Resolver{Upstreams: []string{"tls://1.1.1.1"}},
+				ManagedNetwork: &ManagedNetwork{Subnet: "172.30.0.0/24"},
+			},
+			true,
+		},
+		{
+			"resolver: invalid without subnet",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				Resolver:       &Resolver{Upstreams: []string{"tls://1.1.1.1"}},
+				ManagedNetwork: &ManagedNetwork{},
+			},
+			true,
+		},
+		{
+			"display: valid scale",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Scale:         1.25,
+			},
+			false,
+		},
+		{
+			"display: invalid dpi",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				DPI:           1000,
+			},
+			true,
+		},
+		{
+			"displayBackend: valid headless",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				DisplayBackend: "headless",
+			},
+			false,
+		},
+		{
+			"displayBackend: invalid",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				DisplayBackend: "vnc",
+			},
+			true,
+		},
+		{
+			"keyboard: valid",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Keyboard: &Keyboard{
+					Layout:  "us,de",
+					Variant: "dvorak,",
+					Options: []string{"ctrl:nocaps"},
+					GrabKey: "none",
+				},
+			},
+			false,
+		},
+		{
+			"keyboard: invalid layout",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Keyboard:      &Keyboard{Layout: "us; reboot"},
+			},
+			true,
+		},
+		{
+			"keyboard: unsupported grab key",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Keyboard:      &Keyboard{GrabKey: "ctrl+alt"},
+			},
+			true,
+		},
+		{
+			"clipboard: valid targets",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Clipboard:     &Clipboard{Targets: []string{"text/plain;charset=utf-8", "UTF8_STRING", "image/svg+xml"}},
+			},
+			false,
+		},
+		{
+			"clipboard: invalid target",
+			Profile{
+				Name:          "valid",
+				WindowManager: "valid",
+				Clipboard:     &Clipboard{Targets: []string{"text/plain'; reboot"}},
+			},
+			true,
+		},
+		{
+			"managedNetwork: invalid dns",
+			Profile{
+				Name:           "valid",
+				WindowManager:  "valid",
+				ManagedNetwork: &ManagedNetwork{DNS: []string{"dns.local"}},
+			},
+			true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -0,0 +1,126 @@
+package types
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// EgressProxyPort is the port of the profile's egress proxy, which
+// listens on the gateway of its managed network.
+const EgressProxyPort = 3128
+
+// defaultPorts are the ports reachable per scheme when the profile does
+// not set any. CONNECT tunnels count as https.
+var defaultPorts = map[string][]uint16{
+	"http":  {80},
+	"https": {443},
+}
+
+var domainRegex = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9\-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9\-]*[a-z0-9])?)*$`)
+
+// Egress defines the domains reachable through the profile's egress
+// proxy. When set at profile level, it defines the ceiling for all of
+// its workloads.
+type Egress struct {
+	// Allow lists the allowed domains. A leading "*." matches any of the
+	// domain's subdomains, but not the domain itself.
+	Allow []string `yaml:"allow"`
+	// Ports lists the reachable ports, defaulting to 80 for plain HTTP
+	// and 443 for CONNECT. As the proxy is shared by all workloads, it can only be set at
+	// profile level.
+	Ports []uint16 `yaml:"ports"`
+}
+
+func (e Egress) Validate() error {
+	for _, d := range e.Allow {
+		if err := valid(d, "egress domain", 253, false, domainRegex); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(e.Ports, 0) {
+		return fmt.Errorf("invalid egress port 0")
+	}
+	return nil
+}
+
+// AllowsPort returns whether port is reachable over scheme under e.
+func (e Egress) AllowsPort(scheme, port string) bool {
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return false
+	}
+	ports := e.Ports
+	if len(ports) == 0 {
+		ports = defaultPorts[scheme]
+	}
+	return slices.Contains(ports, uint16(n))
+}
+
+// Allows returns whether host is reachable under e.
+func (e Egress) Allows(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, d := range e.Allow {
+		if covers(d, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Within returns the domains of e which are also allowed by ceiling.
+// A nil e inherits the domains of ceiling.
+func (e *Egress) Within(ceiling Egress) Egress {
+	if e == nil {
+		return Egress{Allow: append([]string(nil), ceiling.Allow...)}
+	}
+
+	var allow []string
+	for _, d := range e.Allow {
+		for _, c := range ceiling.Allow {
+			if covers(c, d) {
+				allow = append(allow, d)
+				break
+			}
+		}
+	}
+	return Egress{Allow: allow}
+}
+
+// covers returns whether the domain pattern c matches all domains
+// matched by pattern p.
+func covers(c, p string) bool {
+	if c == p {
+		return true
+	}
+	suffix, ok := strings.CutPrefix(c, "*.")
+	if !ok {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimPrefix(p, "*."), "."+suffix)
+}
+
+// Gateway returns the gateway address of the network, which is the
+// first address of its subnet.
+func (n ManagedNetwork) Gateway() (netip.Addr, error) {
+	prefix, err := netip.ParsePrefix(n.Subnet)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid managedNetwork subnet %q: %w", n.Subnet, err)
+	}
+	return prefix.Masked().Addr().Next(), nil
+}
+
+// EgressProxy returns the address of the profile's egress proxy.
+func (p Profile) EgressProxy() (string, error) {
+	if p.Egress == nil || p.ManagedNetwork == nil {
+		return "", fmt.Errorf("profile %q has no egress proxy", p.Name)
+	}
+	gw, err := p.ManagedNetwork.Gateway()
+	if err != nil {
+		return "", err
+	}
+	return netip.AddrPortFrom(gw, EgressProxyPort).String(), nil
+}
@@ -0,0 +1,145 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEgressValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   []string
+		ports   []uint16
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "domains", allow: []string{"github.com", "*.slack.com", "api.example-1.io"}},
+		{name: "uppercase", allow: []string{"GitHub.com"}, wantErr: true},
+		{name: "wildcard in the middle", allow: []string{"api.*.com"}, wantErr: true},
+		{name: "with port", allow: []string{"github.com:443"}, wantErr: true},
+		{name: "empty domain", allow: []string{""}, wantErr: true},
+		{name: "ports", ports: []uint16{443, 8443}},
+		{name: "port zero", ports: []uint16{0}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Egress{Allow: tc.allow, Ports: tc.ports}.Validate()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEgressAllows(t *testing.T) {
+	e := Egress{Allow: []string{"github.com", "*.slack.com"}}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"github.com", true},
+		{"GitHub.com.", true},
+		{"api.github.com", false},
+		{"app.slack.com", true},
+		{"files.app.slack.com", true},
+		{"slack.com", false},
+		{"evilslack.com", false},
+		{"slack.com.evil.io", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.host, func(t *testing.T) {
+			assert.Equal(t, tc.want, e.Allows(tc.host))
+		})
+	}
+}
+
+func TestEgressAllowsPort(t *testing.T) {
+	tests := []struct {
+		name   string
+		ports  []uint16
+		scheme string
+		port   string
+		want   bool
+	}{
+		{name: "default", scheme: "https", port: "443", want: true},
+		{name: "default http", scheme: "http", port: "80", want: true},
+		{name: "default http on https port", scheme: "http", port: "443"},
+		{name: "default ssh", scheme: "https", port: "22"},
+		{name: "no port", scheme: "https", port: ""},
+		{name: "unknown scheme", scheme: "ftp", port: "21"},
+		{name: "configured", ports: []uint16{8443}, scheme: "https", port: "8443", want: true},
+		{name: "configured http", ports: []uint16{8080}, scheme: "http", port: "8080", want: true},
+		{name: "configured replaces default", ports: []uint16{8443}, scheme: "https", port: "443"},
+		{name: "out of range", ports: []uint16{443}, scheme: "https", port: "66000"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Egress{Ports: tc.ports}.AllowsPort(tc.scheme, tc.port))
+		})
+	}
+}
+
+func TestApplyProfileEgress(t *testing.T) {
+	ceiling := &Egress{Allow: []string{"github.com", "*.slack.com"}}
+
+	tests := []struct {
+		name    string
+		profile *Egress
+		egress  *Egress
+		want    *Egress
+	}{
+		{
+			name:   "no egress proxy",
+			egress: &Egress{Allow: []string{"github.com"}},
+			want:   &Egress{Allow: []string{"github.com"}},
+		},
+		{
+			name:    "inherited from profile",
+			profile: ceiling,
+			want:    &Egress{Allow: []string{"github.com", "*.slack.com"}},
+		},
+		{
+			name:    "capped by profile",
+			profile: ceiling,
+			egress:  &Egress{Allow: []string{"app.slack.com", "*.files.slack.com", "slack.com", "api.github.com"}},
+			want:    &Egress{Allow: []string{"app.slack.com", "*.files.slack.com"}},
+		},
+		{
+			name:    "nothing allowed",
+			profile: ceiling,
+			egress:  &Egress{Allow: []string{"example.com"}},
+			want:    &Egress{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := Workload{Name: "w", Egress: tc.egress}
+			ew := w.ApplyProfile(&Profile{Name: "p", Egress: tc.profile})
+			assert.Equal(t, tc.want, ew.Workload.Egress)
+		})
+	}
+}
+
+func TestEgressProxy(t *testing.T) {
+	p := Profile{
+		Name:           "p",
+		Egress:         &Egress{},
+		ManagedNetwork: &ManagedNetwork{Subnet: "172.30.0.128/25", Internal: true},
+	}
+
+	addr, err := p.EgressProxy()
+	require.NoError(t, err)
+	assert.Equal(t, "172.30.0.129:3128", addr)
+
+	_, err = Profile{Name: "p"}.EgressProxy()
+	require.Error(t, err)
+}
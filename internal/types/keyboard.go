@@ -0,0 +1,59 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// GrabKeyNone disables the host grab of the nested X server.
+const GrabKeyNone = "none"
+
+var (
+	xkbRegex       = regexp.MustCompile(`^[a-zA-Z0-9_,()\-]+$`)
+	xkbOptionRegex = regexp.MustCompile(`^[a-zA-Z0-9_:()\-]+$`)
+	grabKeyRegex   = regexp.MustCompile(`^(default|none)$`)
+)
+
+// Keyboard defines the keyboard settings of a profile's display, which
+// are applied with setxkbmap once the X server starts.
+type Keyboard struct {
+	// Layout is the XKB layout, e.g. us or us,de.
+	Layout string `yaml:"layout"`
+	// Variant is the XKB variant, e.g. dvorak.
+	Variant string `yaml:"variant"`
+	// Model is the XKB model, e.g. pc105.
+	Model string `yaml:"model"`
+	// Options are XKB options, e.g. ctrl:nocaps or grp:alt_shift_toggle.
+	Options []string `yaml:"options"`
+	// GrabKey is either default or none, which disables the host grab
+	// when it conflicts with window manager bindings. Other combinations
+	// are not supported, as Xephyr hard-codes Ctrl+Shift and Xwayland
+	// hard-codes Super+Esc.
+	GrabKey string `yaml:"grabKey"`
+}
+
+func (k Keyboard) Validate() error {
+	if err := valid(k.Layout, "keyboard layout", 100, true, xkbRegex); err != nil {
+		return err
+	}
+	if err := valid(k.Variant, "keyboard variant", 100, true, xkbRegex); err != nil {
+		return err
+	}
+	if err := valid(k.Model, "keyboard model", 50, true, xkbRegex); err != nil {
+		return err
+	}
+	for _, o := range k.Options {
+		if err := valid(o, "keyboard option", 100, false, xkbOptionRegex); err != nil {
+			return err
+		}
+	}
+	if k.GrabKey != "" && !grabKeyRegex.MatchString(k.GrabKey) {
+		return fmt.Errorf("keyboard grabKey %q is not supported: must be default or none, as Xephyr and Xwayland hard-code their grab combination", k.GrabKey)
+	}
+	return nil
+}
+
+// NoGrab returns whether the host grab is disabled.
+func (k *Keyboard) NoGrab() bool {
+	return k != nil && k.GrabKey == GrabKeyNone
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// ManagedNetworkFormat is the default name of managed networks.
+const ManagedNetworkFormat = "qubesome-%s"
+
+// ManagedNetwork defines a bridge network that qubesome creates when the
+// profile starts, and removes once it stops.
+type ManagedNetwork struct {
+	// Name of the network, defaults to qubesome-<profile>.
+	Name string `yaml:"name"`
+	// Subnet in CIDR notation, e.g. 172.30.0.0/24. When empty, the
+	// container engine picks one.
+	Subnet string `yaml:"subnet"`
+	// Internal networks have no route out of the host.
+	Internal bool `yaml:"internal"`
+	// DNS servers used by the profile's workloads.
+	DNS []string `yaml:"dns"`
+}
+
+func (n ManagedNetwork) Validate() error {
+	if err := valid(n.Name, "managedNetwork name", 50, true, nameRegex); err != nil {
+		return err
+	}
+	if n.Subnet != "" {
+		if _, err := netip.ParsePrefix(n.Subnet); err != nil {
+			return fmt.Errorf("invalid managedNetwork subnet %q: %w", n.Subnet, err)
+		}
+	}
+	for _, dns := range n.DNS {
+		if _, err := netip.ParseAddr(dns); err != nil {
+			return fmt.Errorf("invalid managedNetwork dns %q: %w", dns, err)
+		}
+	}
+	return nil
+}
+
+// NetworkName returns the container network of the profile, which is
+// either its managed network or the pre-existing Network.
+func (p Profile) NetworkName() string {
+	if p.ManagedNetwork == nil {
+		return p.Network
+	}
+	if p.ManagedNetwork.Name != "" {
+		return p.ManagedNetwork.Name
+	}
+	return fmt.Sprintf(ManagedNetworkFormat, p.Name)
+}
+
+// DNSServers returns the DNS servers for the profile's containers, which
+// is the gateway of its managed network when the profile has a Resolver.
+func (p Profile) DNSServers() []string {
+	if p.Resolver != nil && p.ManagedNetwork != nil {
+		if gw, err := p.ManagedNetwork.Gateway(); err == nil {
+			return []string{gw.String()}
+		}
+	}
+
+	var servers []string
+	if p.DNS != "" {
+		servers = append(servers, p.DNS)
+	}
+	if p.ManagedNetwork != nil {
+		servers = append(servers, p.ManagedNetwork.DNS...)
+	}
+	return servers
+}
@@ -0,0 +1,94 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+const (
+	// ResolverPort is the port of the profile's DNS forwarder. Container
+	// engines only support DNS servers on port 53, so non-root users need
+	// net.ipv4.ip_unprivileged_port_start set to 53 or lower.
+	ResolverPort = 53
+
+	dnsPort = "53"
+	dotPort = "853"
+)
+
+// Resolver defines the DNS forwarder qubesome runs for a profile, which
+// listens on the gateway of its managed network. It requires a rootful
+// container engine, whose network gateways are host addresses.
+type Resolver struct {
+	// Upstreams are the DNS servers queries are forwarded to, in order of
+	// preference. Entries are IPv4 or IPv6 addresses with an optional
+	// port, or tls://host[:port] for DNS-over-TLS.
+	Upstreams []string `yaml:"upstreams"`
+	// BlockLists are files relative to the profile dir, listing one domain
+	// per line. Blocked domains and their subdomains return NXDOMAIN.
+	BlockLists []string `yaml:"blockLists"`
+	// QueryLog records the profile's queries to ~/.qubesome/dns.
+	QueryLog bool `yaml:"queryLog"`
+}
+
+// Upstream is a DNS server used by the Resolver.
+type Upstream struct {
+	// Addr is the host:port of the server.
+	Addr string
+	// TLS is set for DNS-over-TLS servers, which are verified against
+	// ServerName.
+	TLS        bool
+	ServerName string
+}
+
+func (r Resolver) Validate() error {
+	if len(r.Upstreams) == 0 {
+		return fmt.Errorf("resolver requires at least one upstream")
+	}
+	for _, u := range r.Upstreams {
+		if _, err := ParseUpstream(u); err != nil {
+			return err
+		}
+	}
+	for _, bl := range r.BlockLists {
+		if err := valid(bl, "blockList", 200, false, seccompRegex); err != nil {
+			return err
+		}
+		if strings.HasPrefix(bl, "/") || strings.Contains(bl, "..") {
+			return fmt.Errorf("block list %q must be relative to the profile dir", bl)
+		}
+	}
+	return nil
+}
+
+// ParseUpstream parses a Resolver upstream.
+func ParseUpstream(s string) (Upstream, error) {
+	if host, ok := strings.CutPrefix(s, "tls://"); ok {
+		port := dotPort
+		if h, p, err := net.SplitHostPort(host); err == nil {
+			host, port = h, p
+		} else {
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		}
+
+		_, err := netip.ParseAddr(host)
+		if err != nil && (!domainRegex.MatchString(host) || strings.HasPrefix(host, "*.")) {
+			return Upstream{}, fmt.Errorf("invalid DNS-over-TLS upstream %q", s)
+		}
+		return Upstream{
+			Addr:       net.JoinHostPort(host, port),
+			TLS:        true,
+			ServerName: host,
+		}, nil
+	}
+
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return Upstream{Addr: net.JoinHostPort(addr.String(), dnsPort)}, nil
+	}
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return Upstream{}, fmt.Errorf("invalid upstream %q: %w", s, err)
+	}
+	return Upstream{Addr: ap.String()}, nil
+}
@@ -0,0 +1,76 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUpstream(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Upstream
+		wantErr bool
+	}{
+		{in: "1.1.1.1", want: Upstream{Addr: "1.1.1.1:53"}},
+		{in: "1.1.1.1:5353", want: Upstream{Addr: "1.1.1.1:5353"}},
+		{in: "2606:4700::1111", want: Upstream{Addr: "[2606:4700::1111]:53"}},
+		{in: "[2606:4700::1111]:5353", want: Upstream{Addr: "[2606:4700::1111]:5353"}},
+		{in: "tls://1.1.1.1", want: Upstream{Addr: "1.1.1.1:853", TLS: true, ServerName: "1.1.1.1"}},
+		{in: "tls://dns.quad9.net:8853", want: Upstream{Addr: "dns.quad9.net:8853", TLS: true, ServerName: "dns.quad9.net"}},
+		{in: "tls://[2620:fe::fe]", want: Upstream{Addr: "[2620:fe::fe]:853", TLS: true, ServerName: "2620:fe::fe"}},
+		{in: "dns.quad9.net", wantErr: true},
+		{in: "https://dns.quad9.net", wantErr: true},
+		{in: "tls://*.quad9.net", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseUpstream(tc.in)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestResolverValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       Resolver
+		wantErr bool
+	}{
+		{name: "valid", r: Resolver{Upstreams: []string{"tls://1.1.1.1"}, BlockLists: []string{"dns/ads.txt"}}},
+		{name: "no upstreams", r: Resolver{}, wantErr: true},
+		{name: "absolute block list", r: Resolver{Upstreams: []string{"1.1.1.1"}, BlockLists: []string{"/etc/hosts"}}, wantErr: true},
+		{name: "block list path traversal", r: Resolver{Upstreams: []string{"1.1.1.1"}, BlockLists: []string{"../hosts"}}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.r.Validate()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDNSServers(t *testing.T) {
+	p := Profile{
+		Name:           "p",
+		DNS:            "9.9.9.9",
+		ManagedNetwork: &ManagedNetwork{Subnet: "172.30.0.0/24", DNS: []string{"1.1.1.1"}},
+	}
+	assert.Equal(t, []string{"9.9.9.9", "1.1.1.1"}, p.DNSServers())
+
+	p.Resolver = &Resolver{Upstreams: []string{"1.1.1.1"}}
+	assert.Equal(t, []string{"172.30.0.1"}, p.DNSServers())
+}
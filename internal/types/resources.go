@@ -0,0 +1,134 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var sizeRegex = regexp.MustCompile(`^[0-9]+[bkmgBKMG]?$`)
+
+// Resources defines the host resources a workload may use. When set at
+// profile level, it defines the ceiling for all of its workloads.
+type Resources struct {
+	// CPUs is the number of CPUs available to the workload, e.g. 1.5.
+	CPUs float64 `yaml:"cpus"`
+	// Memory is the memory limit, e.g. 512m or 2g.
+	Memory string `yaml:"memory"`
+	// MemorySwap is the limit of memory plus swap, which requires Memory
+	// to be set.
+	MemorySwap string `yaml:"memorySwap"`
+	// Pids is the maximum number of processes.
+	Pids int64 `yaml:"pids"`
+	// ShmSize is the size of /dev/shm. At profile level, it also sets
+	// the size for the profile's own container, which defaults to 128m.
+	ShmSize string `yaml:"shmSize"`
+	// BlkioWeight is the relative block I/O weight, between 10 and 1000.
+	BlkioWeight uint16 `yaml:"blkioWeight"`
+}
+
+func (r Resources) Validate() error {
+	if r.CPUs < 0 {
+		return fmt.Errorf("cpus cannot be negative: %v", r.CPUs)
+	}
+	if r.Pids < 0 {
+		return fmt.Errorf("pids cannot be negative: %v", r.Pids)
+	}
+	if r.BlkioWeight != 0 && (r.BlkioWeight < 10 || r.BlkioWeight > 1000) {
+		return fmt.Errorf("blkioWeight must be between 10 and 1000: %v", r.BlkioWeight)
+	}
+	if err := valid(r.Memory, "memory", 20, true, sizeRegex); err != nil {
+		return err
+	}
+	if err := valid(r.MemorySwap, "memorySwap", 20, true, sizeRegex); err != nil {
+		return err
+	}
+	if err := valid(r.ShmSize, "shmSize", 20, true, sizeRegex); err != nil {
+		return err
+	}
+
+	if r.MemorySwap != "" {
+		if r.Memory == "" {
+			return fmt.Errorf("memorySwap requires memory to be set")
+		}
+		mem, _ := ParseSize(r.Memory)
+		swap, _ := ParseSize(r.MemorySwap)
+		if swap < mem {
+			return fmt.Errorf("memorySwap (%s) cannot be lower than memory (%s)", r.MemorySwap, r.Memory)
+		}
+	}
+	return nil
+}
+
+// Within returns the resources of r capped by ceiling. Limits not set
+// in r are inherited from ceiling. Memory is capped by MemorySwap, so
+// that capping MemorySwap alone does not make the limits invalid.
+func (r Resources) Within(ceiling Resources) Resources {
+	res := Resources{
+		CPUs:        minLimit(r.CPUs, ceiling.CPUs),
+		Memory:      minSize(r.Memory, ceiling.Memory),
+		MemorySwap:  minSize(r.MemorySwap, ceiling.MemorySwap),
+		Pids:        minLimit(r.Pids, ceiling.Pids),
+		ShmSize:     minSize(r.ShmSize, ceiling.ShmSize),
+		BlkioWeight: minLimit(r.BlkioWeight, ceiling.BlkioWeight),
+	}
+	if res.Memory != "" {
+		res.Memory = minSize(res.Memory, res.MemorySwap)
+	}
+	return res
+}
+
+// minLimit returns the lowest of a and b, where zero means no limit.
+func minLimit[T float64 | int64 | uint16](a, b T) T {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// minSize returns the lowest of a and b, where empty means no limit.
+// Invalid sizes are returned as is, so that they fail validation.
+func minSize(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+
+	as, err := ParseSize(a)
+	if err != nil {
+		return a
+	}
+	bs, err := ParseSize(b)
+	if err != nil || bs < as {
+		return b
+	}
+	return a
+}
+
+// ParseSize returns the number of bytes represented by s, which is a
+// number followed by an optional b, k, m or g unit.
+func ParseSize(s string) (int64, error) {
+	if !sizeRegex.MatchString(s) {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	mult := int64(1)
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		mult = 1 << 10
+	case "m":
+		mult = 1 << 20
+	case "g":
+		mult = 1 << 30
+	}
+	s = strings.TrimRight(s, "bkmgBKMG")
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	return n * mult, nil
+}
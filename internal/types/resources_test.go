@@ -0,0 +1,114 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		{in: "1024", want: 1024},
+		{in: "10b", want: 10},
+		{in: "2k", want: 2 << 10},
+		{in: "512m", want: 512 << 20},
+		{in: "2G", want: 2 << 30},
+		{in: "", wantErr: true},
+		{in: "1.5g", wantErr: true},
+		{in: "-1m", wantErr: true},
+		{in: "10t", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseSize(tc.in)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestResourcesWithin(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       Resources
+		ceiling Resources
+		want    Resources
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name: "workload limits without ceiling",
+			r:    Resources{CPUs: 2, Memory: "1g", Pids: 100},
+			want: Resources{CPUs: 2, Memory: "1g", Pids: 100},
+		},
+		{
+			name:    "ceiling is inherited",
+			ceiling: Resources{CPUs: 4, Memory: "2g", ShmSize: "64m", BlkioWeight: 500},
+			want:    Resources{CPUs: 4, Memory: "2g", ShmSize: "64m", BlkioWeight: 500},
+		},
+		{
+			name:    "workload limits above ceiling are capped",
+			r:       Resources{CPUs: 8, Memory: "4g", MemorySwap: "8g", Pids: 1000, BlkioWeight: 1000},
+			ceiling: Resources{CPUs: 4, Memory: "2048m", MemorySwap: "4g", Pids: 500, BlkioWeight: 100},
+			want:    Resources{CPUs: 4, Memory: "2048m", MemorySwap: "4g", Pids: 500, BlkioWeight: 100},
+		},
+		{
+			name:    "workload limits below ceiling are kept",
+			r:       Resources{CPUs: 1, Memory: "512m", ShmSize: "32m"},
+			ceiling: Resources{CPUs: 4, Memory: "2g", ShmSize: "1g"},
+			want:    Resources{CPUs: 1, Memory: "512m", ShmSize: "32m"},
+		},
+		{
+			name:    "memory is capped by memorySwap",
+			r:       Resources{Memory: "512m", MemorySwap: "512m"},
+			ceiling: Resources{MemorySwap: "256m"},
+			want:    Resources{Memory: "256m", MemorySwap: "256m"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.r.Within(tc.ceiling)
+			assert.Equal(t, tc.want, got)
+			assert.NoError(t, got.Validate())
+		})
+	}
+}
+
+func TestResourcesValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       Resources
+		wantErr string
+	}{
+		{name: "empty"},
+		{name: "valid", r: Resources{CPUs: 1, Memory: "1g", MemorySwap: "2g", Pids: 10, ShmSize: "64m", BlkioWeight: 10}},
+		{name: "negative cpus", r: Resources{CPUs: -1}, wantErr: "cpus"},
+		{name: "invalid memory", r: Resources{Memory: "1 GB"}, wantErr: "memory"},
+		{name: "swap without memory", r: Resources{MemorySwap: "1g"}, wantErr: "requires memory"},
+		{name: "swap below memory", r: Resources{Memory: "2g", MemorySwap: "1g"}, wantErr: "cannot be lower"},
+		{name: "blkio weight out of range", r: Resources{BlkioWeight: 5}, wantErr: "blkioWeight"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.r.Validate()
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// ScreensAuto creates one screen per connected output.
+	ScreensAuto = "auto"
+
+	maxScreens = 8
+)
+
+var geometryRegex = regexp.MustCompile(`^[1-9][0-9]{1,4}x[1-9][0-9]{1,4}(\+[0-9]{1,5}\+[0-9]{1,5})?$`)
+
+// Screens defines the screens of a profile's display. In YAML, it is
+// either auto, a number of screens with the primary output's geometry,
+// or a list of geometries such as 1920x1080 or 2560x1440+1920+0.
+type Screens struct {
+	Auto       bool
+	Count      int
+	Geometries []string
+}
+
+func (s *Screens) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Value == ScreensAuto {
+			s.Auto = true
+			return nil
+		}
+		n, err := strconv.Atoi(value.Value)
+		if err != nil {
+			return fmt.Errorf("screens must be auto, a number or a list of geometries: %q", value.Value)
+		}
+		s.Count = n
+		return nil
+
+	case yaml.SequenceNode:
+		return value.Decode(&s.Geometries)
+	}
+	return fmt.Errorf("screens must be auto, a number or a list of geometries")
+}
+
+func (s Screens) MarshalYAML() (any, error) {
+	switch {
+	case s.Auto:
+		return ScreensAuto, nil
+	case len(s.Geometries) > 0:
+		return s.Geometries, nil
+	}
+	return s.Count, nil
+}
+
+func (s Screens) Validate() error {
+	if s.Count < 0 || s.Count > maxScreens || len(s.Geometries) > maxScreens {
+		return fmt.Errorf("screens must be between 1 and %d", maxScreens)
+	}
+	for _, g := range s.Geometries {
+		if err := valid(g, "screen geometry", 30, false, geometryRegex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const (
+	baseDPI  = 96
+	minDPI   = 48
+	maxDPI   = 480
+	minScale = 0.5
+	maxScale = 5
+)
+
+// EffectiveDPI returns the DPI of the profile's display, or 0 when it
+// should use the X server's default.
+func (p Profile) EffectiveDPI() int {
+	if p.DPI != 0 {
+		return p.DPI
+	}
+	return int(math.Round(baseDPI * p.Scale))
+}
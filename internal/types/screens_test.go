@@ -0,0 +1,92 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestScreensUnmarshal(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    *Screens
+		wantErr bool
+	}{
+		{name: "not set", in: "name: p", want: nil},
+		{name: "auto", in: "screens: auto", want: &Screens{Auto: true}},
+		{name: "count", in: "screens: 2", want: &Screens{Count: 2}},
+		{name: "geometries", in: "screens: [1920x1080, 2560x1440+1920+0]", want: &Screens{Geometries: []string{"1920x1080", "2560x1440+1920+0"}}},
+		{name: "invalid scalar", in: "screens: all", wantErr: true},
+		{name: "invalid map", in: "screens: {count: 2}", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var p Profile
+			err := yaml.Unmarshal([]byte(tc.in), &p)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, p.Screens)
+
+			if tc.want != nil {
+				out, err := yaml.Marshal(p.Screens)
+				require.NoError(t, err)
+
+				var s Screens
+				require.NoError(t, yaml.Unmarshal(out, &s))
+				assert.Equal(t, *tc.want, s)
+			}
+		})
+	}
+}
+
+func TestScreensValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Screens
+		wantErr bool
+	}{
+		{name: "auto", s: Screens{Auto: true}},
+		{name: "count", s: Screens{Count: 3}},
+		{name: "too many", s: Screens{Count: 9}, wantErr: true},
+		{name: "geometries", s: Screens{Geometries: []string{"1920x1080", "1920x1080+1920+0"}}},
+		{name: "invalid geometry", s: Screens{Geometries: []string{"1920x"}}, wantErr: true},
+		{name: "geometry with args", s: Screens{Geometries: []string{"1920x1080 -nolisten"}}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.s.Validate()
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEffectiveDPI(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		want    int
+	}{
+		{name: "not set"},
+		{name: "dpi", profile: Profile{DPI: 120}, want: 120},
+		{name: "scale", profile: Profile{Scale: 1.5}, want: 144},
+		{name: "dpi over scale", profile: Profile{DPI: 110, Scale: 2}, want: 110},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.profile.EffectiveDPI())
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	SeccompDefault     = "default"
+	SeccompUnconfined  = "unconfined"
+	AppArmorUnconfined = "unconfined"
+	SELinuxDisable     = "disable"
+)
+
+var (
+	seccompRegex  = regexp.MustCompile(`^[a-zA-Z0-9._\-/]+$`)
+	apparmorRegex = regexp.MustCompile(`^[a-zA-Z0-9._\-/]+$`)
+	selinuxRegex  = regexp.MustCompile(`^(disable|(user|role|type|level|filetype):[a-zA-Z0-9_.:,\-]+)$`)
+)
+
+// Security defines the confinement applied to a workload by the container
+// engine. Empty values use the engine's defaults, except for SELinux
+// labeling which is disabled unless a label is set.
+type Security struct {
+	// Seccomp is either default, unconfined or the path to a seccomp
+	// profile, relative to the profile dir.
+	Seccomp string `yaml:"seccomp"`
+	// AppArmor is either unconfined or the name of an AppArmor profile
+	// loaded on the host.
+	AppArmor string `yaml:"apparmor"`
+	// SELinuxLabel is either disable, the default, or a label option such
+	// as type:container_t or level:s0:c100,c200.
+	SELinuxLabel string `yaml:"selinuxLabel"`
+}
+
+func (s Security) Validate() error {
+	if err := valid(s.Seccomp, "seccomp", 200, true, seccompRegex); err != nil {
+		return err
+	}
+	if s.Seccomp != "" && (strings.HasPrefix(s.Seccomp, "/") || strings.Contains(s.Seccomp, "..")) {
+		return fmt.Errorf("seccomp profile %q must be relative to the profile dir", s.Seccomp)
+	}
+	if err := valid(s.AppArmor, "apparmor", 100, true, apparmorRegex); err != nil {
+		return err
+	}
+	return valid(s.SELinuxLabel, "selinuxLabel", 100, true, selinuxRegex)
+}
+
+// Unconfined returns the settings of s that disable confinement.
+func (s Security) Unconfined() []string {
+	var u []string
+	if s.Seccomp == SeccompUnconfined {
+		u = append(u, "seccomp")
+	}
+	if s.AppArmor == AppArmorUnconfined {
+		u = append(u, "apparmor")
+	}
+	return u
+}
+
+// Confined returns s with the settings that disable confinement replaced
+// by the engine's defaults.
+func (s Security) Confined() Security {
+	if s.Seccomp == SeccompUnconfined {
+		s.Seccomp = ""
+	}
+	if s.AppArmor == AppArmorUnconfined {
+		s.AppArmor = ""
+	}
+	return s
+}
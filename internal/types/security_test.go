@@ -0,0 +1,72 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       Security
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "unconfined", s: Security{Seccomp: "unconfined", AppArmor: "unconfined", SELinuxLabel: "disable"}},
+		{name: "custom", s: Security{Seccomp: "seccomp/chrome.json", AppArmor: "qubesome-chrome", SELinuxLabel: "type:container_t"}},
+		{name: "absolute seccomp path", s: Security{Seccomp: "/etc/seccomp.json"}, wantErr: "relative"},
+		{name: "seccomp path traversal", s: Security{Seccomp: "../../seccomp.json"}, wantErr: "relative"},
+		{name: "invalid selinux label", s: Security{SELinuxLabel: "foo"}, wantErr: "selinuxLabel"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.s.Validate()
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestApplyProfileSecurity(t *testing.T) {
+	unconfined := Security{Seccomp: "unconfined", AppArmor: "unconfined", SELinuxLabel: "disable"}
+	custom := Security{Seccomp: "chrome.json", AppArmor: "chrome", SELinuxLabel: "type:container_t"}
+
+	tests := []struct {
+		name     string
+		security Security
+		forbid   bool
+		want     Security
+	}{
+		{
+			name:     "unconfined allowed",
+			security: unconfined,
+			want:     unconfined,
+		},
+		{
+			name:     "unconfined forbidden",
+			security: unconfined,
+			forbid:   true,
+			want:     Security{SELinuxLabel: "disable"},
+		},
+		{
+			name:     "custom profiles are kept",
+			security: custom,
+			forbid:   true,
+			want:     custom,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := Workload{Security: tc.security}
+			got := w.ApplyProfile(&Profile{ForbidUnconfined: tc.forbid})
+			assert.Equal(t, tc.want, got.Workload.Security)
+		})
+	}
+}
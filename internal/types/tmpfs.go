@@ -0,0 +1,34 @@
+package types
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	tmpfsPathRegex = regexp.MustCompile(`^/[a-zA-Z0-9._\-/]*$`)
+	modeRegex      = regexp.MustCompile(`^[0-7]{3,4}$`)
+)
+
+// Tmpfs defines a tmpfs mount within a workload, which is the only
+// writable location when the root file system is read-only.
+type Tmpfs struct {
+	Path string `yaml:"path"`
+	// Size is the size limit of the mount, e.g. 64m.
+	Size string `yaml:"size"`
+	// Mode is the octal file mode of the mount, e.g. 1777.
+	Mode string `yaml:"mode"`
+}
+
+func (t Tmpfs) Validate() error {
+	if err := valid(t.Path, "tmpfs path", 200, false, tmpfsPathRegex); err != nil {
+		return err
+	}
+	if err := valid(t.Size, "tmpfs size", 20, true, sizeRegex); err != nil {
+		return err
+	}
+	if err := valid(t.Mode, "tmpfs mode", 4, true, modeRegex); err != nil {
+		return fmt.Errorf("invalid tmpfs %q: %w", t.Path, err)
+	}
+	return nil
+}
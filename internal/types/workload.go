@@ -24,9 +24,22 @@ type Workload struct {
 	SingleInstance bool       `yaml:"singleInstance"`
 	HostAccess     HostAccess `yaml:"hostAccess"`
 	MimeApps       []string   `yaml:"mimeApps"`
+	Resources      Resources  `yaml:"resources"`
+	Security       Security   `yaml:"security"`
+	// ReadOnlyRootfs mounts the root file system of the workload as
+	// read-only, so that changes can only be written to Tmpfs and Paths.
+	ReadOnlyRootfs bool    `yaml:"readOnlyRootfs"`
+	Tmpfs          []Tmpfs `yaml:"tmpfs"`
+	// Egress defines the domains the workload may reach, when its
+	// profile has an egress proxy. If not set, the profile's domains
+	// are used.
+	Egress *Egress `yaml:"egress"`
 
 	Runner string `yaml:"runner"`
-	User   *int   `yaml:"user"`
+	// Runtime selects the OCI runtime used by the container runner, which
+	// must be allowed by the profile's Runtimes.
+	Runtime string `yaml:"runtime"`
+	User    *int   `yaml:"user"`
 }
 
 type HostAccess struct {
@@ -88,6 +101,23 @@ func (w Workload) ApplyProfile(p *Profile) EffectiveWorkload {
 	e.Workload.HostAccess.Mime = w.HostAccess.Mime && p.Mime
 	e.Workload.HostAccess.Privileged = w.HostAccess.Privileged && p.Privileged
 
+	e.Workload.Resources = w.Resources.Within(p.Resources)
+
+	e.Workload.ReadOnlyRootfs = w.ReadOnlyRootfs || p.ReadOnlyRootfs
+
+	if p.Egress != nil {
+		egress := w.Egress.Within(*p.Egress)
+		e.Workload.Egress = &egress
+	}
+
+	if p.ForbidUnconfined {
+		e.Workload.Security = w.Security.Confined()
+	}
+
+	if w.Runtime != "" && !slices.Contains(p.Runtimes, w.Runtime) {
+		e.Workload.Runtime = ""
+	}
+
 	// TODO: Consider restraining user on workloads.
 	e.Workload.User = w.User
 
@@ -97,8 +127,8 @@ func (w Workload) ApplyProfile(p *Profile) EffectiveWorkload {
 
 	// If profile sets a network, that is enforced on all workloads.
 	// If a profile does not set a network, workloads can only set "none" as a network.
-	if p.Network != "" && w.HostAccess.Network != "none" {
-		e.Workload.HostAccess.Network = p.Network
+	if pn := p.NetworkName(); pn != "" && w.HostAccess.Network != "none" {
+		e.Workload.HostAccess.Network = pn
 	} else if w.HostAccess.Network != "" && w.HostAccess.Network != "none" {
 		e.Workload.HostAccess.Network = ""
 	}
@@ -167,6 +197,83 @@ func (w Workload) ApplyProfile(p *Profile) EffectiveWorkload {
 	return e
 }
 
+// Grants returns a human-readable list of the host access granted to
+// the effective workload. The list is used to detect and report access
+// changes across config versions, so its order is stable.
+func (e EffectiveWorkload) Grants() []string {
+	h := e.Workload.HostAccess
+	var grants []string
+
+	for _, f := range []struct {
+		name string
+		on   bool
+	}{
+		{"privileged", h.Privileged},
+		{"dbus", h.Dbus},
+		{"camera", h.Camera},
+		{"microphone", h.Microphone},
+		{"speakers", h.Speakers},
+		{"bluetooth", h.Bluetooth},
+		{"varRunUser", h.VarRunUser},
+		{"mime", h.Mime},
+	} {
+		if f.on {
+			grants = append(grants, f.name)
+		}
+	}
+
+	if h.Network != "" && h.Network != "none" {
+		grants = append(grants, "network: "+h.Network)
+	}
+
+	// The runner and OCI runtime decide how the workload is isolated
+	// from the host, so switching them is an access change too.
+	if e.Workload.Runner != "" {
+		grants = append(grants, "runner: "+e.Workload.Runner)
+	}
+	if e.Workload.Runtime != "" {
+		grants = append(grants, "runtime: "+e.Workload.Runtime)
+	}
+
+	s := e.Workload.Security
+	if s.Seccomp != "" && s.Seccomp != SeccompDefault {
+		grants = append(grants, "seccomp: "+s.Seccomp)
+	}
+	if s.AppArmor != "" {
+		grants = append(grants, "apparmor: "+s.AppArmor)
+	}
+	if s.SELinuxLabel != "" && s.SELinuxLabel != SELinuxDisable {
+		grants = append(grants, "selinuxLabel: "+s.SELinuxLabel)
+	}
+	if h.Gpus != "" {
+		grants = append(grants, "gpus: "+h.Gpus)
+	}
+
+	var egress []string
+	if e.Workload.Egress != nil {
+		egress = e.Workload.Egress.Allow
+	}
+
+	for _, l := range []struct {
+		prefix string
+		values []string
+	}{
+		{"path: ", h.Paths},
+		{"device: ", h.Devices},
+		{"cap: ", h.CapsAdd},
+		{"usb: ", h.USBDevices},
+		{"egress: ", egress},
+	} {
+		values := slices.Clone(l.values)
+		slices.Sort(values)
+		for _, v := range values {
+			grants = append(grants, l.prefix+v)
+		}
+	}
+
+	return grants
+}
+
 func pathAllowed(path string, list []string) bool {
 	path = filepath.Clean(env.Expand(path))
 	for _, a := range list {
@@ -193,12 +300,35 @@ func (w Workload) Validate() error {
 	if err := valid(w.Command, "command", 100, true, nil); err != nil {
 		return err
 	}
-	if err := valid(w.Image, "image", 100, false, imageRegex); err != nil {
+	// bwrap runs host binaries, so it does not require an image.
+	if err := valid(w.Image, "image", 100, w.Runner == "bwrap", imageRegex); err != nil {
 		return err
 	}
 	if err := valid(w.Runner, "runner", 20, true, runnerRegex); err != nil {
 		return err
 	}
+	if err := valid(w.Runtime, "runtime", 50, true, runtimeRegex); err != nil {
+		return err
+	}
+	if err := w.Resources.Validate(); err != nil {
+		return err
+	}
+	if err := w.Security.Validate(); err != nil {
+		return err
+	}
+	for _, t := range w.Tmpfs {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	if w.Egress != nil {
+		if err := w.Egress.Validate(); err != nil {
+			return err
+		}
+		if len(w.Egress.Ports) > 0 {
+			return fmt.Errorf("egress ports can only be set at profile level")
+		}
+	}
 	for _, mime := range w.MimeApps {
 		if err := valid(mime, "mime", 100, false, nil); err != nil {
 			return err
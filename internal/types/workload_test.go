@@ -636,6 +636,26 @@ func Test_ApplyProfile(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Network managed: workload empty + profile managed",
+			workload: Workload{
+				HostAccess: HostAccess{Network: ""},
+			},
+			profile: &Profile{
+				Name:           "p",
+				ManagedNetwork: &ManagedNetwork{},
+			},
+			want: EffectiveWorkload{
+				Name: "-p",
+				Workload: Workload{
+					HostAccess: HostAccess{Network: "qubesome-p"},
+				},
+				Profile: &Profile{
+					Name:           "p",
+					ManagedNetwork: &ManagedNetwork{},
+				},
+			},
+		},
 		{
 			name: "Network foo: workload foo + profile foo",
 			workload: Workload{
@@ -1269,3 +1289,119 @@ func TestWorkloadValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestGrants(t *testing.T) {
+	tests := []struct {
+		name     string
+		workload Workload
+		profile  *Profile
+		want     []string
+	}{
+		{
+			name:     "no access",
+			workload: Workload{HostAccess: HostAccess{Camera: true, Network: "none"}},
+			profile:  &Profile{},
+		},
+		{
+			name: "only what the profile allows",
+			workload: Workload{HostAccess: HostAccess{
+				Camera:     true,
+				Microphone: true,
+				Privileged: true,
+				CapsAdd:    []string{"NET_RAW", "NET_ADMIN"},
+				Paths:      []string{"/tmp/b:/b", "/tmp/a:/a", "/etc:/etc"},
+			}},
+			profile: &Profile{HostAccess: HostAccess{
+				Camera:     true,
+				Privileged: true,
+				Network:    "host",
+				CapsAdd:    []string{"NET_ADMIN", "NET_RAW"},
+				Paths:      []string{"/tmp"},
+			}},
+			want: []string{
+				"privileged",
+				"camera",
+				"network: host",
+				"path: /tmp/a:/a",
+				"path: /tmp/b:/b",
+				"cap: NET_ADMIN",
+				"cap: NET_RAW",
+			},
+		},
+		{
+			name:     "runner and allowed runtime",
+			workload: Workload{Runner: "bwrap", Runtime: "runsc"},
+			profile:  &Profile{Runtimes: []string{"runsc"}},
+			want:     []string{"runner: bwrap", "runtime: runsc"},
+		},
+		{
+			name:     "runtime not allowed by the profile",
+			workload: Workload{Runtime: "runsc"},
+			profile:  &Profile{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.workload.ApplyProfile(tc.profile).Grants()
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestApplyProfileRuntime(t *testing.T) {
+	tests := []struct {
+		name     string
+		runtime  string
+		runtimes []string
+		want     string
+	}{
+		{
+			name: "default runtime",
+		},
+		{
+			name:     "allowed",
+			runtime:  "runsc",
+			runtimes: []string{"crun", "runsc"},
+			want:     "runsc",
+		},
+		{
+			name:     "not allowed",
+			runtime:  "runsc",
+			runtimes: []string{"crun"},
+		},
+		{
+			name:    "profile allows no runtimes",
+			runtime: "runsc",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := Workload{Runtime: tc.runtime}
+			got := w.ApplyProfile(&Profile{Runtimes: tc.runtimes})
+			assert.Equal(t, tc.want, got.Workload.Runtime)
+		})
+	}
+}
+
+func TestApplyProfileReadOnlyRootfs(t *testing.T) {
+	tests := []struct {
+		name     string
+		workload bool
+		profile  bool
+		want     bool
+	}{
+		{name: "writable"},
+		{name: "workload opts in", workload: true, want: true},
+		{name: "profile requires it", profile: true, want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := Workload{ReadOnlyRootfs: tc.workload}
+			got := w.ApplyProfile(&Profile{ReadOnlyRootfs: tc.profile})
+			assert.Equal(t, tc.want, got.Workload.ReadOnlyRootfs)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package update
+
+import (
+	"fmt"
+
+	"github.com/qubesome/cli/internal/command"
+)
+
+type Options struct {
+	GitURL  string
+	Path    string
+	Profile string
+	Restart bool
+	// RequireSigned requires the updated HEAD to be signed by an
+	// allowed signer.
+	RequireSigned bool
+}
+
+func WithGitURL(gitURL string) command.Option[Options] {
+	return func(o *Options) {
+		o.GitURL = gitURL
+	}
+}
+
+func WithPath(path string) command.Option[Options] {
+	return func(o *Options) {
+		o.Path = path
+	}
+}
+
+func WithProfile(profile string) command.Option[Options] {
+	return func(o *Options) {
+		o.Profile = profile
+	}
+}
+
+func WithRestart() command.Option[Options] {
+	return func(o *Options) {
+		o.Restart = true
+	}
+}
+
+func WithRequireSigned() command.Option[Options] {
+	return func(o *Options) {
+		o.RequireSigned = true
+	}
+}
+
+func (o *Options) Validate() error {
+	if o.GitURL == "" && o.Profile == "" {
+		return fmt.Errorf("either a profile or a git URL must be provided")
+	}
+	if o.GitURL != "" && o.Profile != "" {
+		return fmt.Errorf("profile and git URL are mutually exclusive")
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	securejoin "github.com/cyphar/filepath-securejoin"
+	"github.com/go-git/go-git/v6"
+	"github.com/qubesome/cli/internal/command"
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/permissions"
+	"github.com/qubesome/cli/internal/profiles"
+	"github.com/qubesome/cli/internal/runners/util/container"
+	"github.com/qubesome/cli/internal/types"
+	"github.com/qubesome/cli/internal/util/gitrepo"
+	"gopkg.in/yaml.v3"
+)
+
+const configFile = "qubesome.config"
+
+// Summary holds the qubesome specific view of a git change.
+type Summary struct {
+	// Workloads holds the repository-relative paths of the workload
+	// files that changed.
+	Workloads []string
+	// Images maps workload files (or profiles) to their image change,
+	// in the format "old -> new".
+	Images map[string]string
+}
+
+func Run(opts ...command.Option[Options]) error {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	dir, path, err := gitDir(o)
+	if err != nil {
+		return err
+	}
+
+	keyring, err := profiles.AllowedSigners(o.RequireSigned)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("updating git clone", "dir", dir, "path", path, "verify", keyring != "")
+	change, err := gitrepo.Update(dir, keyring)
+	if err != nil {
+		return fmt.Errorf("cannot update %q: %w", dir, err)
+	}
+
+	if change.UpToDate() {
+		fmt.Printf("Already up to date at %s\n", change.To.String()[:7])
+		return nil
+	}
+
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s..%s (%d commits)\n",
+		change.From.String()[:7], change.To.String()[:7], len(change.Commits))
+	for _, c := range change.Commits {
+		fmt.Printf("  %s %s\n", c.Hash.String()[:7], firstLine(c.Message))
+	}
+	if change.Signer != "" {
+		fmt.Printf("Signed by %s\n", change.Signer)
+	}
+
+	s, err := summarise(change.Files,
+		func(p string) ([]byte, error) { return gitrepo.FileAt(r, change.From, p) },
+		func(p string) ([]byte, error) { return gitrepo.FileAt(r, change.To, p) })
+	if err != nil {
+		return err
+	}
+	printSummary(s)
+
+	cfgPath, err := securejoin.SecureJoin(dir, filepath.Join(path, configFile))
+	if err != nil {
+		return err
+	}
+	cfg, err := types.LoadConfig(cfgPath)
+	if err != nil {
+		return err
+	}
+
+	affected := affectedProfiles(cfg, path, change.Files)
+	if len(affected) == 0 {
+		return nil
+	}
+
+	fmt.Println("Affected profiles:", strings.Join(affected, ", "))
+
+	grants := map[string]permissions.Grants{}
+	for _, name := range affected {
+		p, _ := cfg.Profile(name)
+		pd, err := securejoin.SecureJoin(dir, filepath.Join(path, p.Path))
+		if err != nil {
+			return err
+		}
+
+		g, err := permissions.Load(p, pd)
+		if err != nil {
+			return err
+		}
+		grants[name] = g
+
+		old, ok, err := permissions.Accepted(name)
+		if err != nil {
+			return err
+		}
+		if added := g.Added(old); ok && len(added) > 0 {
+			fmt.Printf("New host access for profile %q:\n%s", name, added)
+		}
+	}
+
+	if !o.Restart {
+		return nil
+	}
+
+	url, err := gitrepo.RemoteURL(r)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range affected {
+		p, _ := cfg.Profile(name)
+		if !activeFrom(dir, p) {
+			continue
+		}
+
+		err := permissions.Check(name, grants[name], false)
+		if errors.Is(err, permissions.ErrNotAccepted) {
+			fmt.Printf("Not restarting profile %q: %v\n", name, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Restarting profile %q\n", name)
+		if err := profiles.Stop(p.Runner, name); err != nil {
+			return err
+		}
+
+		args := []string{"start", "-git", url, "-local", dir, "-path", path}
+		if o.RequireSigned {
+			args = append(args, "-require-signed")
+		}
+		if err := startDetached(append(args, name)...); err != nil {
+			return fmt.Errorf("failed to restart profile %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// gitDir returns the local clone to be updated and the path within it
+// that contains the qubesome config.
+func gitDir(o *Options) (string, string, error) {
+	if o.Profile != "" {
+		target, err := os.Readlink(files.ProfileConfig(o.Profile))
+		if err != nil {
+			return "", "", fmt.Errorf("profile %q is not active: use -git instead", o.Profile)
+		}
+
+		dir, err := gitrepo.Root(filepath.Dir(target))
+		if err != nil {
+			return "", "", err
+		}
+
+		path, err := filepath.Rel(dir, filepath.Dir(target))
+		if err != nil {
+			return "", "", err
+		}
+		return dir, path, nil
+	}
+
+	dir, err := files.GitDirPath(o.GitURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return "", "", fmt.Errorf("%q has not been cloned yet: use qubesome start -git", o.GitURL)
+	}
+
+	return dir, o.Path, nil
+}
+
+func summarise(changed []string, before, after func(string) ([]byte, error)) (*Summary, error) {
+	s := &Summary{Images: map[string]string{}}
+
+	for _, fn := range changed {
+		switch {
+		case filepath.Base(filepath.Dir(fn)) == "workloads" && filepath.Ext(fn) == ".yaml":
+			s.Workloads = append(s.Workloads, fn)
+
+			old, err := workloadImage(before, fn)
+			if err != nil {
+				return nil, err
+			}
+			cur, err := workloadImage(after, fn)
+			if err != nil {
+				return nil, err
+			}
+			if old != cur {
+				s.Images[fn] = imageChange(old, cur)
+			}
+
+		case filepath.Base(fn) == configFile:
+			old, err := profileImages(before, fn)
+			if err != nil {
+				return nil, err
+			}
+			cur, err := profileImages(after, fn)
+			if err != nil {
+				return nil, err
+			}
+			for name := range merge(old, cur) {
+				if old[name] != cur[name] {
+					s.Images[fmt.Sprintf("%s (profile %s)", fn, name)] = imageChange(old[name], cur[name])
+				}
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func workloadImage(read func(string) ([]byte, error), fn string) (string, error) {
+	data, err := read(fn)
+	if err != nil || len(data) == 0 {
+		return "", err
+	}
+
+	w := types.Workload{}
+	if err := yaml.Unmarshal(data, &w); err != nil {
+		return "", fmt.Errorf("cannot unmarshal workload file %q: %w", fn, err)
+	}
+	return w.Image, nil
+}
+
+func profileImages(read func(string) ([]byte, error), fn string) (map[string]string, error) {
+	images := map[string]string{}
+	data, err := read(fn)
+	if err != nil || len(data) == 0 {
+		return images, err
+	}
+
+	cfg := types.Config{}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal config file %q: %w", fn, err)
+	}
+
+	for name, p := range cfg.Profiles {
+		images[name] = p.Image
+	}
+	return images, nil
+}
+
+func imageChange(old, cur string) string {
+	if old == "" {
+		old = "(none)"
+	}
+	if cur == "" {
+		cur = "(none)"
+	}
+	return old + " -> " + cur
+}
+
+func merge(a, b map[string]string) map[string]struct{} {
+	keys := map[string]struct{}{}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// affectedProfiles returns the profiles in cfg which had either their
+// config or any file within their profile dir changed.
+func affectedProfiles(cfg *types.Config, path string, changed []string) []string {
+	var affected []string
+	cfgFile := filepath.Join(path, configFile)
+
+	for name, p := range cfg.Profiles {
+		dir := filepath.Join(path, p.Path) + string(filepath.Separator)
+		for _, fn := range changed {
+			if fn == cfgFile || strings.HasPrefix(fn, dir) {
+				affected = append(affected, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(affected)
+	return affected
+}
+
+// activeFrom returns whether the profile is running and was started
+// from the clone at dir.
+func activeFrom(dir string, p *types.Profile) bool {
+	target, err := os.Readlink(files.ProfileConfig(p.Name))
+	if err != nil {
+		return false
+	}
+	if !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+		return false
+	}
+
+	bin := files.ContainerRunnerBinary(p.Runner)
+	return container.Running(bin, fmt.Sprintf(profiles.ContainerNameFormat, p.Name))
+}
+
+func startDetached(args ...string) error {
+	bin, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("starting detached", "binary", bin, "args", args)
+	cmd := exec.Command(bin, args...) //nolint
+	cmd.Env = os.Environ()
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+
+	return cmd.Start()
+}
+
+func printSummary(s *Summary) {
+	if len(s.Workloads) > 0 {
+		fmt.Println("Changed workloads:")
+		for _, w := range s.Workloads {
+			fmt.Println("  -", w)
+		}
+	}
+
+	if len(s.Images) > 0 {
+		keys := make([]string, 0, len(s.Images))
+		for k := range s.Images {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Println("Changed images:")
+		for _, k := range keys {
+			fmt.Printf("  - %s: %s\n", k, s.Images[k])
+		}
+	}
+}
+
+func firstLine(msg string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(msg), "\n")
+	return line
+}
@@ -0,0 +1,95 @@
+package update
+
+import (
+	"testing"
+
+	"github.com/qubesome/cli/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarise(t *testing.T) {
+	before := map[string]string{
+		"i3/workloads/chrome.yaml":  "image: ghcr.io/qubesome/chrome:1",
+		"i3/workloads/firefox.yaml": "image: ghcr.io/qubesome/firefox:1",
+		"qubesome.config":           "profiles:\n  i3:\n    image: ghcr.io/qubesome/xorg:1\n",
+	}
+	after := map[string]string{
+		"i3/workloads/chrome.yaml":  "image: ghcr.io/qubesome/chrome:2",
+		"i3/workloads/firefox.yaml": "image: ghcr.io/qubesome/firefox:1\nsingleInstance: true",
+		"i3/workloads/slack.yaml":   "image: ghcr.io/qubesome/slack:1",
+		"qubesome.config":           "profiles:\n  i3:\n    image: ghcr.io/qubesome/xorg:2\n",
+	}
+
+	read := func(m map[string]string) func(string) ([]byte, error) {
+		return func(p string) ([]byte, error) {
+			return []byte(m[p]), nil
+		}
+	}
+
+	changed := []string{
+		"README.md",
+		"i3/workloads/chrome.yaml",
+		"i3/workloads/firefox.yaml",
+		"i3/workloads/slack.yaml",
+		"qubesome.config",
+	}
+
+	got, err := summarise(changed, read(before), read(after))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"i3/workloads/chrome.yaml",
+		"i3/workloads/firefox.yaml",
+		"i3/workloads/slack.yaml",
+	}, got.Workloads)
+	assert.Equal(t, map[string]string{
+		"i3/workloads/chrome.yaml":     "ghcr.io/qubesome/chrome:1 -> ghcr.io/qubesome/chrome:2",
+		"i3/workloads/slack.yaml":      "(none) -> ghcr.io/qubesome/slack:1",
+		"qubesome.config (profile i3)": "ghcr.io/qubesome/xorg:1 -> ghcr.io/qubesome/xorg:2",
+	}, got.Images)
+}
+
+func TestAffectedProfiles(t *testing.T) {
+	cfg := &types.Config{
+		Profiles: map[string]types.Profile{
+			"i3":      {Path: "i3"},
+			"awesome": {Path: "awesome"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		changed []string
+		want    []string
+	}{
+		{
+			name:    "unrelated files",
+			changed: []string{"README.md", "i3-old/foo"},
+		},
+		{
+			name:    "profile dir",
+			changed: []string{"i3/workloads/chrome.yaml"},
+			want:    []string{"i3"},
+		},
+		{
+			name:    "config changes affect all profiles",
+			changed: []string{"qubesome.config"},
+			want:    []string{"awesome", "i3"},
+		},
+		{
+			name:    "config within path",
+			path:    "dotfiles",
+			changed: []string{"dotfiles/awesome/rc.lua", "i3/rc"},
+			want:    []string{"awesome"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := affectedProfiles(cfg, tc.path, tc.changed)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
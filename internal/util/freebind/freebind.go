@@ -0,0 +1,38 @@
+// Package freebind listens on addresses which may not be assigned to the
+// host yet, such as the gateway of a container network whose bridge is
+// only created once the first container joins it.
+package freebind
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var lc = net.ListenConfig{
+	Control: func(network, _ string, c syscall.RawConn) error {
+		level, opt := unix.SOL_IP, unix.IP_FREEBIND
+		if network == "tcp6" || network == "udp6" {
+			level, opt = unix.SOL_IPV6, unix.IPV6_FREEBIND
+		}
+
+		var serr error
+		err := c.Control(func(fd uintptr) {
+			serr = unix.SetsockoptInt(int(fd), level, opt, 1)
+		})
+		return errors.Join(err, serr)
+	},
+}
+
+// Listen is net.Listen with IP_FREEBIND set.
+func Listen(network, addr string) (net.Listener, error) {
+	return lc.Listen(context.Background(), network, addr)
+}
+
+// ListenPacket is net.ListenPacket with IP_FREEBIND set.
+func ListenPacket(network, addr string) (net.PacketConn, error) {
+	return lc.ListenPacket(context.Background(), network, addr)
+}
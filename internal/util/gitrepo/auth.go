@@ -0,0 +1,186 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/client"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh"
+	"github.com/kevinburke/ssh_config"
+	"github.com/qubesome/cli/internal/files"
+	"github.com/qubesome/cli/internal/keyring"
+	"github.com/qubesome/cli/internal/keyring/backend"
+	"github.com/qubesome/cli/internal/types"
+)
+
+const defaultUser = "git"
+
+var (
+	// ErrAuthFailed is returned when the git remote rejected the
+	// credentials, or required credentials that were not available.
+	ErrAuthFailed = errors.New("git authentication failed")
+	// ErrNetwork is returned when the git remote could not be reached.
+	ErrNetwork = errors.New("cannot reach git remote")
+)
+
+// credentials holds the sources used to authenticate against git remotes.
+type credentials struct {
+	// token returns the HTTPS token for host, or an empty string.
+	token func(host string) string
+	// sshKey is an explicit private key file for SSH remotes.
+	sshKey string
+	// sshConfig returns the ~/.ssh/config value of key for a host alias.
+	sshConfig func(alias, key string) string
+}
+
+func defaultCredentials() credentials {
+	c := credentials{
+		token:     keyringToken,
+		sshConfig: ssh_config.Get,
+	}
+
+	if _, err := os.Stat(files.QubesomeConfig()); err == nil {
+		cfg, err := types.LoadConfig(files.QubesomeConfig())
+		if err == nil {
+			c.sshKey = cfg.GitSSHKey
+		}
+	}
+
+	return c
+}
+
+var keyringGet = func(scope string, name keyring.SecretName) (string, error) {
+	return keyring.New(scope, backend.New()).Get(name)
+}
+
+// keyringToken returns the git token set for host. Tokens are never sent
+// to hosts other than the one they were set for.
+func keyringToken(host string) string {
+	if host == "" {
+		return ""
+	}
+
+	scope := keyring.GitHostScope(host)
+	v, err := keyringGet(scope, keyring.GitToken)
+	if err != nil || v == "" {
+		slog.Debug("no git token found", "scope", scope, "error", err)
+		return ""
+	}
+	return v
+}
+
+// ClientOptions returns the transport options required to reach url.
+//
+// HTTPS remotes use the git-token set for their host in the qubesome
+// keyring, when set.
+// SSH remotes honour ~/.ssh/config host aliases and use, in order, the
+// gitSSHKey from the user-level config, the IdentityFile for the host
+// or the ssh-agent.
+func ClientOptions(url string) ([]client.Option, error) {
+	return defaultCredentials().clientOptions(url)
+}
+
+func (c credentials) clientOptions(rawURL string) ([]client.Option, error) {
+	u, err := transport.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid git URL %q: %w", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		token := c.token(u.Hostname())
+		if token == "" {
+			return nil, nil
+		}
+
+		user := u.User.Username()
+		if user == "" {
+			user = defaultUser
+		}
+		return []client.Option{client.WithHTTPAuth(&http.BasicAuth{
+			Username: user,
+			Password: token,
+		})}, nil
+
+	case "ssh":
+		user, key := c.sshSettings(u)
+		if key != "" {
+			a, err := ssh.NewPublicKeysFromFile(user, key, "")
+			if err == nil {
+				return []client.Option{client.WithSSHAuth(a)}, nil
+			}
+			if c.sshKey != "" {
+				return nil, fmt.Errorf("cannot load SSH key %q: %w", key, err)
+			}
+			slog.Debug("cannot load IdentityFile, falling back to ssh-agent", "path", key, "error", err)
+		}
+
+		a, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("%w: no usable SSH key and ssh-agent is not available: %w", ErrAuthFailed, err)
+		}
+		return []client.Option{client.WithSSHAuth(a)}, nil
+	}
+
+	return nil, nil
+}
+
+// sshSettings returns the user and private key file to be used for u,
+// taking into account ~/.ssh/config host aliases.
+func (c credentials) sshSettings(u *url.URL) (string, string) {
+	alias := u.Hostname()
+
+	user := u.User.Username()
+	if user == "" {
+		user = c.sshConfig(alias, "User")
+	}
+	if user == "" {
+		user = defaultUser
+	}
+
+	key := c.sshKey
+	if key == "" {
+		f := c.sshConfig(alias, "IdentityFile")
+		if f != ssh_config.Default("IdentityFile") {
+			key = f
+		}
+	}
+
+	if strings.HasPrefix(key, "~/") {
+		key = filepath.Join(os.ExpandEnv("${HOME}"), key[2:])
+	}
+	return user, key
+}
+
+// classify wraps err with ErrAuthFailed or ErrNetwork, so that callers
+// can tell credential problems apart from connectivity ones.
+func classify(url string, err error) error {
+	if err == nil || errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrNetwork) {
+		return err
+	}
+
+	if errors.Is(err, transport.ErrAuthenticationRequired) ||
+		errors.Is(err, transport.ErrAuthorizationFailed) ||
+		strings.Contains(err.Error(), "unable to authenticate") {
+		hint := "check the ssh-agent or the gitSSHKey in the user-level config"
+		if u, perr := transport.ParseURL(url); perr == nil && strings.HasPrefix(u.Scheme, "http") {
+			hint = "set a token with: qubesome secret set -host " + u.Hostname() + " git-token"
+		}
+		return fmt.Errorf("%w for %q (%s): %w", ErrAuthFailed, url, hint, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, transport.ErrTimeoutExceeded) {
+		return fmt.Errorf("%w %q: %w", ErrNetwork, url, err)
+	}
+
+	return err
+}
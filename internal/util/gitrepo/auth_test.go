@@ -0,0 +1,173 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/kevinburke/ssh_config"
+	"github.com/qubesome/cli/internal/keyring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHSettings(t *testing.T) {
+	t.Setenv("HOME", "/home/user")
+
+	sshConfig := map[string]map[string]string{
+		"work": {"User": "alice", "IdentityFile": "~/.ssh/id_work"},
+	}
+	get := func(alias, key string) string {
+		if v, ok := sshConfig[alias][key]; ok {
+			return v
+		}
+		return ssh_config.Default(key)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		sshKey   string
+		wantUser string
+		wantKey  string
+	}{
+		{
+			name:     "scp-like URL",
+			url:      "git@github.com:qubesome/cli",
+			wantUser: "git",
+		},
+		{
+			name:     "host alias",
+			url:      "work:org/dotfiles",
+			wantUser: "alice",
+			wantKey:  "/home/user/.ssh/id_work",
+		},
+		{
+			name:     "URL user takes precedence",
+			url:      "ssh://bob@work/org/dotfiles",
+			wantUser: "bob",
+			wantKey:  "/home/user/.ssh/id_work",
+		},
+		{
+			name:     "explicit key",
+			url:      "work:org/dotfiles",
+			sshKey:   "/keys/qubesome",
+			wantUser: "alice",
+			wantKey:  "/keys/qubesome",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := transport.ParseURL(tc.url)
+			require.NoError(t, err)
+
+			c := credentials{sshKey: tc.sshKey, sshConfig: get}
+			user, key := c.sshSettings(u)
+			assert.Equal(t, tc.wantUser, user)
+			assert.Equal(t, tc.wantKey, key)
+		})
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	c := credentials{
+		token: func(host string) string {
+			if host == "github.com" {
+				return "secret"
+			}
+			return ""
+		},
+		sshKey: filepath.Join(t.TempDir(), "missing"),
+	}
+
+	opts, err := c.clientOptions("https://github.com/qubesome/cli")
+	require.NoError(t, err)
+	assert.Len(t, opts, 1)
+
+	opts, err = c.clientOptions("https://gitlab.com/qubesome/cli")
+	require.NoError(t, err)
+	assert.Empty(t, opts)
+
+	c.sshConfig = func(_, key string) string { return ssh_config.Default(key) }
+	_, err = c.clientOptions("git@github.com:qubesome/cli")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestKeyringToken(t *testing.T) {
+	old := keyringGet
+	t.Cleanup(func() { keyringGet = old })
+
+	scopes := map[string]string{
+		"git":            "unscoped",
+		"git:github.com": "secret",
+	}
+	keyringGet = func(scope string, name keyring.SecretName) (string, error) {
+		assert.Equal(t, keyring.GitToken, name)
+		if v, ok := scopes[scope]; ok {
+			return v, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	assert.Equal(t, "secret", keyringToken("github.com"))
+	assert.Empty(t, keyringToken("evil.example.com"), "unknown hosts must get no credentials")
+	assert.Empty(t, keyringToken(""))
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "http auth required",
+			err:  fmt.Errorf("%w: 401", transport.ErrAuthenticationRequired),
+			want: ErrAuthFailed,
+		},
+		{
+			name: "http auth failed",
+			err:  fmt.Errorf("%w: 403", transport.ErrAuthorizationFailed),
+			want: ErrAuthFailed,
+		},
+		{
+			name: "ssh handshake",
+			err:  errors.New("ssh: handshake failed: ssh: unable to authenticate, attempted methods [none publickey]"),
+			want: ErrAuthFailed,
+		},
+		{
+			name: "dns",
+			err:  &net.DNSError{Err: "no such host", Name: "github.invalid"},
+			want: ErrNetwork,
+		},
+		{
+			name: "connection refused",
+			err:  &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			want: ErrNetwork,
+		},
+		{
+			name: "other",
+			err:  transport.ErrRepositoryNotFound,
+			want: transport.ErrRepositoryNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := classify("https://github.com/qubesome/cli", tc.err)
+			if tc.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.want)
+		})
+	}
+}
@@ -0,0 +1,511 @@
+// Package gitrepo wraps the go-git operations used to source qubesome
+// configuration from git repositories.
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+var (
+	// ErrNonFastForward is returned when the remote branch has diverged
+	// from the local clone.
+	ErrNonFastForward = errors.New("refusing non fast-forward update")
+	// ErrDetachedHead is returned when trying to update a clone that is
+	// not currently on a branch.
+	ErrDetachedHead = errors.New("clone is not on a branch")
+	// ErrUnsignedCommit is returned when a commit that must be verified
+	// has no signature.
+	ErrUnsignedCommit = errors.New("commit is not signed")
+	// ErrUntrustedSignature is returned when a commit signature cannot be
+	// verified against the allowed signers.
+	ErrUntrustedSignature = errors.New("commit signature is not trusted")
+)
+
+// pinnedBranch records the branch a clone was on before Checkout detached
+// it, so that Update can re-attach to it.
+const pinnedBranch = plumbing.ReferenceName("refs/qubesome/branch")
+
+// Change describes the movement of a clone from one commit to another.
+type Change struct {
+	From plumbing.Hash
+	To   plumbing.Hash
+
+	// Commits holds the commits in (From, To], newest first.
+	Commits []*object.Commit
+	// Files holds the sorted repository-relative paths of all files
+	// that were added, modified or removed between From and To.
+	Files []string
+	// Signer holds the identity that signed To, when it was verified.
+	Signer string
+}
+
+// UpToDate returns whether the clone did not move.
+func (c *Change) UpToDate() bool {
+	return c.From == c.To
+}
+
+// Clone clones url into dir.
+func Clone(dir, url string) error {
+	opts, err := ClientOptions(url)
+	if err != nil {
+		return err
+	}
+
+	_, err = git.PlainClone(dir, &git.CloneOptions{
+		URL:           url,
+		ClientOptions: opts,
+	})
+	return classify(url, err)
+}
+
+// Root returns the worktree root of the git repository containing path.
+func Root(path string) (string, error) {
+	r, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return "", fmt.Errorf("cannot open git repository at %q: %w", path, err)
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	return wt.Filesystem().Root(), nil
+}
+
+// RemoteURL returns the first URL of the origin remote.
+func RemoteURL(r *git.Repository) (string, error) {
+	remote, err := r.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return "", err
+	}
+
+	urls := remote.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote %q has no URLs", git.DefaultRemoteName)
+	}
+
+	return urls[0], nil
+}
+
+// Head returns the commit currently checked out at dir.
+func Head(dir string) (plumbing.Hash, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return head.Hash(), nil
+}
+
+// Checkout checks out ref at the clone in dir. The ref can be a branch,
+// a tag or a commit hash. Refs that cannot be found locally are fetched
+// from the origin remote. Branches are fetched first, so that they are
+// fast-forwarded to their remote, and may only exist at the remote.
+func Checkout(dir, ref string) (plumbing.Hash, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if isBranch(r, ref) {
+		if err := fetch(r); err != nil {
+			slog.Warn("cannot fetch branch, using local clone", "ref", ref, "error", err)
+		}
+	}
+
+	co, err := checkoutOptions(r, ref)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		slog.Debug("ref not found locally, fetching", "ref", ref)
+		if err := fetch(r); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		co, err = checkoutOptions(r, ref)
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("cannot resolve ref %q: %w", ref, err)
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	current, err := r.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	// Checkout defaults an empty Branch, so decide before calling it.
+	detach := co.Branch == ""
+	if err := wt.Checkout(co); err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("cannot checkout %q: %w", ref, err)
+	}
+
+	switch {
+	case !detach:
+		err = r.Storer.RemoveReference(pinnedBranch)
+	case current.Name().IsBranch():
+		err = r.Storer.SetReference(plumbing.NewSymbolicReference(pinnedBranch, current.Name()))
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("cannot record pinned branch: %w", err)
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return head.Hash(), nil
+}
+
+// isBranch returns whether ref is a local or remote branch of r.
+func isBranch(r *git.Repository, ref string) bool {
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(ref),
+		plumbing.NewRemoteReferenceName(git.DefaultRemoteName, ref),
+	} {
+		if _, err := r.Reference(name, true); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func checkoutOptions(r *git.Repository, ref string) (*git.CheckoutOptions, error) {
+	branch := plumbing.NewBranchReferenceName(ref)
+	local, lerr := r.Reference(branch, true)
+	remote, rerr := r.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, ref), true)
+
+	switch {
+	case lerr == nil && rerr == nil:
+		if err := fastForward(r, local, remote.Hash()); err != nil {
+			return nil, err
+		}
+		return &git.CheckoutOptions{Branch: branch}, nil
+	case lerr == nil:
+		return &git.CheckoutOptions{Branch: branch}, nil
+	case rerr == nil:
+		return &git.CheckoutOptions{Branch: branch, Hash: remote.Hash(), Create: true}, nil
+	}
+
+	h, err := r.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, err
+	}
+	return &git.CheckoutOptions{Hash: *h}, nil
+}
+
+// fastForward moves the local branch to h, when it is behind it. Local
+// branches ahead of h are kept as is.
+func fastForward(r *git.Repository, local *plumbing.Reference, h plumbing.Hash) error {
+	if local.Hash() == h {
+		return nil
+	}
+
+	from, err := r.CommitObject(local.Hash())
+	if err != nil {
+		return err
+	}
+	to, err := r.CommitObject(h)
+	if err != nil {
+		return err
+	}
+
+	if ok, err := from.IsAncestor(to); err != nil {
+		return err
+	} else if ok {
+		slog.Debug("fast-forwarding branch", "branch", local.Name().Short(), "from", local.Hash(), "to", h)
+		return r.Storer.SetReference(plumbing.NewHashReference(local.Name(), h))
+	}
+
+	if ok, err := to.IsAncestor(from); err != nil {
+		return err
+	} else if ok {
+		return nil
+	}
+	return fmt.Errorf("%w: %s has diverged from its remote", ErrNonFastForward, local.Name().Short())
+}
+
+func fetch(r *git.Repository) error {
+	url, err := RemoteURL(r)
+	if err != nil {
+		return err
+	}
+
+	opts, err := ClientOptions(url)
+	if err != nil {
+		return err
+	}
+
+	err = r.Fetch(&git.FetchOptions{
+		RemoteName:    git.DefaultRemoteName,
+		ClientOptions: opts,
+		Tags:          plumbing.AllTags,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil
+	}
+	return classify(url, err)
+}
+
+// Verify checks that commit h is signed by a key within the armored
+// OpenPGP keyring, returning the signer identity.
+func Verify(r *git.Repository, h plumbing.Hash, keyring string) (string, error) {
+	c, err := r.CommitObject(h)
+	if err != nil {
+		return "", err
+	}
+
+	if c.Signature == "" {
+		return "", fmt.Errorf("%w: %s", ErrUnsignedCommit, h)
+	}
+
+	e, err := c.Verify(keyring)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrUntrustedSignature, h, err)
+	}
+
+	if id := e.PrimaryIdentity(); id != nil {
+		return id.Name, nil
+	}
+	return e.PrimaryKey.KeyIdString(), nil
+}
+
+// Update fetches the origin remote of the clone at dir and fast-forwards
+// its current branch. When keyring is set, the new HEAD must be signed by
+// one of its keys, otherwise the clone is reset back to where it was.
+func Update(dir, keyring string) (*Change, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := RemoteURL(r)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := ClientOptions(url)
+	if err != nil {
+		return nil, err
+	}
+
+	wt, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	// A clone detached by Checkout (e.g. by a rollback) is moved back
+	// onto the branch it was pinned from.
+	from := head.Hash()
+	pinned := !head.Name().IsBranch()
+	if pinned {
+		head, err = reattach(r, wt, head)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = wt.Pull(&git.PullOptions{
+		RemoteName:    git.DefaultRemoteName,
+		ReferenceName: head.Name(),
+		SingleBranch:  true,
+		ClientOptions: opts,
+	})
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return Diff(r, from, head.Hash())
+	}
+	if errors.Is(err, git.ErrNonFastForwardUpdate) {
+		return nil, errors.Join(
+			fmt.Errorf("%w: %s has diverged from its remote", ErrNonFastForward, head.Name().Short()),
+			repin(r, wt, pinned, from))
+	}
+	if err != nil {
+		return nil, errors.Join(classify(url, err), repin(r, wt, pinned, from))
+	}
+
+	newHead, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	var signer string
+	if keyring != "" {
+		signer, err = Verify(r, newHead.Hash(), keyring)
+		if err != nil {
+			slog.Debug("resetting clone after failed verification", "commit", head.Hash())
+			rerr := wt.Reset(&git.ResetOptions{
+				Commit: head.Hash(),
+				Mode:   git.MergeReset,
+			})
+			if rerr != nil {
+				return nil, fmt.Errorf("%w: failed to reset clone: %w", err, rerr)
+			}
+			return nil, errors.Join(err, repin(r, wt, pinned, from))
+		}
+	}
+
+	c, err := Diff(r, from, newHead.Hash())
+	if err != nil {
+		return nil, err
+	}
+	c.Signer = signer
+	return c, nil
+}
+
+// Diff returns the Change between two commits, where from must be an
+// ancestor of to.
+func Diff(r *git.Repository, from, to plumbing.Hash) (*Change, error) {
+	c := &Change{From: from, To: to}
+	if from == to {
+		return c, nil
+	}
+
+	fromCommit, err := r.CommitObject(from)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := r.CommitObject(to)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := r.Log(&git.LogOptions{From: to})
+	if err != nil {
+		return nil, err
+	}
+	err = iter.ForEach(func(cm *object.Commit) error {
+		if cm.Hash == from {
+			return storer.ErrStop
+		}
+		c.Commits = append(c.Commits, cm)
+		return nil
+	})
+	if err != nil && !errors.Is(err, storer.ErrStop) {
+		return nil, err
+	}
+
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := toCommit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]struct{}{}
+	for _, ch := range changes {
+		for _, name := range []string{ch.From.Name, ch.To.Name} {
+			if name == "" {
+				continue
+			}
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				c.Files = append(c.Files, name)
+			}
+		}
+	}
+	sort.Strings(c.Files)
+
+	return c, nil
+}
+
+// FileAt returns the contents of path at the given commit. A file that
+// does not exist at that commit yields no data and no error.
+func FileAt(r *git.Repository, h plumbing.Hash, path string) ([]byte, error) {
+	c, err := r.CommitObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+// reattach checks out the branch recorded by Checkout when it detached
+// the clone at head.
+func reattach(r *git.Repository, wt *git.Worktree, head *plumbing.Reference) (*plumbing.Reference, error) {
+	pin, err := r.Storer.Reference(pinnedBranch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: HEAD is at %s, run qubesome start -ref <branch> to move it back onto a branch",
+			ErrDetachedHead, head.Hash())
+	}
+
+	branch := pin.Target()
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: branch}); err != nil {
+		return nil, fmt.Errorf("cannot check out pinned branch %q: %w", branch.Short(), err)
+	}
+	slog.Debug("re-attached clone to pinned branch", "branch", branch.Short(), "from", head.Hash())
+
+	if err := r.Storer.RemoveReference(pinnedBranch); err != nil {
+		return nil, err
+	}
+	return r.Head()
+}
+
+// repin restores a clone that Update re-attached back onto the commit it
+// was pinned to, so that a failed update leaves it as it was found.
+func repin(r *git.Repository, wt *git.Worktree, pinned bool, at plumbing.Hash) error {
+	if !pinned {
+		return nil
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Hash: at}); err != nil {
+		return fmt.Errorf("cannot restore pinned commit %s: %w", at, err)
+	}
+	return r.Storer.SetReference(plumbing.NewSymbolicReference(pinnedBranch, head.Name()))
+}
+
+// Detached reports whether the clone at dir is pinned to a commit rather
+// than following a branch.
+func Detached(dir string) (bool, error) {
+	r, err := git.PlainOpen(dir)
+	if err != nil {
+		return false, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return false, err
+	}
+	return !head.Name().IsBranch(), nil
+}
@@ -0,0 +1,220 @@
+package gitrepo
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entitySigner struct {
+	e *openpgp.Entity
+}
+
+func (s entitySigner) Sign(msg io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, s.e, msg, nil); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func newEntity(t *testing.T, name string) *openpgp.Entity {
+	t.Helper()
+	e, err := openpgp.NewEntity(name, "", name+"@example.com", nil)
+	require.NoError(t, err)
+	return e
+}
+
+func armoredKeyring(t *testing.T, e *openpgp.Entity) string {
+	t.Helper()
+	var b bytes.Buffer
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, e.Serialize(w))
+	require.NoError(t, w.Close())
+	return b.String()
+}
+
+func commit(t *testing.T, r *git.Repository, signer git.Signer) plumbing.Hash {
+	t.Helper()
+	wt, err := r.Worktree()
+	require.NoError(t, err)
+
+	h, err := wt.Commit("test", &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Signer:            signer,
+	})
+	require.NoError(t, err)
+	return h
+}
+
+func TestVerify(t *testing.T) {
+	trusted := newEntity(t, "trusted")
+	untrusted := newEntity(t, "untrusted")
+	keyring := armoredKeyring(t, trusted)
+
+	r, err := git.PlainInit(t.TempDir(), false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		signer  git.Signer
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "unsigned",
+			wantErr: ErrUnsignedCommit,
+		},
+		{
+			name:    "untrusted signer",
+			signer:  entitySigner{untrusted},
+			wantErr: ErrUntrustedSignature,
+		},
+		{
+			name:   "trusted signer",
+			signer: entitySigner{trusted},
+			want:   "trusted <trusted@example.com>",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := commit(t, r, tc.signer)
+
+			got, err := Verify(r, h, keyring)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestCheckout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	origin := t.TempDir()
+	or, err := git.PlainInit(origin, false)
+	require.NoError(t, err)
+	first := commit(t, or, nil)
+	head, err := or.Head()
+	require.NoError(t, err)
+	main := head.Name()
+
+	dir := t.TempDir()
+	require.NoError(t, Clone(dir, origin))
+	r, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+
+	owt, err := or.Worktree()
+	require.NoError(t, err)
+	checkout := func(branch plumbing.ReferenceName, create bool) {
+		t.Helper()
+		require.NoError(t, owt.Checkout(&git.CheckoutOptions{Branch: branch, Create: create}))
+	}
+
+	// Branches which only exist at the remote.
+	feature := plumbing.NewBranchReferenceName("feature")
+	checkout(feature, true)
+	want := commit(t, or, nil)
+
+	got, err := Checkout(dir, "feature")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+	head, err = r.Head()
+	require.NoError(t, err)
+	assert.Equal(t, feature, head.Name())
+
+	// Stale local branches are fast-forwarded.
+	checkout(main, false)
+	want = commit(t, or, nil)
+
+	got, err = Checkout(dir, main.Short())
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	// Diverged branches are not checked out.
+	checkout(feature, false)
+	commit(t, or, nil)
+	_, err = Checkout(dir, "feature")
+	require.NoError(t, err)
+	wt, err := r.Worktree()
+	require.NoError(t, err)
+	_, err = wt.Commit("local", &git.CommitOptions{
+		AllowEmptyCommits: true,
+		Author:            &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	commit(t, or, nil)
+
+	_, err = Checkout(dir, "feature")
+	require.ErrorIs(t, err, ErrNonFastForward)
+
+	// Commits are checked out with a detached HEAD.
+	got, err = Checkout(dir, first.String())
+	require.NoError(t, err)
+	assert.Equal(t, first, got)
+	head, err = r.Head()
+	require.NoError(t, err)
+	assert.False(t, head.Name().IsBranch())
+}
+
+func TestUpdateAfterCheckout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	origin := t.TempDir()
+	or, err := git.PlainInit(origin, false)
+	require.NoError(t, err)
+	first := commit(t, or, nil)
+	head, err := or.Head()
+	require.NoError(t, err)
+	main := head.Name()
+	commit(t, or, nil)
+
+	dir := t.TempDir()
+	require.NoError(t, Clone(dir, origin))
+	r, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+
+	// Rolled back clones are re-attached to their branch.
+	_, err = Checkout(dir, first.String())
+	require.NoError(t, err)
+	want := commit(t, or, nil)
+
+	c, err := Update(dir, "")
+	require.NoError(t, err)
+	assert.Equal(t, first, c.From)
+	assert.Equal(t, want, c.To)
+	head, err = r.Head()
+	require.NoError(t, err)
+	assert.Equal(t, main, head.Name())
+	assert.Equal(t, want, head.Hash())
+
+	detached, err := Detached(dir)
+	require.NoError(t, err)
+	assert.False(t, detached)
+
+	// Clones detached outside of Checkout are left alone.
+	wt, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Hash: first}))
+
+	_, err = Update(dir, "")
+	require.ErrorIs(t, err, ErrDetachedHead)
+	head, err = r.Head()
+	require.NoError(t, err)
+	assert.Equal(t, first, head.Hash())
+}
@@ -0,0 +1,144 @@
+package resolution
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/qubesome/cli/internal/files"
+	"golang.org/x/sys/execabs"
+)
+
+var (
+	// e.g. "HDMI-1 connected primary 1920x1080+0+0 (normal left ...".
+	xrandrOutputRegex = regexp.MustCompile(`^(\S+) connected( primary)? ([0-9]+)x([0-9]+)\+([0-9]+)\+([0-9]+)`)
+	// e.g. "    1920x1080 px, 60.000000 Hz (preferred, current)".
+	wlrModeRegex = regexp.MustCompile(`^([0-9]+)x([0-9]+) px, .*current`)
+)
+
+// Output is a connected and enabled output of the host.
+type Output struct {
+	Name    string
+	Width   int
+	Height  int
+	X       int
+	Y       int
+	Scale   float64
+	Primary bool
+}
+
+// Geometry returns the size of o, e.g. 1920x1080.
+func (o Output) Geometry() string {
+	return fmt.Sprintf("%dx%d", o.Width, o.Height)
+}
+
+// Outputs returns the connected outputs of the host, with the primary
+// output first.
+func Outputs() ([]Output, error) {
+	if out, err := execabs.Command(files.XrandrBinary).Output(); err == nil {
+		if outputs := parseXrandr(out); len(outputs) > 0 {
+			return outputs, nil
+		}
+	}
+
+	out, err := execabs.Command(files.WlrRandrBinary).Output()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list outputs: %w", err)
+	}
+	outputs := parseWlrRandr(out)
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("cannot get outputs from output: %q", out)
+	}
+	return outputs, nil
+}
+
+// parseXrandr parses the output of xrandr. The scale is always 1, as
+// xrandr reports sizes in physical pixels.
+func parseXrandr(out []byte) []Output {
+	var outputs []Output
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		m := xrandrOutputRegex.FindStringSubmatch(s.Text())
+		if m == nil {
+			continue
+		}
+
+		o := Output{Name: m[1], Primary: m[2] != "", Scale: 1}
+		o.Width, _ = strconv.Atoi(m[3])
+		o.Height, _ = strconv.Atoi(m[4])
+		o.X, _ = strconv.Atoi(m[5])
+		o.Y, _ = strconv.Atoi(m[6])
+		outputs = append(outputs, o)
+	}
+	return primaryFirst(outputs)
+}
+
+// parseWlrRandr parses the output of wlr-randr. Wayland has no primary
+// output, so the one at the origin is used instead.
+func parseWlrRandr(out []byte) []Output {
+	var outputs []Output
+	var o *Output
+	enabled := false
+
+	flush := func() {
+		if o != nil && enabled && o.Width > 0 {
+			outputs = append(outputs, *o)
+		}
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := s.Text()
+		if line != "" && !strings.HasPrefix(line, " ") {
+			flush()
+			o = &Output{Name: strings.Fields(line)[0], Scale: 1}
+			enabled = false
+			continue
+		}
+		if o == nil {
+			continue
+		}
+
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "Enabled: yes":
+			enabled = true
+		case strings.HasPrefix(line, "Position: "):
+			x, y, _ := strings.Cut(strings.TrimPrefix(line, "Position: "), ",")
+			o.X, _ = strconv.Atoi(x)
+			o.Y, _ = strconv.Atoi(y)
+		case strings.HasPrefix(line, "Scale: "):
+			if f, err := strconv.ParseFloat(strings.TrimPrefix(line, "Scale: "), 64); err == nil && f > 0 {
+				o.Scale = f
+			}
+		default:
+			if m := wlrModeRegex.FindStringSubmatch(line); m != nil {
+				o.Width, _ = strconv.Atoi(m[1])
+				o.Height, _ = strconv.Atoi(m[2])
+			}
+		}
+	}
+	flush()
+
+	for i := range outputs {
+		if outputs[i].X == 0 && outputs[i].Y == 0 {
+			outputs[i].Primary = true
+			break
+		}
+	}
+	return primaryFirst(outputs)
+}
+
+func primaryFirst(outputs []Output) []Output {
+	for i, o := range outputs {
+		if o.Primary && i > 0 {
+			copy(outputs[1:i+1], outputs[:i])
+			outputs[0] = o
+			break
+		}
+	}
+	return outputs
+}
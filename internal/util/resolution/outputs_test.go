@@ -0,0 +1,55 @@
+package resolution
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseXrandr(t *testing.T) {
+	out := []byte(`Screen 0: minimum 320 x 200, current 4480 x 1440, maximum 16384 x 16384
+eDP-1 connected 1920x1080+2560+0 (normal left inverted right x axis y axis) 309mm x 174mm
+   1920x1080     60.01*+  59.97
+   1680x1050     59.95
+HDMI-1 connected primary 2560x1440+0+0 (normal left inverted right x axis y axis) 597mm x 336mm
+   2560x1440     59.95*+
+DP-1 disconnected (normal left inverted right x axis y axis)
+DP-2 connected (normal left inverted right x axis y axis)
+   1920x1080     60.00 +
+`)
+
+	want := []Output{
+		{Name: "HDMI-1", Width: 2560, Height: 1440, Scale: 1, Primary: true},
+		{Name: "eDP-1", Width: 1920, Height: 1080, X: 2560, Scale: 1},
+	}
+	assert.Equal(t, want, parseXrandr(out))
+}
+
+func TestParseWlrRandr(t *testing.T) {
+	out := []byte(`DP-1 "Dell Inc. DELL U2720Q (DP-1)"
+  Make: Dell Inc.
+  Enabled: yes
+  Modes:
+    3840x2160 px, 29.981001 Hz
+    3840x2160 px, 59.997002 Hz (preferred, current)
+  Position: 1280,0
+  Transform: normal
+  Scale: 1.500000
+eDP-1 "Sharp Corporation 0x1449 (eDP-1)"
+  Enabled: yes
+  Modes:
+    2560x1600 px, 60.000000 Hz (preferred, current)
+  Position: 0,0
+  Scale: 2.000000
+HDMI-A-1 "Unknown (HDMI-A-1)"
+  Enabled: no
+  Modes:
+    1920x1080 px, 60.000000 Hz (preferred)
+`)
+
+	want := []Output{
+		{Name: "eDP-1", Width: 2560, Height: 1600, Scale: 2, Primary: true},
+		{Name: "DP-1", Width: 3840, Height: 2160, X: 1280, Scale: 1.5},
+	}
+	assert.Equal(t, want, parseWlrRandr(out))
+}
@@ -0,0 +1,336 @@
+package x11
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	opCreateWindow           = 1
+	opChangeWindowAttributes = 2
+	opInternAtom             = 16
+	opGetAtomName            = 17
+	opChangeProperty         = 18
+	opGetProperty            = 20
+	opSetSelectionOwner      = 22
+	opGetSelectionOwner      = 23
+	opConvertSelection       = 24
+	opSendEvent              = 25
+
+	windowClassInputOnly = 2
+	cwEventMask          = 0x800
+)
+
+// Event codes.
+const (
+	PropertyNotify   = 28
+	SelectionClear   = 29
+	SelectionRequest = 30
+	SelectionNotify  = 31
+)
+
+// Predefined atoms and special values.
+const (
+	None     = 0
+	AtomAtom = 4
+
+	PropertyNewValue = 0
+	PropertyDelete   = 1
+
+	PropertyChangeMask = 0x400000
+
+	PropModeReplace = 0
+)
+
+var ErrIDsExhausted = errors.New("x11 resource ids exhausted")
+
+// MaxPropertySize returns the largest property value which can be set
+// in a single request.
+func (c *Conn) MaxPropertySize() int {
+	n := min(c.maxRequest, 0xffff*4) - 24
+	return n &^ 3
+}
+
+// NewID allocates a resource id.
+func (c *Conn) NewID() (uint32, error) {
+	next := c.lastID + c.idMask&-c.idMask
+	if next > c.idMask {
+		return 0, ErrIDsExhausted
+	}
+	c.lastID = next
+	return c.idBase | next, nil
+}
+
+// CreateWindow creates an unmapped input only window, which receives
+// the events within eventMask.
+func (c *Conn) CreateWindow(eventMask uint32) (uint32, error) {
+	wid, err := c.NewID()
+	if err != nil {
+		return 0, err
+	}
+
+	req := make([]byte, 36)
+	req[0] = opCreateWindow
+	le.PutUint16(req[2:], 9)
+	le.PutUint32(req[4:], wid)
+	le.PutUint32(req[8:], c.screen.root)
+	le.PutUint16(req[16:], 1)
+	le.PutUint16(req[18:], 1)
+	le.PutUint16(req[22:], windowClassInputOnly)
+	le.PutUint32(req[28:], cwEventMask)
+	le.PutUint32(req[32:], eventMask)
+
+	if err := c.send(req); err != nil {
+		return 0, err
+	}
+	return wid, nil
+}
+
+// SelectInput sets the events of window this connection receives.
+func (c *Conn) SelectInput(window, eventMask uint32) error {
+	req := make([]byte, 16)
+	req[0] = opChangeWindowAttributes
+	le.PutUint16(req[2:], 4)
+	le.PutUint32(req[4:], window)
+	le.PutUint32(req[8:], cwEventMask)
+	le.PutUint32(req[12:], eventMask)
+	return c.send(req)
+}
+
+// InternAtom returns the atom for name, creating it if needed.
+func (c *Conn) InternAtom(name string) (uint32, error) {
+	req := make([]byte, 8, 8+pad4(len(name)))
+	req[0] = opInternAtom
+	le.PutUint16(req[2:], uint16(2+pad4(len(name))/4))
+	le.PutUint16(req[4:], uint16(len(name)))
+	req = append(req, padded([]byte(name))...)
+
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, fmt.Errorf("cannot intern atom %q: %w", name, err)
+	}
+	return le.Uint32(reply[8:]), nil
+}
+
+// AtomName returns the name of atom.
+func (c *Conn) AtomName(atom uint32) (string, error) {
+	req := make([]byte, 8)
+	req[0] = opGetAtomName
+	le.PutUint16(req[2:], 2)
+	le.PutUint32(req[4:], atom)
+
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot get atom name: %w", err)
+	}
+	n := int(le.Uint16(reply[8:]))
+	if 32+n > len(reply) {
+		return "", errors.New("x11 atom name truncated")
+	}
+	return string(reply[32 : 32+n]), nil
+}
+
+// ChangeProperty replaces the property of window with data, which is
+// made of format bit units.
+func (c *Conn) ChangeProperty(window, property, typ uint32, format uint8, data []byte) error {
+	if len(data) > c.MaxPropertySize() {
+		return fmt.Errorf("property value too large: %d bytes", len(data))
+	}
+
+	req := make([]byte, 24, 24+pad4(len(data)))
+	req[0] = opChangeProperty
+	req[1] = PropModeReplace
+	le.PutUint16(req[2:], uint16(6+pad4(len(data))/4))
+	le.PutUint32(req[4:], window)
+	le.PutUint32(req[8:], property)
+	le.PutUint32(req[12:], typ)
+	req[16] = format
+	le.PutUint32(req[20:], uint32(len(data)/int(format/8)))
+	req = append(req, padded(data)...)
+	return c.send(req)
+}
+
+// Property is the value of a window property.
+type Property struct {
+	Type   uint32
+	Format uint8
+	Value  []byte
+}
+
+// GetProperty returns the whole value of the property of window,
+// deleting it afterwards when del is set.
+func (c *Conn) GetProperty(window, property uint32, del bool) (Property, error) {
+	req := make([]byte, 24)
+	req[0] = opGetProperty
+	if del {
+		req[1] = 1
+	}
+	le.PutUint16(req[2:], 6)
+	le.PutUint32(req[4:], window)
+	le.PutUint32(req[8:], property)
+	le.PutUint32(req[20:], 0x1fffffff)
+
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return Property{}, fmt.Errorf("cannot get property: %w", err)
+	}
+
+	p := Property{Type: le.Uint32(reply[8:]), Format: reply[1]}
+	n := int(le.Uint32(reply[16:])) * int(p.Format/8)
+	if 32+n > len(reply) {
+		return Property{}, errors.New("x11 property truncated")
+	}
+	p.Value = reply[32 : 32+n]
+	return p, nil
+}
+
+// Atoms returns the value of a property of type ATOM.
+func (p Property) Atoms() []uint32 {
+	if p.Format != 32 {
+		return nil
+	}
+	atoms := make([]uint32, 0, len(p.Value)/4)
+	for i := 0; i+4 <= len(p.Value); i += 4 {
+		atoms = append(atoms, le.Uint32(p.Value[i:]))
+	}
+	return atoms
+}
+
+// SetSelectionOwner makes window the owner of selection.
+func (c *Conn) SetSelectionOwner(window, selection uint32) error {
+	req := make([]byte, 16)
+	req[0] = opSetSelectionOwner
+	le.PutUint16(req[2:], 4)
+	le.PutUint32(req[4:], window)
+	le.PutUint32(req[8:], selection)
+	return c.send(req)
+}
+
+// SelectionOwner returns the window owning selection, or None.
+func (c *Conn) SelectionOwner(selection uint32) (uint32, error) {
+	req := make([]byte, 8)
+	req[0] = opGetSelectionOwner
+	le.PutUint16(req[2:], 2)
+	le.PutUint32(req[4:], selection)
+
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get selection owner: %w", err)
+	}
+	return le.Uint32(reply[8:]), nil
+}
+
+// ConvertSelection asks the owner of selection to store it as target
+// in the property of requestor.
+func (c *Conn) ConvertSelection(requestor, selection, target, property uint32) error {
+	req := make([]byte, 24)
+	req[0] = opConvertSelection
+	le.PutUint16(req[2:], 6)
+	le.PutUint32(req[4:], requestor)
+	le.PutUint32(req[8:], selection)
+	le.PutUint32(req[12:], target)
+	le.PutUint32(req[16:], property)
+	return c.send(req)
+}
+
+// SendSelectionNotify notifies requestor that the conversion of selection
+// into target was stored in property, or refused when property is None.
+func (c *Conn) SendSelectionNotify(ev SelectionRequestEvent, property uint32) error {
+	req := make([]byte, 44)
+	req[0] = opSendEvent
+	le.PutUint16(req[2:], 11)
+	le.PutUint32(req[4:], ev.Requestor)
+
+	e := req[12:]
+	e[0] = SelectionNotify
+	le.PutUint32(e[4:], ev.Time)
+	le.PutUint32(e[8:], ev.Requestor)
+	le.PutUint32(e[12:], ev.Selection)
+	le.PutUint32(e[16:], ev.Target)
+	le.PutUint32(e[20:], property)
+	return c.send(req)
+}
+
+// SelectionRequestEvent is sent to the selection owner when a client
+// requests its conversion.
+type SelectionRequestEvent struct {
+	Time      uint32
+	Owner     uint32
+	Requestor uint32
+	Selection uint32
+	Target    uint32
+	Property  uint32
+}
+
+// ParseSelectionRequest parses a SelectionRequest event.
+func ParseSelectionRequest(ev []byte) SelectionRequestEvent {
+	return SelectionRequestEvent{
+		Time:      le.Uint32(ev[4:]),
+		Owner:     le.Uint32(ev[8:]),
+		Requestor: le.Uint32(ev[12:]),
+		Selection: le.Uint32(ev[16:]),
+		Target:    le.Uint32(ev[20:]),
+		Property:  le.Uint32(ev[24:]),
+	}
+}
+
+// SelectionNotifyEvent is sent to the requestor once a selection was
+// converted.
+type SelectionNotifyEvent struct {
+	Requestor uint32
+	Selection uint32
+	Target    uint32
+	Property  uint32
+}
+
+// ParseSelectionNotify parses a SelectionNotify event.
+func ParseSelectionNotify(ev []byte) SelectionNotifyEvent {
+	return SelectionNotifyEvent{
+		Requestor: le.Uint32(ev[8:]),
+		Selection: le.Uint32(ev[12:]),
+		Target:    le.Uint32(ev[16:]),
+		Property:  le.Uint32(ev[20:]),
+	}
+}
+
+// PropertyNotifyEvent is sent when a property of a window changes.
+type PropertyNotifyEvent struct {
+	Window uint32
+	Atom   uint32
+	Time   uint32
+	State  uint8
+}
+
+// ParsePropertyNotify parses a PropertyNotify event.
+func ParsePropertyNotify(ev []byte) PropertyNotifyEvent {
+	return PropertyNotifyEvent{
+		Window: le.Uint32(ev[4:]),
+		Atom:   le.Uint32(ev[8:]),
+		Time:   le.Uint32(ev[12:]),
+		State:  ev[16],
+	}
+}
+
+// SelectionClearEvent is sent to the previous owner of a selection.
+type SelectionClearEvent struct {
+	Owner     uint32
+	Selection uint32
+}
+
+// ParseSelectionClear parses a SelectionClear event.
+func ParseSelectionClear(ev []byte) SelectionClearEvent {
+	return SelectionClearEvent{
+		Owner:     le.Uint32(ev[8:]),
+		Selection: le.Uint32(ev[12:]),
+	}
+}
+
+// AtomsValue encodes atoms as the value of a property of type ATOM.
+func AtomsValue(atoms ...uint32) []byte {
+	b := make([]byte, 0, len(atoms)*4)
+	for _, a := range atoms {
+		b = binary.LittleEndian.AppendUint32(b, a)
+	}
+	return b
+}
@@ -0,0 +1,411 @@
+// Package x11 implements the subset of the X11 protocol needed to
+// capture the contents of a display and to transfer selections between
+// displays, without depending on Xlib or any host binaries.
+package x11
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math/bits"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	opGetGeometry = 14
+	opGetImage    = 73
+
+	genericEvent = 35
+
+	formatZPixmap = 2
+	allPlanes     = 0xffffffff
+)
+
+var (
+	ErrAuthFailed       = errors.New("x11 authentication failed")
+	ErrUnsupportedImage = errors.New("unsupported x11 image format")
+)
+
+// SocketPath returns the path to the unix socket of the given display.
+func SocketPath(display uint8) string {
+	return fmt.Sprintf("/tmp/.X11-unix/X%d", display)
+}
+
+type format struct {
+	depth       uint8
+	bpp         uint8
+	scanlinePad uint8
+}
+
+type screen struct {
+	root   uint32
+	width  uint16
+	height uint16
+	depth  uint8
+	red    uint32
+	green  uint32
+	blue   uint32
+}
+
+// Conn is a connection to an X server. It is not safe for concurrent use.
+type Conn struct {
+	conn       net.Conn
+	seq        uint16
+	msbImages  bool
+	formats    []format
+	screen     screen
+	idBase     uint32
+	idMask     uint32
+	lastID     uint32
+	maxRequest int
+	events     [][]byte
+}
+
+// Error is an error reported by the X server.
+type Error struct {
+	Code   uint8
+	Opcode uint8
+	Seq    uint16
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("x11 error %d for request %d", e.Code, e.Opcode)
+}
+
+// Dial connects to the given display through its unix socket,
+// authenticating with the given protocol name and data, such as
+// MIT-MAGIC-COOKIE-1 and its cookie.
+func Dial(display uint8, authName string, authData []byte) (*Conn, error) {
+	c, err := net.Dial("unix", SocketPath(display))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to display :%d: %w", display, err)
+	}
+
+	xc, err := NewConn(c, authName, authData)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return xc, nil
+}
+
+// NewConn performs the connection setup with the X server on c.
+func NewConn(c net.Conn, authName string, authData []byte) (*Conn, error) {
+	req := make([]byte, 12, 12+pad4(len(authName))+pad4(len(authData)))
+	req[0] = 'l'
+	le.PutUint16(req[2:], 11)
+	le.PutUint16(req[4:], 0)
+	le.PutUint16(req[6:], uint16(len(authName)))
+	le.PutUint16(req[8:], uint16(len(authData)))
+	req = append(req, padded([]byte(authName))...)
+	req = append(req, padded(authData)...)
+
+	if _, err := c.Write(req); err != nil {
+		return nil, fmt.Errorf("cannot send x11 setup: %w", err)
+	}
+
+	hdr := make([]byte, 8)
+	if _, err := io.ReadFull(c, hdr); err != nil {
+		return nil, fmt.Errorf("cannot read x11 setup reply: %w", err)
+	}
+	body := make([]byte, int(le.Uint16(hdr[6:]))*4)
+	if _, err := io.ReadFull(c, body); err != nil {
+		return nil, fmt.Errorf("cannot read x11 setup reply: %w", err)
+	}
+
+	switch hdr[0] {
+	case 0:
+		reason := string(body[:min(int(hdr[1]), len(body))])
+		return nil, fmt.Errorf("%w: %s", ErrAuthFailed, strings.TrimSpace(reason))
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: further authentication required", ErrAuthFailed)
+	}
+
+	xc := &Conn{conn: c}
+	if err := xc.parseSetup(body); err != nil {
+		return nil, err
+	}
+	return xc, nil
+}
+
+func (c *Conn) parseSetup(b []byte) error {
+	if len(b) < 32 {
+		return errors.New("x11 setup reply too short")
+	}
+
+	c.idBase = le.Uint32(b[4:])
+	c.idMask = le.Uint32(b[8:])
+	c.maxRequest = int(le.Uint16(b[18:])) * 4
+	vendorLen := int(le.Uint16(b[16:]))
+	screens := int(b[20])
+	formats := int(b[21])
+	c.msbImages = b[22] == 1
+
+	off := 32 + pad4(vendorLen)
+	for i := 0; i < formats; i++ {
+		if off+8 > len(b) {
+			return errors.New("x11 setup reply truncated")
+		}
+		c.formats = append(c.formats, format{
+			depth:       b[off],
+			bpp:         b[off+1],
+			scanlinePad: b[off+2],
+		})
+		off += 8
+	}
+
+	if screens == 0 || off+40 > len(b) {
+		return errors.New("x11 setup reply has no screens")
+	}
+
+	s := b[off:]
+	c.screen = screen{
+		root:   le.Uint32(s[0:]),
+		width:  le.Uint16(s[20:]),
+		height: le.Uint16(s[22:]),
+		depth:  s[38],
+	}
+	visual := le.Uint32(s[32:])
+	depths := int(s[39])
+
+	off += 40
+	for i := 0; i < depths; i++ {
+		if off+8 > len(b) {
+			return errors.New("x11 setup reply truncated")
+		}
+		visuals := int(le.Uint16(b[off+2:]))
+		off += 8
+		for j := 0; j < visuals; j++ {
+			if off+24 > len(b) {
+				return errors.New("x11 setup reply truncated")
+			}
+			v := b[off:]
+			if le.Uint32(v) == visual {
+				c.screen.red = le.Uint32(v[8:])
+				c.screen.green = le.Uint32(v[12:])
+				c.screen.blue = le.Uint32(v[16:])
+			}
+			off += 24
+		}
+	}
+
+	return nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// Root returns the root window of the first screen.
+func (c *Conn) Root() uint32 {
+	return c.screen.root
+}
+
+// Size returns the current size of the root window, which may differ
+// from the size at connection time if the display was resized.
+func (c *Conn) Size() (int, int, error) {
+	req := make([]byte, 8)
+	req[0] = opGetGeometry
+	le.PutUint16(req[2:], 2)
+	le.PutUint32(req[4:], c.screen.root)
+
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot get root geometry: %w", err)
+	}
+	return int(le.Uint16(reply[16:])), int(le.Uint16(reply[18:])), nil
+}
+
+// Capture returns the contents of the root window within r.
+func (c *Conn) Capture(r image.Rectangle) (*image.RGBA, error) {
+	req := make([]byte, 20)
+	req[0] = opGetImage
+	req[1] = formatZPixmap
+	le.PutUint16(req[2:], 5)
+	le.PutUint32(req[4:], c.screen.root)
+	le.PutUint16(req[8:], uint16(int16(r.Min.X)))
+	le.PutUint16(req[10:], uint16(int16(r.Min.Y)))
+	le.PutUint16(req[12:], uint16(r.Dx()))
+	le.PutUint16(req[14:], uint16(r.Dy()))
+	le.PutUint32(req[16:], allPlanes)
+
+	reply, err := c.roundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get image: %w", err)
+	}
+	return c.toRGBA(reply[1], reply[32:], r.Dx(), r.Dy())
+}
+
+// CaptureScreen returns the current contents of the whole root window.
+func (c *Conn) CaptureScreen() (*image.RGBA, error) {
+	w, h, err := c.Size()
+	if err != nil {
+		return nil, err
+	}
+	return c.Capture(image.Rect(0, 0, w, h))
+}
+
+func (c *Conn) toRGBA(depth uint8, data []byte, w, h int) (*image.RGBA, error) {
+	var f *format
+	for i := range c.formats {
+		if c.formats[i].depth == depth {
+			f = &c.formats[i]
+		}
+	}
+	if f == nil || f.bpp%8 != 0 || f.bpp < 16 || f.bpp > 32 || f.scanlinePad == 0 {
+		return nil, fmt.Errorf("%w: depth %d", ErrUnsupportedImage, depth)
+	}
+	if c.screen.red == 0 || c.screen.green == 0 || c.screen.blue == 0 {
+		return nil, fmt.Errorf("%w: visual is not TrueColor", ErrUnsupportedImage)
+	}
+
+	bpp := int(f.bpp) / 8
+	pad := int(f.scanlinePad)
+	stride := (w*int(f.bpp) + pad - 1) / pad * pad / 8
+	if len(data) < stride*h {
+		return nil, fmt.Errorf("%w: image data too short", ErrUnsupportedImage)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		row := data[y*stride:]
+		for x := 0; x < w; x++ {
+			px := pixel(row[x*bpp:x*bpp+bpp], c.msbImages)
+			i := img.PixOffset(x, y)
+			img.Pix[i] = channel(px, c.screen.red)
+			img.Pix[i+1] = channel(px, c.screen.green)
+			img.Pix[i+2] = channel(px, c.screen.blue)
+			img.Pix[i+3] = 0xff
+		}
+	}
+	return img, nil
+}
+
+// send sends a request which has no reply.
+func (c *Conn) send(req []byte) error {
+	c.seq++
+	_, err := c.conn.Write(req)
+	return err
+}
+
+// roundTrip sends a request and returns its reply. Events and errors of
+// earlier requests are queued for NextEvent.
+func (c *Conn) roundTrip(req []byte) ([]byte, error) {
+	if err := c.send(req); err != nil {
+		return nil, err
+	}
+
+	for {
+		hdr, err := c.read()
+		if err != nil {
+			return nil, err
+		}
+
+		switch hdr[0] {
+		case 0:
+			xerr := toError(hdr)
+			if xerr.Seq != c.seq {
+				c.events = append(c.events, hdr)
+				continue
+			}
+			return nil, xerr
+		case 1:
+			reply := make([]byte, 32+int(le.Uint32(hdr[4:]))*4)
+			copy(reply, hdr)
+			if _, err := io.ReadFull(c.conn, reply[32:]); err != nil {
+				return nil, err
+			}
+			if seq := le.Uint16(hdr[2:]); seq != c.seq {
+				return nil, fmt.Errorf("unexpected x11 reply sequence %d (want %d)", seq, c.seq)
+			}
+			return reply, nil
+		default:
+			c.events = append(c.events, hdr)
+		}
+	}
+}
+
+// NextEvent returns the next event, blocking until one is received or
+// the deadline of the connection is reached. Errors of requests without
+// replies are returned as *Error.
+func (c *Conn) NextEvent() ([]byte, error) {
+	var ev []byte
+	if len(c.events) > 0 {
+		ev, c.events = c.events[0], c.events[1:]
+	} else {
+		var err error
+		if ev, err = c.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ev[0] == 0 {
+		return nil, toError(ev)
+	}
+	return ev, nil
+}
+
+// SetDeadline sets the deadline for reads and writes on the connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// read reads a 32 byte message, including the additional data of
+// generic events.
+func (c *Conn) read() ([]byte, error) {
+	msg := make([]byte, 32)
+	if _, err := io.ReadFull(c.conn, msg); err != nil {
+		return nil, err
+	}
+	if msg[0]&0x7f == genericEvent {
+		extra := make([]byte, int(le.Uint32(msg[4:]))*4)
+		if _, err := io.ReadFull(c.conn, extra); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+func toError(b []byte) *Error {
+	return &Error{Code: b[1], Opcode: b[10], Seq: le.Uint16(b[2:])}
+}
+
+func pixel(b []byte, msb bool) uint32 {
+	var px uint32
+	for i := range b {
+		if msb {
+			px = px<<8 | uint32(b[i])
+		} else {
+			px |= uint32(b[i]) << (8 * i)
+		}
+	}
+	return px
+}
+
+// channel extracts the bits of mask from px, scaled to 8 bits.
+func channel(px, mask uint32) uint8 {
+	v := (px & mask) >> bits.TrailingZeros32(mask)
+	n := bits.OnesCount32(mask)
+	if n >= 8 {
+		return uint8(v >> (n - 8))
+	}
+	return uint8(v * 0xff / (1<<n - 1))
+}
+
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func padded(b []byte) []byte {
+	out := make([]byte, pad4(len(b)))
+	copy(out, b)
+	return out
+}
+
+var le = binary.LittleEndian
@@ -0,0 +1,172 @@
+package x11
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testRoot   = 0x100
+	testVisual = 0x21
+)
+
+// fakeServer serves a 2x2 depth 24 display to a single client.
+func fakeServer(t *testing.T, c net.Conn, cookie []byte) {
+	t.Helper()
+	defer c.Close()
+
+	hdr := make([]byte, 12)
+	if _, err := io.ReadFull(c, hdr); err != nil {
+		return
+	}
+	nameLen := pad4(int(le.Uint16(hdr[6:])))
+	dataLen := int(le.Uint16(hdr[8:]))
+	auth := make([]byte, nameLen+pad4(dataLen))
+	if _, err := io.ReadFull(c, auth); err != nil {
+		return
+	}
+
+	if string(auth[nameLen:nameLen+dataLen]) != string(cookie) {
+		reason := padded([]byte("No protocol specified"))
+		reply := make([]byte, 8)
+		reply[1] = 21
+		le.PutUint16(reply[6:], uint16(len(reason)/4))
+		_, _ = c.Write(append(reply, reason...))
+		return
+	}
+
+	_, _ = c.Write(setupReply())
+
+	var seq uint16
+	for {
+		req := make([]byte, 4)
+		if _, err := io.ReadFull(c, req); err != nil {
+			return
+		}
+		rest := make([]byte, int(le.Uint16(req[2:]))*4-4)
+		if _, err := io.ReadFull(c, rest); err != nil {
+			return
+		}
+		seq++
+
+		reply := make([]byte, 32)
+		reply[0] = 1
+		le.PutUint16(reply[2:], seq)
+
+		switch req[0] {
+		case opGetGeometry:
+			le.PutUint16(reply[16:], 2)
+			le.PutUint16(reply[18:], 2)
+		case opGetImage:
+			// An event before the reply must be skipped.
+			event := make([]byte, 32)
+			event[0] = 12
+			_, _ = c.Write(event)
+
+			reply[1] = 24
+			le.PutUint32(reply[4:], 4)
+			reply = binary.LittleEndian.AppendUint32(reply, 0xff0000)
+			reply = binary.LittleEndian.AppendUint32(reply, 0x00ff00)
+			reply = binary.LittleEndian.AppendUint32(reply, 0x0000ff)
+			reply = binary.LittleEndian.AppendUint32(reply, 0xffffff)
+		default:
+			reply[0] = 0
+			reply[1] = 1
+			reply[10] = req[0]
+		}
+		_, _ = c.Write(reply)
+	}
+}
+
+func setupReply() []byte {
+	vendor := padded([]byte("fake"))
+
+	body := make([]byte, 32)
+	le.PutUint16(body[16:], 4)
+	body[20] = 1
+	body[21] = 1
+	body = append(body, vendor...)
+	body = append(body, 24, 32, 32, 0, 0, 0, 0, 0)
+
+	scr := make([]byte, 40)
+	le.PutUint32(scr[0:], testRoot)
+	le.PutUint16(scr[20:], 2)
+	le.PutUint16(scr[22:], 2)
+	le.PutUint32(scr[32:], testVisual)
+	scr[38] = 24
+	scr[39] = 1
+	body = append(body, scr...)
+
+	depth := make([]byte, 8)
+	depth[0] = 24
+	le.PutUint16(depth[2:], 1)
+	body = append(body, depth...)
+
+	visual := make([]byte, 24)
+	le.PutUint32(visual[0:], testVisual)
+	visual[4] = 4
+	le.PutUint32(visual[8:], 0xff0000)
+	le.PutUint32(visual[12:], 0x00ff00)
+	le.PutUint32(visual[16:], 0x0000ff)
+	body = append(body, visual...)
+
+	hdr := make([]byte, 8)
+	hdr[0] = 1
+	le.PutUint16(hdr[2:], 11)
+	le.PutUint16(hdr[6:], uint16(len(body)/4))
+	return append(hdr, body...)
+}
+
+func TestCaptureScreen(t *testing.T) {
+	cookie := []byte("0123456789abcdef")
+	client, server := net.Pipe()
+	go fakeServer(t, server, cookie)
+
+	c, err := NewConn(client, "MIT-MAGIC-COOKIE-1", cookie)
+	require.NoError(t, err)
+	defer c.Close()
+
+	assert.Equal(t, uint32(testRoot), c.Root())
+
+	img, err := c.CaptureScreen()
+	require.NoError(t, err)
+
+	assert.Equal(t, image.Rect(0, 0, 2, 2), img.Bounds())
+	assert.Equal(t, color.RGBA{R: 0xff, A: 0xff}, img.RGBAAt(0, 0))
+	assert.Equal(t, color.RGBA{G: 0xff, A: 0xff}, img.RGBAAt(1, 0))
+	assert.Equal(t, color.RGBA{B: 0xff, A: 0xff}, img.RGBAAt(0, 1))
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}, img.RGBAAt(1, 1))
+}
+
+func TestNewConnAuthFailed(t *testing.T) {
+	client, server := net.Pipe()
+	go fakeServer(t, server, []byte("0123456789abcdef"))
+
+	_, err := NewConn(client, "MIT-MAGIC-COOKIE-1", []byte("wrong"))
+	require.ErrorIs(t, err, ErrAuthFailed)
+	assert.ErrorContains(t, err, "No protocol specified")
+}
+
+func TestChannel(t *testing.T) {
+	tests := []struct {
+		px, mask uint32
+		want     uint8
+	}{
+		{px: 0xab0000, mask: 0xff0000, want: 0xab},
+		{px: 0xf800, mask: 0xf800, want: 0xff},
+		{px: 0x07e0, mask: 0x07e0, want: 0xff},
+		{px: 0x0000, mask: 0x001f, want: 0x00},
+		{px: 0x3ff00000, mask: 0x3ff00000, want: 0xff},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, channel(tc.px, tc.mask), "px %#x mask %#x", tc.px, tc.mask)
+	}
+}
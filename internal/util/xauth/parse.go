@@ -0,0 +1,68 @@
+package xauth
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Entry is an entry of an Xauthority file.
+type Entry struct {
+	Family  uint16
+	Address string
+	Number  string
+	Name    string
+	Data    []byte
+}
+
+// Parse returns the entries of the Xauthority file in r. Each entry is
+// made of a big-endian family, followed by the address, display number,
+// auth name and auth data, each prefixed by their big-endian length.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	for {
+		var e Entry
+		if err := binary.Read(r, binary.BigEndian, &e.Family); err != nil {
+			if errors.Is(err, io.EOF) {
+				return entries, nil
+			}
+			return nil, fmt.Errorf("cannot read xauth family: %w", err)
+		}
+
+		fields := make([][]byte, 4)
+		for i := range fields {
+			var n uint16
+			if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+				return nil, fmt.Errorf("cannot read xauth entry: %w", err)
+			}
+			fields[i] = make([]byte, n)
+			if _, err := io.ReadFull(r, fields[i]); err != nil {
+				return nil, fmt.Errorf("cannot read xauth entry: %w", err)
+			}
+		}
+
+		e.Address = string(fields[0])
+		e.Number = string(fields[1])
+		e.Name = string(fields[2])
+		e.Data = fields[3]
+		entries = append(entries, e)
+	}
+}
+
+// Find returns the entry for display, falling back to the first entry
+// when none matches it.
+func Find(entries []Entry, display uint8) (Entry, bool) {
+	if len(entries) == 0 {
+		return Entry{}, false
+	}
+
+	n := strconv.Itoa(int(display))
+	for _, e := range entries {
+		if e.Number == n {
+			return e, true
+		}
+	}
+	return entries[0], true
+}
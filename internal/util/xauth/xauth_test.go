@@ -118,3 +118,34 @@ func FuzzAuthPair(f *testing.F) {
 			&bytes.Buffer{}, &bytes.Buffer{})
 	})
 }
+
+func TestParse(t *testing.T) {
+	data, err := hex.DecodeString(
+		"01000005712d706f6400013000124d49542d4d414749432d434f4f4b49452d310010ffffffffffffffffffffffffffffffff" +
+			"ffff0005712d706f6400013500124d49542d4d414749432d434f4f4b49452d31001040cc3e730e6f7a534a3977321b14e0a7")
+	require.NoError(t, err)
+
+	entries, err := Parse(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, uint16(256), entries[0].Family)
+	assert.Equal(t, "q-pod", entries[0].Address)
+	assert.Equal(t, "0", entries[0].Number)
+	assert.Equal(t, "MIT-MAGIC-COOKIE-1", entries[0].Name)
+	assert.Len(t, entries[0].Data, 16)
+
+	e, ok := Find(entries, 5)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(0xffff), e.Family)
+
+	e, ok = Find(entries, 7)
+	assert.True(t, ok)
+	assert.Equal(t, "0", e.Number)
+
+	_, err = Parse(bytes.NewReader(data[:20]))
+	require.Error(t, err)
+
+	_, ok = Find(nil, 0)
+	assert.False(t, ok)
+}